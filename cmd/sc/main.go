@@ -1,15 +1,31 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/zpdzap/sandcastles/internal/agent"
 	"github.com/zpdzap/sandcastles/internal/config"
+	"github.com/zpdzap/sandcastles/internal/devcontainer"
+	"github.com/zpdzap/sandcastles/internal/dockerfile"
+	"github.com/zpdzap/sandcastles/internal/egress"
 	"github.com/zpdzap/sandcastles/internal/sandbox"
+	"github.com/zpdzap/sandcastles/internal/tasks"
+	"github.com/zpdzap/sandcastles/internal/transcript"
 	"github.com/zpdzap/sandcastles/internal/tui"
+	"github.com/zpdzap/sandcastles/internal/usage"
 )
 
 func main() {
@@ -21,6 +37,15 @@ func main() {
 
 	root.AddCommand(initCmd())
 	root.AddCommand(rebuildCmd())
+	root.AddCommand(cacheCmd())
+	root.AddCommand(warmCmd())
+	root.AddCommand(poolCmd())
+	root.AddCommand(startCmd())
+	root.AddCommand(sendCmd())
+	root.AddCommand(transcriptCmd())
+	root.AddCommand(usageCmd())
+	root.AddCommand(egressProxyCmd())
+	root.AddCommand(watchCredentialsCmd())
 
 	if err := root.Execute(); err != nil {
 		os.Exit(1)
@@ -28,7 +53,10 @@ func main() {
 }
 
 func initCmd() *cobra.Command {
-	return &cobra.Command{
+	var templateName string
+	var regen bool
+
+	cmd := &cobra.Command{
 		Use:   "init",
 		Short: "Initialize sandcastles in the current project",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -38,10 +66,19 @@ func initCmd() *cobra.Command {
 			}
 
 			if config.Exists(projectDir) {
+				if regen {
+					return regenDockerfile(projectDir, templateName)
+				}
 				fmt.Println("Sandcastles already initialized in this project.")
+				fmt.Println("Use --regen-dockerfile to re-render the Dockerfile.")
 				return nil
 			}
 
+			tmpl, err := dockerfile.Load(templateName)
+			if err != nil {
+				return err
+			}
+
 			detection := config.Detect(projectDir)
 			projectName := filepath.Base(projectDir)
 
@@ -50,9 +87,10 @@ func initCmd() *cobra.Command {
 				Project:  projectName,
 				Language: detection.Language,
 				Image: config.Image{
-					Base:       "ubuntu:24.04",
+					Base:       tmpl.DefaultBase(),
 					Dockerfile: ".sandcastles/Dockerfile",
 					Packages:   detection.Packages,
+					Template:   templateName,
 				},
 				Defaults: config.Defaults{
 					Agent:        "claude",
@@ -65,6 +103,22 @@ func initCmd() *cobra.Command {
 				},
 			}
 
+			// Build on top of an existing devcontainer definition if there is one
+			var unsupported []string
+			if detection.Devcontainer != "" {
+				spec, err := devcontainer.Load(filepath.Join(projectDir, detection.Devcontainer))
+				if err != nil {
+					return err
+				}
+				features, skipped := spec.ResolveFeatures()
+				unsupported = skipped
+				cfg.Image.Devcontainer = detection.Devcontainer
+				cfg.Image.Base = spec.BaseImage(projectName)
+				if features.DockerSocket {
+					cfg.Defaults.DockerSocket = true
+				}
+			}
+
 			if err := config.Save(projectDir, cfg); err != nil {
 				return fmt.Errorf("saving config: %w", err)
 			}
@@ -85,7 +139,14 @@ func initCmd() *cobra.Command {
 
 			fmt.Printf("Initialized sandcastles for %s (%s project)\n", projectName, detection.Language)
 			fmt.Printf("  Config: %s/%s\n", config.Dir, config.ConfigFile)
-			fmt.Printf("  Dockerfile: %s/Dockerfile\n", config.Dir)
+			fmt.Printf("  Dockerfile: %s/Dockerfile (template: %s)\n", config.Dir, tmpl.Name)
+			if cfg.Image.Devcontainer != "" {
+				fmt.Printf("\n  Detected %s — building on %s.\n", cfg.Image.Devcontainer, cfg.Image.Base)
+				fmt.Println("  containerEnv, forwardPorts and postCreateCommand are applied to every sandcastle.")
+				for _, f := range unsupported {
+					fmt.Printf("  Feature %s is not supported and will be skipped.\n", f)
+				}
+			}
 			if detection.ClaudeEnv {
 				fmt.Println("\n  Detected ~/.claude — claude_env enabled.")
 				fmt.Println("  Skills, plugins, and settings will be copied into containers.")
@@ -100,6 +161,52 @@ func initCmd() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().StringVar(&templateName, "template", "",
+		fmt.Sprintf("Dockerfile template (%s)", strings.Join(dockerfile.Names(), ", ")))
+	cmd.Flags().BoolVar(&regen, "regen-dockerfile", false,
+		"re-render the Dockerfile of an initialized project, keeping its custom section")
+	return cmd
+}
+
+// regenDockerfile re-renders the Dockerfile of an initialized project,
+// switching to templateName if one is given.
+func regenDockerfile(projectDir, templateName string) error {
+	cfg, err := config.LoadRaw(projectDir)
+	if err != nil {
+		return err
+	}
+
+	if templateName != "" && templateName != cfg.Image.Template {
+		tmpl, err := dockerfile.Load(templateName)
+		if err != nil {
+			return err
+		}
+		cfg.Image.Template = templateName
+		if cfg.Image.Devcontainer == "" {
+			cfg.Image.Base = tmpl.DefaultBase()
+		}
+		if err := config.Save(projectDir, cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+	}
+
+	path := filepath.Join(projectDir, cfg.Image.Dockerfile)
+	if existing, err := os.ReadFile(path); err == nil {
+		if _, ok := dockerfile.ExtractCustom(existing); !ok {
+			// Written before templates had a custom section; keep a copy of any hand edits
+			if err := os.WriteFile(path+".bak", existing, 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("Saved the previous Dockerfile to %s.bak\n", cfg.Image.Dockerfile)
+		}
+	}
+
+	if err := writeDockerfile(projectDir, cfg); err != nil {
+		return fmt.Errorf("writing Dockerfile: %w", err)
+	}
+	fmt.Printf("Regenerated %s. The next /start rebuilds the image.\n", cfg.Image.Dockerfile)
+	return nil
 }
 
 func rebuildCmd() *cobra.Command {
@@ -130,115 +237,548 @@ func rebuildCmd() *cobra.Command {
 	}
 }
 
-func writeDockerfile(projectDir string, cfg *config.Config) error {
-	// Remove nodejs/npm from apt packages — we install Node via NodeSource
-	// to get a modern version (Ubuntu's nodejs is too old for most tools)
-	var pkgs []string
-	for _, p := range cfg.Image.Packages {
-		if p != "nodejs" && p != "npm" {
-			pkgs = append(pkgs, p)
-		}
+func warmCmd() *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "warm",
+		Short: "Prebuild the warm image so the next sandcastle skips setup",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+			if !force && mgr.WarmCurrent() {
+				fmt.Println("Warm image is up to date (use --force to rebuild anyway).")
+				return nil
+			}
+			reason, err := mgr.BuildWarm(func(phase string) {
+				fmt.Println(phase)
+			})
+			if err != nil {
+				return err
+			}
+			if reason == "" || reason == "warm image reused" {
+				reason = "forced"
+			}
+			fmt.Printf("Warm image built (%s).\n", reason)
+			return nil
+		},
 	}
-	// Ensure ca-certificates and gnupg are present (needed for NodeSource)
-	for _, needed := range []string{"ca-certificates", "gnupg"} {
-		found := false
-		for _, p := range pkgs {
-			if p == needed {
-				found = true
-				break
+	cmd.Flags().BoolVar(&force, "force", false, "rebuild even if the warm image is current")
+	return cmd
+}
+
+func cacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect or clear the cache volumes shared by sandcastles",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "ls",
+		Short: "List cache volumes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
 			}
-		}
-		if !found {
-			pkgs = append(pkgs, needed)
-		}
+			caches, err := mgr.Caches()
+			if err != nil {
+				return err
+			}
+			if len(caches) == 0 {
+				fmt.Println("No cache volumes configured.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VOLUME\tPATH\tSTATUS")
+			for _, c := range caches {
+				path, status := c.Path, "created"
+				if path == "" {
+					path, status = "-", "unused (not in config)"
+				} else if !c.Exists {
+					status = "not created yet"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", c.Volume, path, status)
+			}
+			return w.Flush()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear [name...]",
+		Short: "Remove cache volumes (all of them, or the named ones)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+			removed, err := mgr.ClearCaches(args)
+			for _, vol := range removed {
+				fmt.Printf("Removed %s\n", vol)
+			}
+			if err != nil {
+				return fmt.Errorf("%w (stop sandcastles using the cache first)", err)
+			}
+			if len(removed) == 0 {
+				fmt.Println("No cache volumes to remove.")
+			}
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func startCmd() *cobra.Command {
+	var taskFile, base, model string
+	var vars []string
+	cmd := &cobra.Command{
+		Use:   "start [name] [task...]",
+		Short: "Start a sandcastle, optionally from a task template (--task-file)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			task := strings.Join(args[min(1, len(args)):], " ")
+			opts := sandbox.CreateOptions{}
+
+			if taskFile != "" {
+				if task != "" {
+					return fmt.Errorf("give either a task or --task-file, not both")
+				}
+				tmpl, err := tasks.Load(mgr.ProjectDir(), taskFile)
+				if err != nil {
+					return err
+				}
+				values, err := tasks.ParseVars(vars)
+				if err != nil {
+					return err
+				}
+				meta, rendered, err := tmpl.Render(values)
+				if err != nil {
+					return err
+				}
+				task = rendered
+				opts = sandbox.CreateOptions{Base: meta.Base, Model: meta.Model}
+				if name == "" {
+					name = meta.Name
+				}
+			} else if len(vars) > 0 {
+				return fmt.Errorf("--var needs --task-file")
+			}
+			if base != "" {
+				opts.Base = base
+			}
+			if model != "" {
+				opts.Model = model
+			}
+
+			if name == "" {
+				return fmt.Errorf("a sandcastle name is required")
+			}
+			if !sandbox.ValidName.MatchString(name) {
+				return fmt.Errorf("name must be alphanumeric (hyphens ok, e.g. my-sandbox)")
+			}
+
+			sb, err := mgr.Create(name, task, opts, func(phase string) {
+				fmt.Printf("[%s] %s\n", name, phase)
+			})
+			if err != nil {
+				return err
+			}
+			if err := agent.Start("sc-"+sb.Name, task, agent.Options{Model: sb.Model}); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v (attach with `sc` and start the agent by hand)\n", err)
+			}
+			if err := startCredentialWatcher(mgr.ProjectDir()); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: credentials won't sync automatically: %v\n", err)
+			}
+			fmt.Printf("Sandcastle %s is running on branch %s.\n", sb.Name, sb.Branch)
+			return nil
+		},
 	}
-	// Docker CLI for docker socket support
-	if cfg.Defaults.DockerSocket {
-		hasDocker := false
-		for _, p := range pkgs {
-			if p == "docker.io" {
-				hasDocker = true
-				break
+	cmd.Flags().StringVar(&taskFile, "task-file", "", "task template: a name from .sandcastles/tasks/ or a path to a .md file")
+	cmd.Flags().StringArrayVar(&vars, "var", nil, "template variable as key=value (repeatable)")
+	cmd.Flags().StringVar(&base, "base", "", "git ref to branch from (overrides the template's base)")
+	cmd.Flags().StringVar(&model, "model", "", "model for the agent (overrides the template's model)")
+	return cmd
+}
+
+func sendCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "send <name> [message...]",
+		Short: "Send a follow-up prompt to a sandcastle's agent (reads stdin if no message)",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
 			}
-		}
-		if !hasDocker {
-			pkgs = append(pkgs, "docker.io")
-		}
+			name := args[0]
+			sb, ok := mgr.Get(name)
+			if !ok {
+				return fmt.Errorf("sandcastle %q not found", name)
+			}
+			if sb.Status != sandbox.StatusRunning {
+				return fmt.Errorf("sandcastle %q is not running", name)
+			}
+
+			text := strings.Join(args[1:], " ")
+			if text == "" || text == "-" {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("reading prompt: %w", err)
+				}
+				text = string(data)
+			}
+			if err := agent.Send("sc-"+name, text); err != nil {
+				return err
+			}
+			fmt.Printf("Sent prompt to %s.\n", name)
+			return nil
+		},
 	}
-	packages := strings.Join(pkgs, " ")
-
-	userGroups := "sudo"
-	if cfg.Defaults.DockerSocket {
-		userGroups = "sudo,docker"
-	}
-
-	dockerCompose := ""
-	if cfg.Defaults.DockerSocket {
-		dockerCompose = `
-# Install Docker Compose v2 plugin
-RUN mkdir -p /usr/local/lib/docker/cli-plugins && \
-    curl -SL "https://github.com/docker/compose/releases/latest/download/docker-compose-linux-$(uname -m)" \
-    -o /usr/local/lib/docker/cli-plugins/docker-compose && \
-    chmod +x /usr/local/lib/docker/cli-plugins/docker-compose
-`
-	}
-
-	content := fmt.Sprintf(`FROM %s
-
-ARG HOST_UID=1000
-ARG HOST_GID=1000
-
-RUN apt-get update && apt-get install -y \
-    tmux \
-    %s \
-    sudo \
-    locales \
-    && rm -rf /var/lib/apt/lists/* \
-    && locale-gen en_US.UTF-8
-
-ENV LANG=en_US.UTF-8 LC_ALL=en_US.UTF-8 TERM=xterm-256color
-
-# Install Node.js 22 from NodeSource (Ubuntu's nodejs is too old)
-RUN curl -fsSL https://deb.nodesource.com/setup_22.x | bash - && \
-    apt-get install -y nodejs=22.* && \
-    rm -rf /var/lib/apt/lists/*
-%s
-RUN npm install -g @anthropic-ai/claude-code
-
-# Non-root user with host UID/GID so bind-mounted files are writable
-# If UID/GID already exist (e.g. ubuntu user), take them over
-RUN existing_user=$(getent passwd $HOST_UID | cut -d: -f1) && \
-    if [ -n "$existing_user" ] && [ "$existing_user" != "sandcastle" ]; then \
-        usermod -l sandcastle -d /home/sandcastle -m "$existing_user" && \
-        existing_group=$(getent group $HOST_GID | cut -d: -f1) && \
-        if [ -n "$existing_group" ] && [ "$existing_group" != "sandcastle" ]; then \
-            groupmod -n sandcastle "$existing_group"; \
-        fi; \
-    else \
-        groupadd -g $HOST_GID sandcastle 2>/dev/null; \
-        useradd -m -s /bin/bash -u $HOST_UID -g $HOST_GID sandcastle; \
-    fi && \
-    usermod -aG %s -s /bin/bash sandcastle && \
-    echo 'sandcastle ALL=(ALL) NOPASSWD:ALL' >> /etc/sudoers
-
-# Go tools on PATH
-ENV PATH="/home/sandcastle/go/bin:${PATH}"
-
-RUN mkdir -p /workspace && chown sandcastle:sandcastle /workspace
-WORKDIR /workspace
-
-USER sandcastle
-
-RUN echo 'set -g mouse on' > ~/.tmux.conf && \
-    echo 'set -g status-style "bg=#1a1a2e,fg=#FFD700"' >> ~/.tmux.conf && \
-    echo 'set -g status-left " sandcastle "' >> ~/.tmux.conf && \
-    echo 'set -g status-right " %%H:%%M "' >> ~/.tmux.conf
-
-CMD ["sleep", "infinity"]
-`, cfg.Image.Base, packages, dockerCompose, userGroups)
-
-	path := filepath.Join(projectDir, config.Dir, "Dockerfile")
-	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+func transcriptCmd() *cobra.Command {
+	var full bool
+	cmd := &cobra.Command{
+		Use:   "transcript [name]",
+		Short: "Show a sandcastle's agent transcripts, or list all saved sessions",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				mgr.SyncAllTranscripts()
+				sessions := mgr.Transcripts("")
+				if len(sessions) == 0 {
+					fmt.Println("No transcripts saved yet.")
+					return nil
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "SANDCASTLE\tSTARTED\tMESSAGES\tTASK")
+				for _, s := range sessions {
+					task := s.Task
+					if task == "" {
+						task = s.FirstPrompt
+					}
+					if len(task) > 60 {
+						task = task[:57] + "..."
+					}
+					fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.Sandbox, s.Started.Local().Format("2006-01-02 15:04"), s.Messages, task)
+				}
+				return w.Flush()
+			}
+
+			// A destroyed sandcastle's transcripts were saved when it stopped
+			name := args[0]
+			if _, ok := mgr.Get(name); ok {
+				if err := mgr.SyncTranscripts(name); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v (showing the last saved copy)\n", err)
+				}
+			}
+			sessions := mgr.Transcripts(name)
+			if len(sessions) == 0 {
+				return fmt.Errorf("no transcripts for %q", name)
+			}
+			for i, s := range sessions {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("=== %s · %s · %s\n", s.Sandbox, s.Started.Local().Format("2006-01-02 15:04"), s.Path)
+				if s.Task != "" {
+					fmt.Printf("Task: %s\n", s.Task)
+				}
+				f, err := os.Open(mgr.TranscriptPath(s))
+				if err != nil {
+					return err
+				}
+				err = transcript.Render(os.Stdout, f, transcript.Options{Full: full})
+				f.Close()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&full, "full", false, "show tool results in full instead of truncating them")
+	return cmd
+}
+
+func usageCmd() *cobra.Command {
+	var asJSON bool
+	var since, until string
+	cmd := &cobra.Command{
+		Use:   "usage [name]",
+		Short: "Show agent token usage and estimated cost per sandcastle and task",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+			var filter sandbox.UsageFilter
+			if len(args) == 1 {
+				filter.Sandbox = args[0]
+			}
+			now := time.Now()
+			if since != "" {
+				if filter.Since, err = usage.ParseTime(since, now, false); err != nil {
+					return err
+				}
+			}
+			if until != "" {
+				if filter.Until, err = usage.ParseTime(until, now, true); err != nil {
+					return err
+				}
+			}
+
+			mgr.SyncAllTranscripts()
+			tasks := mgr.Usage(filter)
+			var total usage.Totals
+			for _, t := range tasks {
+				total.Merge(t.Totals)
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(struct {
+					Tasks []sandbox.TaskUsage `json:"tasks"`
+					Total usage.Totals        `json:"total"`
+				}{tasks, total})
+			}
+
+			if len(tasks) == 0 {
+				fmt.Println("No usage recorded.")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SANDCASTLE\tTASK\tSESSIONS\tINPUT\tOUTPUT\tCACHE WRITE\tCACHE READ\tCOST")
+			row := func(name, task, sessions string, t usage.Totals) {
+				if len(task) > 40 {
+					task = task[:37] + "..."
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, task, sessions,
+					usage.FormatTokens(t.Input), usage.FormatTokens(t.Output),
+					usage.FormatTokens(t.CacheWrite), usage.FormatTokens(t.CacheRead), usage.FormatCost(t.Cost))
+			}
+			for _, t := range tasks {
+				row(t.Sandbox, t.Task, fmt.Sprint(t.Sessions), t.Totals)
+			}
+			row("TOTAL", "", "", total)
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if len(total.Unpriced) > 0 {
+				fmt.Fprintf(os.Stderr, "\nNo price for %s; add it under pricing in config.yaml to include it in the cost.\n",
+					strings.Join(total.Unpriced, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the usage as JSON")
+	cmd.Flags().StringVar(&since, "since", "", "only count usage from this time on (YYYY-MM-DD, RFC 3339, or an age like 7d)")
+	cmd.Flags().StringVar(&until, "until", "", "only count usage before this time (a date includes the whole day)")
+	return cmd
+}
+
+func poolCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pool",
+		Short: "Show the pool of pre-started containers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+			status := mgr.Pool()
+			fmt.Printf("%d ready, %d stale (pool_size: %d)\n", status.Ready, status.Stale, status.Size)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "fill",
+		Short: "Start containers until the pool is full, replacing stale ones",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+			started, err := mgr.FillPool()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Pooled containers started: %d\n", started)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "drain",
+		Short: "Remove all idle pooled containers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+			removed := mgr.DrainPool()
+			fmt.Printf("Pooled containers removed: %d\n", removed)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// loadManager loads the project in the current directory for CLI commands.
+func loadManager() (*sandbox.Manager, error) {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load(projectDir)
+	if err != nil {
+		return nil, fmt.Errorf("not a sandcastles project (run `sc init` first): %w", err)
+	}
+	return sandbox.NewManager(projectDir, cfg), nil
+}
+
+// watchCredentialsCmd keeps pushing rotated host credentials to running
+// sandcastles while no dashboard is open. It is started in the background
+// by startCredentialWatcher and exits once no sandcastle is running.
+func watchCredentialsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "watch-credentials",
+		Short:  "Sync rotated host credentials to running sandcastles (started in the background)",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mgr, err := loadManager()
+			if err != nil {
+				return err
+			}
+			logger := log.New(os.Stdout, "", log.LstdFlags)
+			return mgr.WatchCredentialsUntilIdle(func(s sandbox.CredentialSync) {
+				if len(s.Synced) > 0 {
+					logger.Printf("synced credentials to %s", strings.Join(s.Synced, ", "))
+				}
+				for name, err := range s.Errors {
+					logger.Printf("syncing credentials to %s failed: %v", name, err)
+				}
+			})
+		},
+	}
+}
+
+// startCredentialWatcher runs `sc watch-credentials` detached from the
+// terminal, logging to .sandcastles/logs/credentials.log. A watcher that is
+// already running keeps going and the new one exits.
+func startCredentialWatcher(projectDir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(projectDir, config.Dir, config.LogDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(dir, "credentials.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, "watch-credentials")
+	cmd.Dir = projectDir
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// egressProxyCmd runs the allowlist proxy. It is started inside the
+// per-project proxy container, not by users directly.
+func egressProxyCmd() *cobra.Command {
+	var listen, allow, connectPorts, httpPorts, logPath string
+	cmd := &cobra.Command{
+		Use:    "egress-proxy",
+		Short:  "Run the egress allowlist proxy (used inside the proxy container)",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var out io.Writer = os.Stdout
+			if logPath != "" {
+				f, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+				if err != nil {
+					return fmt.Errorf("opening log: %w", err)
+				}
+				defer f.Close()
+				out = io.MultiWriter(os.Stdout, f)
+			}
+			var hosts []string
+			for _, h := range strings.Split(allow, ",") {
+				if h = strings.TrimSpace(h); h != "" {
+					hosts = append(hosts, h)
+				}
+			}
+			proxy := egress.NewProxy(hosts, log.New(out, "", log.LstdFlags))
+			if connectPorts != "" {
+				proxy.ConnectPorts = nil
+				for _, p := range strings.Split(connectPorts, ",") {
+					port, err := strconv.Atoi(strings.TrimSpace(p))
+					if err != nil {
+						return fmt.Errorf("invalid connect port %q", p)
+					}
+					proxy.ConnectPorts = append(proxy.ConnectPorts, port)
+				}
+			}
+			if httpPorts != "" {
+				proxy.HTTPPorts = nil
+				for _, p := range strings.Split(httpPorts, ",") {
+					port, err := strconv.Atoi(strings.TrimSpace(p))
+					if err != nil {
+						return fmt.Errorf("invalid http port %q", p)
+					}
+					proxy.HTTPPorts = append(proxy.HTTPPorts, port)
+				}
+			}
+			return http.ListenAndServe(listen, proxy)
+		},
+	}
+	cmd.Flags().StringVar(&listen, "listen", ":3128", "address to listen on")
+	cmd.Flags().StringVar(&allow, "allow", "", "comma-separated list of allowed hosts")
+	cmd.Flags().StringVar(&connectPorts, "connect-ports", "", "comma-separated ports CONNECT may tunnel to (default 443)")
+	cmd.Flags().StringVar(&httpPorts, "http-ports", "", "comma-separated ports plain HTTP requests may go to (default 80)")
+	cmd.Flags().StringVar(&logPath, "log", "", "file to append denied requests to")
+	return cmd
+}
+
+// writeDockerfile renders the project's Dockerfile from its template,
+// keeping the custom section of an existing Dockerfile.
+func writeDockerfile(projectDir string, cfg *config.Config) error {
+	content, err := sandbox.RenderDockerfile(projectDir, cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(projectDir, cfg.Image.Dockerfile), content, 0o644)
 }
 
 func updateGitignore(projectDir string) error {
@@ -248,6 +788,9 @@ func updateGitignore(projectDir string) error {
 		".sandcastles/worktrees/",
 		".sandcastles/state.json",
 		".sandcastles/.warm-hash",
+		".sandcastles/logs/",
+		".sandcastles/pool/",
+		".sandcastles/transcripts/",
 	}
 
 	existing, _ := os.ReadFile(gitignorePath)
@@ -294,5 +837,10 @@ func runTUI(cmd *cobra.Command, args []string) error {
 		fmt.Fprintf(os.Stderr, "Warning: state reconciliation failed: %v\n", err)
 	}
 
-	return tui.Run(mgr, cfg)
+	err = tui.Run(mgr, cfg)
+	// Take over credential sync for sandcastles left running
+	if werr := startCredentialWatcher(projectDir); werr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: credentials won't sync automatically: %v\n", werr)
+	}
+	return err
 }
@@ -3,23 +3,34 @@ package agent
 import (
 	"fmt"
 	"os/exec"
+	"strings"
 	"time"
+
+	"github.com/zpdzap/sandcastles/internal/shell"
 )
 
+// Options are per-sandbox settings for the agent.
+type Options struct {
+	Model string // passed to claude --model; empty for its default
+}
+
 // Start launches Claude Code inside a sandbox's tmux session using send-keys.
 // Wraps claude with claude-chill to prevent terminal flicker from large redraws.
 // If task is provided, passes it as the initial prompt. Otherwise starts Claude interactively.
 // This is non-fatal — if it fails, the container is still usable manually.
-func Start(containerName, task string) error {
+func Start(containerName, task string, opts Options) error {
 	// Brief pause to let the tmux session fully initialize
 	time.Sleep(500 * time.Millisecond)
 
 	// Use tmux send-keys to type the claude command into the session.
 	// claude-chill is a PTY proxy that intercepts Claude's massive sync block
 	// redraws and sends only diffs, eliminating flicker in tmux.
-	claudeCmd := "claude-chill claude"
+	claudeCmd := "claude-chill -- claude"
+	if opts.Model != "" {
+		claudeCmd += " --model " + shell.Quote(opts.Model)
+	}
 	if task != "" {
-		claudeCmd = fmt.Sprintf("claude-chill -- claude %q", task)
+		claudeCmd += " " + shell.Quote(task)
 	}
 
 	cmd := exec.Command("docker", "exec", containerName,
@@ -31,3 +42,33 @@ func Start(containerName, task string) error {
 	}
 	return nil
 }
+
+// Send types a follow-up prompt into the agent's tmux pane and submits it.
+// The text is sent literally (send-keys -l, so words like "Enter" aren't
+// key names) inside bracketed-paste markers, so a multi-line prompt arrives
+// as one message instead of being submitted at its first newline.
+func Send(containerName, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("empty prompt")
+	}
+	// Drop escape characters so the text can't end the paste early
+	text = strings.ReplaceAll(text, "\x1b", "")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	paste := exec.Command("docker", "exec", containerName,
+		"tmux", "send-keys", "-t", "main", "-l", "\x1b[200~"+text+"\x1b[201~")
+	if out, err := paste.CombinedOutput(); err != nil {
+		return fmt.Errorf("send failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	// Give the agent a moment to take in the paste before submitting it
+	time.Sleep(150 * time.Millisecond)
+
+	submit := exec.Command("docker", "exec", containerName,
+		"tmux", "send-keys", "-t", "main", "Enter")
+	if out, err := submit.CombinedOutput(); err != nil {
+		return fmt.Errorf("send failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Prompt is a selection prompt the agent is blocked on, such as a
+// permission request ("Do you want to proceed?") or a question with
+// numbered answers, parsed from the visible pane.
+type Prompt struct {
+	Question string   // line directly above the options
+	Details  []string // context above the question, e.g. the command to approve
+	Options  []Option
+	Selected int // index into Options of the highlighted option
+}
+
+// Option is one numbered answer of a Prompt.
+type Option struct {
+	Key   string // digit that picks the option
+	Label string
+}
+
+// promptOptionRe matches a numbered option line, with an optional
+// selection marker: "❯ 1. Yes" or "  2. No, and tell Claude what to do".
+var promptOptionRe = regexp.MustCompile(`^(❯|>)?\s*(\d)\.\s+(.+)$`)
+
+// boxChars are the border characters Claude Code draws around prompts.
+const boxChars = "│╭╮╰╯─┃┏┓┗┛━"
+
+// maxPromptDetails caps how many context lines are kept above a question.
+const maxPromptDetails = 6
+
+// ParsePrompt finds a selection prompt at the bottom of captured pane
+// output. It looks for a run of options numbered from 1, one of them marked
+// as selected, and takes the line above them as the question. Returns nil
+// if the pane doesn't end in a prompt.
+func ParsePrompt(pane string) *Prompt {
+	raw := strings.Split(strings.TrimRight(pane, "\n"), "\n")
+	lines := make([]string, len(raw))
+	border := make([]bool, len(raw))
+	for i, line := range raw {
+		line = strings.TrimSpace(line)
+		lines[i] = strings.TrimSpace(strings.Trim(line, boxChars))
+		border[i] = lines[i] == "" && strings.ContainsAny(line, "─━")
+	}
+
+	// The options must be near the bottom; anything below them is a footer
+	// ("Enter to select · Esc to cancel") or border.
+	last := -1
+	for i := len(lines) - 1; i >= 0 && i >= len(lines)-8; i-- {
+		if promptOptionRe.MatchString(lines[i]) {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+
+	// Walk up collecting options; description lines between options are skipped.
+	var options []Option
+	selectedKey := ""
+	first := last
+	for i := last; i >= 0 && i >= last-20; i-- {
+		match := promptOptionRe.FindStringSubmatch(lines[i])
+		if match == nil {
+			if lines[i] == "" {
+				break
+			}
+			continue
+		}
+		options = append([]Option{{Key: match[2], Label: match[3]}}, options...)
+		if match[1] != "" {
+			selectedKey = match[2]
+		}
+		first = i
+		if match[2] == "1" {
+			break
+		}
+	}
+	if len(options) < 2 || selectedKey == "" {
+		return nil
+	}
+	p := &Prompt{Options: options}
+	for i, opt := range options {
+		if opt.Key != strconv.Itoa(i+1) {
+			return nil
+		}
+		if opt.Key == selectedKey {
+			p.Selected = i
+		}
+	}
+
+	i := first - 1
+	for i >= 0 && lines[i] == "" && !border[i] {
+		i--
+	}
+	if i < 0 || border[i] {
+		return p
+	}
+	p.Question = lines[i]
+
+	// Context runs up to the prompt's top border or a blank gap
+	blank := 0
+	for i--; i >= 0 && !border[i] && len(p.Details) < maxPromptDetails; i-- {
+		if lines[i] == "" {
+			blank++
+			if blank > 1 {
+				break
+			}
+			continue
+		}
+		blank = 0
+		p.Details = append([]string{lines[i]}, p.Details...)
+	}
+	return p
+}
+
+// ErrPromptChanged is returned by Answer when the pane no longer shows the
+// prompt being answered, e.g. because the agent moved on to another one.
+var ErrPromptChanged = errors.New("prompt changed since it was shown")
+
+// Same reports whether two prompts ask the same thing: the same question,
+// context and options. The highlighted option may differ.
+func (p *Prompt) Same(other *Prompt) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	return p.Question == other.Question &&
+		slices.Equal(p.Details, other.Details) &&
+		slices.Equal(p.Options, other.Options)
+}
+
+// Capture returns the visible text of the agent's pane plus a little
+// scrollback.
+func Capture(containerName string) (string, error) {
+	out, err := exec.Command("docker", "exec", containerName,
+		"tmux", "capture-pane", "-t", "main", "-p", "-S", "-30").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("capture failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return string(out), nil
+}
+
+// Answer picks a prompt option by pressing its digit in the agent's pane.
+// The pane is captured again first, and nothing is sent unless it still
+// shows the prompt the answer was chosen for (see ErrPromptChanged).
+func Answer(containerName string, shown *Prompt, key string) error {
+	if len(key) != 1 || key[0] < '1' || key[0] > '9' {
+		return fmt.Errorf("invalid option %q", key)
+	}
+	pane, err := Capture(containerName)
+	if err != nil {
+		return err
+	}
+	if !shown.Same(ParsePrompt(pane)) {
+		return ErrPromptChanged
+	}
+	cmd := exec.Command("docker", "exec", containerName,
+		"tmux", "send-keys", "-t", "main", "-l", key)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("answer failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
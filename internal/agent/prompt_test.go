@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePromptPermission(t *testing.T) {
+	pane := `● I'll clean the build directory first.
+
+╭──────────────────────────────────────────────────────╮
+│ Bash command                                         │
+│                                                      │
+│   rm -rf build                                       │
+│   Remove build directory                             │
+│                                                      │
+│ Do you want to proceed?                              │
+│ ❯ 1. Yes                                             │
+│   2. Yes, and don't ask again for rm commands        │
+│   3. No, and tell Claude what to do differently (esc)│
+╰──────────────────────────────────────────────────────╯
+`
+	p := ParsePrompt(pane)
+	if p == nil {
+		t.Fatal("ParsePrompt() = nil, want a prompt")
+	}
+	if p.Question != "Do you want to proceed?" {
+		t.Errorf("Question = %q", p.Question)
+	}
+	wantOptions := []Option{
+		{"1", "Yes"},
+		{"2", "Yes, and don't ask again for rm commands"},
+		{"3", "No, and tell Claude what to do differently (esc)"},
+	}
+	if !reflect.DeepEqual(p.Options, wantOptions) {
+		t.Errorf("Options = %v, want %v", p.Options, wantOptions)
+	}
+	if p.Selected != 0 {
+		t.Errorf("Selected = %d, want 0", p.Selected)
+	}
+	if want := []string{"Bash command", "rm -rf build", "Remove build directory"}; !reflect.DeepEqual(p.Details, want) {
+		t.Errorf("Details = %q, want %q", p.Details, want)
+	}
+}
+
+func TestParsePromptQuestion(t *testing.T) {
+	pane := `
+ Which database should the tests use?
+
+   1. SQLite
+      Fast, in-process
+ ❯ 2. Postgres
+      Matches production
+   3. Type something.
+
+ Enter to select · ↑/↓ to navigate · Esc to cancel
+`
+	p := ParsePrompt(pane)
+	if p == nil {
+		t.Fatal("ParsePrompt() = nil, want a prompt")
+	}
+	if p.Question != "Which database should the tests use?" {
+		t.Errorf("Question = %q", p.Question)
+	}
+	if len(p.Options) != 3 || p.Options[1].Label != "Postgres" {
+		t.Errorf("Options = %v", p.Options)
+	}
+	if p.Selected != 1 {
+		t.Errorf("Selected = %d, want 1", p.Selected)
+	}
+}
+
+func TestParsePromptNone(t *testing.T) {
+	for name, pane := range map[string]string{
+		"plain output":                    "Running tests...\nok  ./...\n",
+		"numbered list without selection": "Plan:\n1. Add the handler\n2. Write tests\n\n> ",
+		"shell":                           "sandcastle@abc:/workspace$ ",
+	} {
+		if p := ParsePrompt(pane); p != nil {
+			t.Errorf("%s: ParsePrompt() = %+v, want nil", name, p)
+		}
+	}
+}
+
+func TestPromptSame(t *testing.T) {
+	shown := ParsePrompt(permissionPane("npm test", 0))
+	for _, tc := range []struct {
+		name string
+		pane string
+		want bool
+	}{
+		{"unchanged", permissionPane("npm test", 0), true},
+		{"selection moved", permissionPane("npm test", 1), true},
+		{"different command", permissionPane("rm -rf build", 0), false},
+		{"no prompt", "sandcastle@abc:/workspace$ ", false},
+	} {
+		if got := shown.Same(ParsePrompt(tc.pane)); got != tc.want {
+			t.Errorf("%s: Same() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// permissionPane renders a Bash permission prompt for command with the
+// given option highlighted.
+func permissionPane(command string, selected int) string {
+	options := []string{"1. Yes", "2. No, and tell Claude what to do differently"}
+	options[selected] = "❯ " + options[selected]
+	return "Bash command\n\n  " + command + "\n\nDo you want to proceed?\n" +
+		strings.Join(options, "\n") + "\n"
+}
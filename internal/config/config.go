@@ -2,31 +2,135 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/zpdzap/sandcastles/internal/devcontainer"
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	Dir        = ".sandcastles"
-	ConfigFile = "config.yaml"
-	StateFile  = "state.json"
-	WorktreeDir = "worktrees"
+	Dir           = ".sandcastles"
+	ConfigFile    = "config.yaml"
+	StateFile     = "state.json"
+	WorktreeDir   = "worktrees"
+	LogDir        = "logs"
+	PoolDir       = "pool"
+	TranscriptDir = "transcripts"
+	TasksDir      = "tasks"
 )
 
 type Config struct {
-	Version  string   `yaml:"version"`
-	Project  string   `yaml:"project"`
-	Language string   `yaml:"language"`
-	Image    Image    `yaml:"image"`
-	Defaults Defaults `yaml:"defaults"`
+	Version  string             `yaml:"version"`
+	Project  string             `yaml:"project"`
+	Language Languages          `yaml:"language"`
+	Image    Image              `yaml:"image"`
+	Defaults Defaults           `yaml:"defaults"`
+	Services map[string]Service `yaml:"services,omitempty"`
+	Notify   []NotifyRule       `yaml:"notify,omitempty"`
+	// Pricing overrides or extends the built-in model price table used for
+	// cost estimates, keyed by model name prefix (e.g. "claude-sonnet-4").
+	Pricing map[string]ModelPrice `yaml:"pricing,omitempty"`
+}
+
+// ModelPrice is what a model costs in USD per million tokens.
+type ModelPrice struct {
+	Input      float64 `yaml:"input" json:"input"`
+	Output     float64 `yaml:"output" json:"output"`
+	CacheWrite float64 `yaml:"cache_write" json:"cache_write"`
+	CacheRead  float64 `yaml:"cache_read" json:"cache_read"`
+}
+
+// NotifyRule sends notifications when an agent enters one of the On states
+// (working, waiting or done). Any combination of channels can be enabled.
+type NotifyRule struct {
+	On      []string `yaml:"on"`
+	Bell    bool     `yaml:"bell,omitempty"`    // terminal bell plus an OSC 9 notification
+	Desktop bool     `yaml:"desktop,omitempty"` // notify-send
+	Command string   `yaml:"command,omitempty"` // run with sh -c; SC_SANDBOX, SC_STATE, SC_PREV_STATE are set
+	Webhook string   `yaml:"webhook,omitempty"` // URL the event is POSTed to as JSON
+}
+
+// AgentStates are the states the dashboard detects for an agent.
+var AgentStates = []string{"working", "waiting", "done"}
+
+// Validate checks a notify rule's states and webhook URL.
+func (r NotifyRule) Validate() error {
+	if len(r.On) == 0 {
+		return fmt.Errorf("notify rule needs at least one state in on")
+	}
+	for _, state := range r.On {
+		if !slices.Contains(AgentStates, state) {
+			return fmt.Errorf("unknown notify state %q (want working, waiting, or done)", state)
+		}
+	}
+	if r.Webhook != "" {
+		u, err := url.Parse(r.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notify webhook %q must be an http(s) URL", r.Webhook)
+		}
+	}
+	return nil
+}
+
+// Service is a sidecar container (database, cache, ...) started alongside
+// each sandbox on a private network, reachable by its name.
+type Service struct {
+	Image       string            `yaml:"image"`
+	Env         map[string]string `yaml:"env,omitempty"`
+	Ports       []int             `yaml:"ports,omitempty"`       // published to random host ports
+	Healthcheck string            `yaml:"healthcheck,omitempty"` // shell command; creation waits until it passes
+}
+
+// Languages lists the ecosystems in a project. In YAML it is a single
+// string for one language (as older configs have it) or a list.
+type Languages []string
+
+func (l *Languages) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var s string
+		if err := value.Decode(&s); err != nil {
+			return err
+		}
+		*l = Languages{s}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+func (l Languages) MarshalYAML() (any, error) {
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return []string(l), nil
+}
+
+// String joins the languages for display, e.g. "go, node".
+func (l Languages) String() string {
+	if len(l) == 0 {
+		return "unknown"
+	}
+	return strings.Join(l, ", ")
 }
 
 type Image struct {
 	Base       string   `yaml:"base"`
 	Dockerfile string   `yaml:"dockerfile"`
 	Packages   []string `yaml:"packages"`
+	// Template names the Dockerfile template (see `sc init --template`);
+	// empty means the default ubuntu template.
+	Template string `yaml:"template,omitempty"`
+	// Devcontainer is the path (relative to the project) of a devcontainer.json
+	// whose image, features, env, ports and postCreateCommand are honored.
+	Devcontainer string `yaml:"devcontainer,omitempty"`
 }
 
 type Defaults struct {
@@ -38,13 +142,180 @@ type Defaults struct {
 	DockerSocket bool              `yaml:"docker_socket,omitempty"`
 	Setup        []string          `yaml:"setup,omitempty"`
 	ClaudeEnv    bool              `yaml:"claude_env,omitempty"`
+	Egress       Egress            `yaml:"egress,omitempty"`
+	Secrets      []Secret          `yaml:"secrets,omitempty"`
+	Git          Git               `yaml:"git,omitempty"`
+	// Caches are extra named volumes shared by all sandcastles, in addition
+	// to the per-language package manager caches.
+	Caches []Cache `yaml:"caches,omitempty"`
+	// WarmManifests are extra globs whose files invalidate the warm image
+	// when they change. Patterns without a slash match file names anywhere
+	// in the project (see FindFiles); others match paths from the root.
+	WarmManifests []string `yaml:"warm_manifests,omitempty"`
+	// PoolSize is how many idle, pre-configured containers to keep running
+	// so /start only has to create the worktree and launch the agent.
+	PoolSize int `yaml:"pool_size,omitempty"`
+	// Verify are shell commands (tests, linters) run in /workspace when an
+	// agent finishes; the sandcastle passes if every one exits 0.
+	Verify []string `yaml:"verify,omitempty"`
+	// MaxIterations is how many times failing verify output is sent back to
+	// the agent as a new prompt before the sandcastle is flagged for a human.
+	// 0 turns the feedback loop off.
+	MaxIterations int `yaml:"max_iterations,omitempty"`
+}
+
+// Cache is a Docker volume mounted at Path in every sandcastle. The volume
+// is named sc-<project>-<name>; a leading ~ in Path is the sandcastle home.
+type Cache struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+}
+
+// Git controls the identity and credentials git uses inside containers.
+// By default commits use the host's user.name/user.email. Setting Name and
+// Email gives the agent its own identity, and its commits get a
+// Co-authored-by trailer crediting the host user.
+type Git struct {
+	Name     string `yaml:"name,omitempty"`
+	Email    string `yaml:"email,omitempty"`
+	SSHAgent bool   `yaml:"ssh_agent,omitempty"` // forward the host's SSH_AUTH_SOCK into containers
+}
+
+// Secret is an environment variable or file whose value is read on the host
+// when a sandbox starts, so it never has to be written into config.yaml.
+// With no source set, the host environment variable of the same name is used.
+type Secret struct {
+	Name       string `yaml:"name"`                  // variable name, or file name under /run/secrets with as_file
+	FromEnv    string `yaml:"from_env,omitempty"`    // host environment variable
+	FromDotenv string `yaml:"from_dotenv,omitempty"` // .env file (relative to the project) containing Name
+	FromFile   string `yaml:"from_file,omitempty"`   // file under ~/.config/sandcastles/secrets, or an absolute path
+	AsFile     bool   `yaml:"as_file,omitempty"`     // mount at /run/secrets/<name> instead of setting an env var
+}
+
+// Egress modes.
+const (
+	EgressOpen      = "open"
+	EgressAllowlist = "allowlist"
+	EgressNone      = "none"
+)
+
+// Egress restricts outbound network access from sandbox containers.
+type Egress struct {
+	Mode         string   `yaml:"mode,omitempty"`          // "open" (default), "allowlist", or "none"
+	Allow        []string `yaml:"allow,omitempty"`         // extra hosts for allowlist mode; "*.example.com" matches subdomains
+	ConnectPorts []int    `yaml:"connect_ports,omitempty"` // extra ports HTTPS tunnels may use besides 443
+	HTTPPorts    []int    `yaml:"http_ports,omitempty"`    // extra ports plain HTTP requests may use besides 80
 }
 
 // IsHostNetwork returns true if the sandbox should use host networking.
 func (d Defaults) IsHostNetwork() bool { return d.Network == "host" }
 
+// Host-network sandboxes share the host's port space, so each one gets its
+// own offset: slot n (0 to HostPortSlots-1) maps container port p to host
+// port p+HostPortBase+n*HostPortStep.
+const (
+	HostPortBase  = 10000
+	HostPortStep  = 1000
+	HostPortSlots = 40
+)
+
+// ValidatePorts checks that the configured ports are valid TCP ports and,
+// with host networking, low enough to be shifted by HostPortBase.
+func (d Defaults) ValidatePorts() error {
+	limit := 65535
+	if d.IsHostNetwork() {
+		limit -= HostPortBase
+	}
+	for _, port := range d.Ports {
+		if port < 1 || port > limit {
+			if d.IsHostNetwork() {
+				return fmt.Errorf("port %d is out of range: with network: host, ports must be between 1 and %d so they can be shifted by %d", port, limit, HostPortBase)
+			}
+			return fmt.Errorf("port %d is out of range", port)
+		}
+	}
+	return nil
+}
+
+// Restricted returns true if containers should be cut off from the internet
+// (apart from the allowlist proxy, if any).
+func (e Egress) Restricted() bool {
+	return e.Mode == EgressAllowlist || e.Mode == EgressNone
+}
+
+// Validate checks that the egress mode is known.
+func (e Egress) Validate() error {
+	switch e.Mode {
+	case "", EgressOpen, EgressAllowlist, EgressNone:
+	default:
+		return fmt.Errorf("unknown egress mode %q (want open, allowlist, or none)", e.Mode)
+	}
+	for _, port := range e.ConnectPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("egress connect port %d is out of range", port)
+		}
+	}
+	for _, port := range e.HTTPPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("egress http port %d is out of range", port)
+		}
+	}
+	return nil
+}
+
+// Validate checks that a cache has a volume-safe name and an absolute path.
+func (c Cache) Validate() error {
+	if c.Name == "" || strings.Trim(c.Name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_.-") != "" {
+		return fmt.Errorf("cache name %q must be non-empty and use only letters, digits, '_', '.' and '-'", c.Name)
+	}
+	if !strings.HasPrefix(c.Path, "/") && !strings.HasPrefix(c.Path, "~/") {
+		return fmt.Errorf("cache %q path %q must be absolute or start with ~/", c.Name, c.Path)
+	}
+	return nil
+}
+
+// Validate checks that a service has an image and a name usable in its
+// container name (sc-<sandbox>_<name>) and as a DNS alias: lowercase letters,
+// digits and '-', not starting or ending with '-'.
+func (s Service) Validate(name string) error {
+	if name == "" || len(name) > 63 || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" ||
+		strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+		return fmt.Errorf("service name %q must use only lowercase letters, digits and '-' (not at the start or end)", name)
+	}
+	if s.Image == "" {
+		return fmt.Errorf("service %q has no image", name)
+	}
+	return nil
+}
+
+// ContainerPath returns Path with a leading ~ expanded to the sandcastle home.
+func (c Cache) ContainerPath() string {
+	if rest, ok := strings.CutPrefix(c.Path, "~/"); ok {
+		return "/home/sandcastle/" + rest
+	}
+	return c.Path
+}
+
 // Load reads config from .sandcastles/config.yaml relative to projectDir.
 func Load(projectDir string) (*Config, error) {
+	cfg, err := LoadRaw(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Image.Devcontainer != "" {
+		if err := applyDevcontainer(projectDir, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.Defaults.ValidatePorts(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadRaw reads config.yaml as written, without merging in a devcontainer.
+// Use it when the config will be saved back.
+func LoadRaw(projectDir string) (*Config, error) {
 	path := filepath.Join(projectDir, Dir, ConfigFile)
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -57,6 +328,35 @@ func Load(projectDir string) (*Config, error) {
 	return &cfg, nil
 }
 
+// applyDevcontainer merges the runtime settings of the configured
+// devcontainer.json into cfg. Values in config.yaml take precedence; the
+// devcontainer's postCreateCommand runs before the configured setup.
+func applyDevcontainer(projectDir string, cfg *Config) error {
+	spec, err := devcontainer.Load(filepath.Join(projectDir, cfg.Image.Devcontainer))
+	if err != nil {
+		return err
+	}
+
+	env := spec.Env()
+	if len(env) > 0 && cfg.Defaults.Env == nil {
+		cfg.Defaults.Env = make(map[string]string, len(env))
+	}
+	for k, v := range env {
+		if _, ok := cfg.Defaults.Env[k]; !ok {
+			cfg.Defaults.Env[k] = v
+		}
+	}
+
+	for _, port := range spec.Ports() {
+		if !slices.Contains(cfg.Defaults.Ports, port) {
+			cfg.Defaults.Ports = append(cfg.Defaults.Ports, port)
+		}
+	}
+
+	cfg.Defaults.Setup = append(spec.Setup(), cfg.Defaults.Setup...)
+	return nil
+}
+
 // Save writes config to .sandcastles/config.yaml relative to projectDir.
 func Save(projectDir string, cfg *Config) error {
 	dir := filepath.Join(projectDir, Dir)
@@ -71,6 +371,15 @@ func Save(projectDir string, cfg *Config) error {
 	return os.WriteFile(path, data, 0o644)
 }
 
+// UserDir returns the per-user sandcastles directory (~/.config/sandcastles).
+func UserDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sandcastles"), nil
+}
+
 // ConfigPath returns the path to the config directory.
 func ConfigPath(projectDir string) string {
 	return filepath.Join(projectDir, Dir)
@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestSaveAndLoad(t *testing.T) {
@@ -12,7 +15,7 @@ func TestSaveAndLoad(t *testing.T) {
 	cfg := &Config{
 		Version:  "1",
 		Project:  "test-project",
-		Language: "go",
+		Language: Languages{"go"},
 		Image: Image{
 			Base:       "ubuntu:24.04",
 			Dockerfile: ".sandcastles/Dockerfile",
@@ -38,7 +41,7 @@ func TestSaveAndLoad(t *testing.T) {
 	if loaded.Project != "test-project" {
 		t.Errorf("Project = %q, want %q", loaded.Project, "test-project")
 	}
-	if loaded.Language != "go" {
+	if loaded.Language.String() != "go" {
 		t.Errorf("Language = %q, want %q", loaded.Language, "go")
 	}
 	if len(loaded.Defaults.Ports) != 1 || loaded.Defaults.Ports[0] != 8080 {
@@ -46,6 +49,55 @@ func TestSaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestLoadAppliesDevcontainer(t *testing.T) {
+	dir := t.TempDir()
+	dcDir := filepath.Join(dir, ".devcontainer")
+	if err := os.MkdirAll(dcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	spec := `{
+		"image": "mcr.microsoft.com/devcontainers/go:1",
+		"containerEnv": {"GOFLAGS": "-mod=mod", "APP_ENV": "dev"},
+		"forwardPorts": [8080, 5432],
+		"postCreateCommand": "go mod download"
+	}`
+	if err := os.WriteFile(filepath.Join(dcDir, "devcontainer.json"), []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		Version: "1",
+		Project: "dc",
+		Image:   Image{Devcontainer: ".devcontainer/devcontainer.json"},
+		Defaults: Defaults{
+			Ports: []int{8080},
+			Env:   map[string]string{"APP_ENV": "test"},
+			Setup: []string{"make tools"},
+		},
+	}
+	if err := Save(dir, cfg); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.Defaults.Env["APP_ENV"] != "test" {
+		t.Errorf("APP_ENV = %q, config.yaml should take precedence", loaded.Defaults.Env["APP_ENV"])
+	}
+	if loaded.Defaults.Env["GOFLAGS"] != "-mod=mod" {
+		t.Errorf("GOFLAGS = %q, want -mod=mod", loaded.Defaults.Env["GOFLAGS"])
+	}
+	if len(loaded.Defaults.Ports) != 2 || loaded.Defaults.Ports[1] != 5432 {
+		t.Errorf("Ports = %v, want [8080 5432]", loaded.Defaults.Ports)
+	}
+	wantSetup := []string{"cd /workspace && go mod download", "make tools"}
+	if len(loaded.Defaults.Setup) != 2 || loaded.Defaults.Setup[0] != wantSetup[0] || loaded.Defaults.Setup[1] != wantSetup[1] {
+		t.Errorf("Setup = %q, want %q", loaded.Defaults.Setup, wantSetup)
+	}
+}
+
 func TestExists(t *testing.T) {
 	dir := t.TempDir()
 	if Exists(dir) {
@@ -72,6 +124,12 @@ func TestDetect(t *testing.T) {
 		{"node project", "package.json", "node"},
 		{"python project", "requirements.txt", "python"},
 		{"rust project", "Cargo.toml", "rust"},
+		{"maven project", "pom.xml", "java"},
+		{"gradle project", "build.gradle.kts", "java"},
+		{"ruby project", "Gemfile", "ruby"},
+		{"php project", "composer.json", "php"},
+		{"dotnet project", "App.csproj", "dotnet"},
+		{"elixir project", "mix.exs", "elixir"},
 		{"unknown project", "", "unknown"},
 	}
 
@@ -82,9 +140,197 @@ func TestDetect(t *testing.T) {
 				os.WriteFile(filepath.Join(dir, tt.file), []byte(""), 0o644)
 			}
 			d := Detect(dir)
-			if d.Language != tt.wantLang {
+			if d.Language.String() != tt.wantLang {
 				t.Errorf("Language = %q, want %q", d.Language, tt.wantLang)
 			}
 		})
 	}
 }
+
+func TestDetectPolyglot(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"go.mod",
+		"frontend/package.json",
+		"frontend/packages/ui/package.json", // npm workspace, covered by frontend/
+		"services/ml/requirements.txt",
+		"services/ml/pyproject.toml", // same dir as requirements.txt
+		"node_modules/dep/package.json",
+		"a/b/c/d/package.json", // too deep
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(""), 0o644)
+	}
+
+	d := Detect(dir)
+	if d.Language.String() != "go, node, python" {
+		t.Errorf("Language = %q, want %q", d.Language, "go, node, python")
+	}
+	wantSetup := []string{
+		"cd /workspace/frontend && npm install",
+		"cd /workspace/services/ml && pip install -r requirements.txt",
+	}
+	if len(d.Setup) != len(wantSetup) {
+		t.Fatalf("Setup = %q, want %q", d.Setup, wantSetup)
+	}
+	for i := range wantSetup {
+		if d.Setup[i] != wantSetup[i] {
+			t.Errorf("Setup[%d] = %q, want %q", i, d.Setup[i], wantSetup[i])
+		}
+	}
+	if len(d.Ports) != 3 {
+		t.Errorf("Ports = %v, want one per language", d.Ports)
+	}
+	seen := make(map[string]bool)
+	for _, p := range d.Packages {
+		if seen[p] {
+			t.Errorf("duplicate package %q in %v", p, d.Packages)
+		}
+		seen[p] = true
+	}
+	if !seen["golang-go"] || !seen["npm"] || !seen["python3-pip"] {
+		t.Errorf("Packages = %v, want all ecosystems merged", d.Packages)
+	}
+}
+
+func TestLanguagesYAML(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		lang Languages
+		yaml string
+	}{
+		{Languages{"go"}, "language: go\n"},
+		{Languages{"go", "node"}, "language:\n    - go\n    - node\n"},
+	} {
+		if err := Save(dir, &Config{Language: tt.lang}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, Dir, ConfigFile))
+		if !strings.Contains(string(data), tt.yaml) {
+			t.Errorf("saved config %q does not contain %q", data, tt.yaml)
+		}
+		loaded, err := Load(dir)
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if loaded.Language.String() != tt.lang.String() {
+			t.Errorf("Language = %v, want %v", loaded.Language, tt.lang)
+		}
+	}
+}
+
+func TestDetectDotnetSolution(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"App.sln", "src/Api/Api.csproj", "src/Web/Web.csproj"} {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(""), 0o644)
+	}
+
+	d := Detect(dir)
+	if d.Language.String() != "dotnet" {
+		t.Errorf("Language = %q, want dotnet", d.Language)
+	}
+	// The solution restores at the root; each project restores in its own dir
+	if len(d.Setup) != 3 || d.Setup[0] != "cd /workspace && dotnet restore" {
+		t.Errorf("Setup = %q", d.Setup)
+	}
+}
+
+func TestNotifyRules(t *testing.T) {
+	var cfg Config
+	data := `
+notify:
+  - on: [waiting, done]
+    bell: true
+    webhook: http://localhost:9000/hook
+  - on: [done]
+    command: say done
+`
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(cfg.Notify) != 2 {
+		t.Fatalf("Notify = %+v, want 2 rules", cfg.Notify)
+	}
+	if got := cfg.Notify[0].On; len(got) != 2 || got[0] != "waiting" || got[1] != "done" {
+		t.Errorf("On = %v, want [waiting done]", got)
+	}
+	for _, r := range cfg.Notify {
+		if err := r.Validate(); err != nil {
+			t.Errorf("Validate(%+v): %v", r, err)
+		}
+	}
+
+	for _, bad := range []NotifyRule{
+		{},
+		{On: []string{"idle"}},
+		{On: []string{"done"}, Webhook: "localhost:9000"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v): want error", bad)
+		}
+	}
+}
+
+func TestEgressValidate(t *testing.T) {
+	for _, e := range []Egress{
+		{},
+		{Mode: EgressAllowlist, ConnectPorts: []int{8443}},
+		{Mode: EgressAllowlist, HTTPPorts: []int{8080}},
+	} {
+		if err := e.Validate(); err != nil {
+			t.Errorf("Validate(%+v): %v", e, err)
+		}
+	}
+	for _, bad := range []Egress{
+		{Mode: "strict"},
+		{Mode: EgressAllowlist, ConnectPorts: []int{0}},
+		{Mode: EgressAllowlist, ConnectPorts: []int{70000}},
+		{Mode: EgressAllowlist, HTTPPorts: []int{-1}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v): want error", bad)
+		}
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	for _, d := range []Defaults{
+		{},
+		{Ports: []int{3000, 65535}},
+		{Ports: []int{8080, 65535 - HostPortBase}, Network: "host"},
+	} {
+		if err := d.ValidatePorts(); err != nil {
+			t.Errorf("ValidatePorts(%v, %q): %v", d.Ports, d.Network, err)
+		}
+	}
+	for _, bad := range []Defaults{
+		{Ports: []int{0}},
+		{Ports: []int{70000}},
+		{Ports: []int{3000, 60000}, Network: "host"},
+	} {
+		if err := bad.ValidatePorts(); err == nil {
+			t.Errorf("ValidatePorts(%v, %q): want error", bad.Ports, bad.Network)
+		}
+	}
+}
+
+func TestServiceValidate(t *testing.T) {
+	postgres := Service{Image: "postgres:16"}
+	for _, name := range []string{"db", "redis-cache", "pg16"} {
+		if err := postgres.Validate(name); err != nil {
+			t.Errorf("Validate(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "DB", "my_db", "-db", "db-", "db.local", "a b"} {
+		if err := postgres.Validate(name); err == nil {
+			t.Errorf("Validate(%q): want error", name)
+		}
+	}
+	if err := (Service{}).Validate("db"); err == nil {
+		t.Error("Validate without image: want error")
+	}
+}
@@ -1,51 +1,97 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/devcontainer"
 )
 
 type Detection struct {
-	Language     string
+	Language     Languages
 	Packages     []string
 	Ports        []int
 	DockerSocket bool
 	Setup        []string
 	ClaudeEnv    bool
+	// Devcontainer is the project's devcontainer.json, relative to the project, or "".
+	Devcontainer string
+}
+
+// manifestCheck describes how a manifest file maps to an ecosystem.
+type manifestCheck struct {
+	file     string // file name or glob, e.g. "*.csproj"
+	language string
+	packages []string
+	ports    []int
+	// setup commands run from the manifest's directory
+	setup []string
 }
 
-// Detect inspects the project directory and returns language, suggested packages, and ports.
+// manifestChecks are tried in order; within a directory, only the first
+// manifest of each language counts.
+var manifestChecks = []manifestCheck{
+	{"go.mod", "go", []string{"golang-go", "git", "curl", "make", "lsof"}, []int{8080}, nil},
+	{"package.json", "node", []string{"nodejs", "npm", "git", "curl", "make", "lsof"}, []int{3000}, []string{"npm install"}},
+	{"requirements.txt", "python", []string{"python3", "python3-pip", "git", "curl", "make", "lsof"}, []int{8000}, []string{"pip install -r requirements.txt"}},
+	{"Cargo.toml", "rust", []string{"rustc", "cargo", "git", "curl", "make", "lsof"}, []int{8080}, nil},
+	{"pyproject.toml", "python", []string{"python3", "python3-pip", "git", "curl", "make", "lsof"}, []int{8000}, []string{"pip install -e ."}},
+	{"pom.xml", "java", []string{"default-jdk", "maven", "git", "curl", "make", "lsof"}, []int{8080}, []string{"mvn -q dependency:go-offline"}},
+	{"build.gradle", "java", []string{"default-jdk", "git", "curl", "make", "lsof", "unzip"}, []int{8080}, []string{"./gradlew --quiet dependencies"}},
+	{"build.gradle.kts", "java", []string{"default-jdk", "git", "curl", "make", "lsof", "unzip"}, []int{8080}, []string{"./gradlew --quiet dependencies"}},
+	{"Gemfile", "ruby", []string{"ruby-full", "build-essential", "git", "curl", "make", "lsof"}, []int{3000}, []string{"bundle config set --global path ~/.gem && bundle install"}},
+	{"composer.json", "php", []string{"php-cli", "php-xml", "php-mbstring", "php-curl", "composer", "unzip", "git", "curl", "make", "lsof"}, []int{8000}, []string{"composer install"}},
+	{"*.sln", "dotnet", []string{"dotnet-sdk-8.0", "git", "curl", "make", "lsof"}, []int{5000}, []string{"dotnet restore"}},
+	{"*.csproj", "dotnet", []string{"dotnet-sdk-8.0", "git", "curl", "make", "lsof"}, []int{5000}, []string{"dotnet restore"}},
+	{"*.fsproj", "dotnet", []string{"dotnet-sdk-8.0", "git", "curl", "make", "lsof"}, []int{5000}, []string{"dotnet restore"}},
+	{"mix.exs", "elixir", []string{"elixir", "erlang-dev", "git", "curl", "make", "lsof"}, []int{4000}, []string{"mix local.hex --force && mix local.rebar --force && mix deps.get"}},
+}
+
+// Detect inspects the project tree and returns its languages, suggested
+// packages, ports and setup commands. Every ecosystem found within
+// MaxScanDepth contributes; a manifest nested under another manifest of the
+// same name (e.g. an npm workspace package) is covered by its ancestor.
 func Detect(projectDir string) Detection {
-	checks := []struct {
-		file     string
-		language string
-		packages []string
-		ports    []int
-		setup    []string
-	}{
-		{"go.mod", "go", []string{"golang-go", "git", "curl", "make", "lsof"}, []int{8080}, nil},
-		{"package.json", "node", []string{"nodejs", "npm", "git", "curl", "make", "lsof"}, []int{3000}, []string{"cd /workspace && npm install"}},
-		{"requirements.txt", "python", []string{"python3", "python3-pip", "git", "curl", "make", "lsof"}, []int{8000}, []string{"cd /workspace && pip install -r requirements.txt"}},
-		{"Cargo.toml", "rust", []string{"rustc", "cargo", "git", "curl", "make", "lsof"}, []int{8080}, nil},
-		{"pyproject.toml", "python", []string{"python3", "python3-pip", "git", "curl", "make", "lsof"}, []int{8000}, []string{"cd /workspace && pip install -e ."}},
+	names := make([]string, len(manifestChecks))
+	for i, c := range manifestChecks {
+		names[i] = c.file
 	}
+	found := FindFiles(projectDir, names)
 
 	var det Detection
-	for _, c := range checks {
-		if _, err := os.Stat(filepath.Join(projectDir, c.file)); err == nil {
-			det = Detection{
-				Language: c.language,
-				Packages: c.packages,
-				Ports:    c.ports,
-				Setup:    c.setup,
+	covered := make(map[string]bool) // language + dir already handled
+	for _, c := range manifestChecks {
+		for _, rel := range found {
+			if ok, _ := filepath.Match(c.file, filepath.Base(rel)); !ok || hasAncestor(found, rel, c.file) {
+				continue
+			}
+			dir := filepath.Dir(rel)
+			if covered[c.language+"\x00"+dir] {
+				continue
+			}
+			covered[c.language+"\x00"+dir] = true
+
+			if !slices.Contains(det.Language, c.language) {
+				det.Language = append(det.Language, c.language)
+			}
+			det.Packages = appendUnique(det.Packages, c.packages...)
+			det.Ports = appendUnique(det.Ports, c.ports...)
+			workdir := "/workspace"
+			if dir != "." {
+				workdir = "/workspace/" + filepath.ToSlash(dir)
+			}
+			for _, cmd := range c.setup {
+				det.Setup = append(det.Setup, fmt.Sprintf("cd %s && %s", workdir, cmd))
 			}
-			break
 		}
 	}
 
-	if det.Language == "" {
+	if len(det.Language) == 0 {
 		det = Detection{
-			Language: "unknown",
+			Language: Languages{"unknown"},
 			Packages: []string{"git", "curl", "make", "lsof"},
 			Ports:    nil,
 		}
@@ -73,5 +119,33 @@ func Detect(projectDir string) Detection {
 		}
 	}
 
+	det.Devcontainer = devcontainer.Find(projectDir)
+
 	return det
 }
+
+// hasAncestor reports whether a file matching pattern exists in one of rel's
+// parent directories.
+func hasAncestor(found []string, rel, pattern string) bool {
+	relDir := filepath.Dir(rel)
+	for _, f := range found {
+		if ok, _ := filepath.Match(pattern, filepath.Base(f)); !ok {
+			continue
+		}
+		dir := filepath.Dir(f)
+		if dir != relDir && (dir == "." || strings.HasPrefix(relDir, dir+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique appends the values not already in s.
+func appendUnique[T comparable](s []T, values ...T) []T {
+	for _, v := range values {
+		if !slices.Contains(s, v) {
+			s = append(s, v)
+		}
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MaxScanDepth is how many directory levels below the project root are
+// searched for manifests.
+const MaxScanDepth = 3
+
+// skipDirs are vendored or generated directories never searched for manifests.
+var skipDirs = []string{
+	"node_modules", "bower_components", "vendor", "third_party",
+	"target", "dist", "build", "venv", "__pycache__",
+}
+
+// FindFiles returns the paths, relative to projectDir, of files whose names
+// match one of patterns (see filepath.Match) within MaxScanDepth levels. Hidden and vendored directories are
+// skipped. Results are ordered by depth, then path.
+func FindFiles(projectDir string, patterns []string) []string {
+	var found []string
+	filepath.WalkDir(projectDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, relErr := filepath.Rel(projectDir, path)
+		if relErr != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			if strings.HasPrefix(d.Name(), ".") || slices.Contains(skipDirs, d.Name()) || depth(rel) >= MaxScanDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, p := range patterns {
+			if ok, _ := filepath.Match(p, d.Name()); ok {
+				found = append(found, rel)
+				break
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(found, func(i, j int) bool {
+		if di, dj := depth(found[i]), depth(found[j]); di != dj {
+			return di < dj
+		}
+		return found[i] < found[j]
+	})
+	return found
+}
+
+// depth returns the number of directories in a relative path.
+func depth(rel string) int {
+	return strings.Count(filepath.ToSlash(rel), "/")
+}
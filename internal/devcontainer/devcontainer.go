@@ -0,0 +1,260 @@
+// Package devcontainer reads the subset of devcontainer.json that sandcastles
+// can honor when building its images.
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/shell"
+)
+
+// Spec is a parsed devcontainer.json.
+type Spec struct {
+	Image             string                     `json:"image"`
+	Build             *Build                     `json:"build"`
+	DockerFile        string                     `json:"dockerFile"` // legacy top-level form of build.dockerfile
+	Context           string                     `json:"context"`    // legacy top-level form of build.context
+	Features          map[string]json.RawMessage `json:"features"`
+	PostCreateCommand Command                    `json:"postCreateCommand"`
+	ForwardPorts      []json.RawMessage          `json:"forwardPorts"`
+	ContainerEnv      map[string]string          `json:"containerEnv"`
+
+	// Dir is the directory containing devcontainer.json; build paths are relative to it.
+	Dir string `json:"-"`
+}
+
+// Build is the devcontainer "build" section.
+type Build struct {
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context"`
+	Args       map[string]string `json:"args"`
+}
+
+// Command is a lifecycle command, which devcontainer.json allows as a string,
+// an argv array, or an object of named commands. It is normalized to a list
+// of shell commands.
+type Command []string
+
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		if str != "" {
+			*c = Command{str}
+		}
+		return nil
+	}
+	var argv []string
+	if err := json.Unmarshal(data, &argv); err == nil {
+		if len(argv) > 0 {
+			*c = Command{joinArgv(argv)}
+		}
+		return nil
+	}
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(data, &named); err != nil {
+		return fmt.Errorf("unsupported command format: %s", data)
+	}
+	keys := make([]string, 0, len(named))
+	for k := range named {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var sub Command
+		if err := sub.UnmarshalJSON(named[k]); err != nil {
+			return err
+		}
+		*c = append(*c, sub...)
+	}
+	return nil
+}
+
+// Load reads and parses a devcontainer.json file (JSON with comments).
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading devcontainer: %w", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(stripJSONC(data), &spec); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	spec.Dir = filepath.Dir(path)
+	if spec.Build == nil && spec.DockerFile != "" {
+		spec.Build = &Build{Dockerfile: spec.DockerFile, Context: spec.Context}
+	}
+	if spec.Image == "" && (spec.Build == nil || spec.Build.Dockerfile == "") {
+		return nil, fmt.Errorf("%s has neither an image nor a build.dockerfile", path)
+	}
+	return &spec, nil
+}
+
+// Find returns the devcontainer.json path in a project, or "" if there is none.
+// The result is relative to projectDir.
+func Find(projectDir string) string {
+	for _, p := range []string{
+		filepath.Join(".devcontainer", "devcontainer.json"),
+		".devcontainer.json",
+	} {
+		if _, err := os.Stat(filepath.Join(projectDir, p)); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// DockerfilePath returns the absolute path of the Dockerfile to build, or ""
+// if the spec uses a prebuilt image.
+func (s *Spec) DockerfilePath() string {
+	if s.Build == nil || s.Build.Dockerfile == "" {
+		return ""
+	}
+	return filepath.Join(s.Dir, s.Build.Dockerfile)
+}
+
+// ContextDir returns the absolute build context directory.
+func (s *Spec) ContextDir() string {
+	if s.Build == nil || s.Build.Context == "" {
+		return s.Dir
+	}
+	return filepath.Join(s.Dir, s.Build.Context)
+}
+
+// Ports returns the numeric forwardPorts entries. "host:port" entries refer to
+// other containers and are skipped.
+func (s *Spec) Ports() []int {
+	var ports []int
+	for _, raw := range s.ForwardPorts {
+		var n int
+		if err := json.Unmarshal(raw, &n); err == nil {
+			ports = append(ports, n)
+			continue
+		}
+		var str string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			if n, err := strconv.Atoi(str); err == nil {
+				ports = append(ports, n)
+			}
+		}
+	}
+	return ports
+}
+
+// Env returns containerEnv with ${containerWorkspaceFolder} and ${localEnv:VAR}
+// substituted.
+func (s *Spec) Env() map[string]string {
+	env := make(map[string]string, len(s.ContainerEnv))
+	for k, v := range s.ContainerEnv {
+		env[k] = substitute(v)
+	}
+	return env
+}
+
+// Setup returns postCreateCommand as shell commands run from /workspace.
+func (s *Spec) Setup() []string {
+	var cmds []string
+	for _, c := range s.PostCreateCommand {
+		cmds = append(cmds, "cd /workspace && "+c)
+	}
+	return cmds
+}
+
+// substitute expands the devcontainer variables that make sense outside VS Code.
+func substitute(v string) string {
+	v = strings.ReplaceAll(v, "${containerWorkspaceFolder}", "/workspace")
+	for {
+		start := strings.Index(v, "${localEnv:")
+		if start < 0 {
+			return v
+		}
+		end := strings.Index(v[start:], "}")
+		if end < 0 {
+			return v
+		}
+		name, def, _ := strings.Cut(v[start+len("${localEnv:"):start+end], ":")
+		if val, ok := os.LookupEnv(name); ok {
+			def = val
+		}
+		v = v[:start] + def + v[start+end+1:]
+	}
+}
+
+// joinArgv renders an argv array as a single shell command.
+func joinArgv(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, a := range argv {
+		quoted[i] = shell.Quote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// stripJSONC removes // and /* */ comments and trailing commas so the
+// result parses as plain JSON. String contents are left untouched.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ',':
+			// Drop the comma if the next significant character closes a container
+			j := i + 1
+			for j < len(data) && (data[j] == ' ' || data[j] == '\t' || data[j] == '\n' || data[j] == '\r') {
+				j++
+			}
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				continue
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ImageName returns the tag used for a project's devcontainer base image when
+// the devcontainer is built from a Dockerfile.
+func ImageName(project string) string {
+	return fmt.Sprintf("sc-%s-devcontainer", project)
+}
+
+// BaseImage returns the image the sandcastles layer should be built FROM.
+func (s *Spec) BaseImage(project string) string {
+	if s.DockerfilePath() != "" {
+		return ImageName(project)
+	}
+	return s.Image
+}
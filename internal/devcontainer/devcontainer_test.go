@@ -0,0 +1,128 @@
+package devcontainer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeSpec(t *testing.T, content string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), ".devcontainer")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "devcontainer.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadJSONC(t *testing.T) {
+	path := writeSpec(t, `{
+	// The base image
+	"image": "mcr.microsoft.com/devcontainers/go:1",
+	/* block
+	   comment */
+	"containerEnv": {
+		"URL": "http://example.com/a//b", // not a comment inside the string
+		"WS": "${containerWorkspaceFolder}/bin",
+	},
+	"forwardPorts": [8080, "9090", "db:5432",],
+	"postCreateCommand": "go mod download",
+}`)
+
+	spec, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if spec.Image != "mcr.microsoft.com/devcontainers/go:1" {
+		t.Errorf("Image = %q", spec.Image)
+	}
+	env := spec.Env()
+	if env["URL"] != "http://example.com/a//b" {
+		t.Errorf("URL = %q", env["URL"])
+	}
+	if env["WS"] != "/workspace/bin" {
+		t.Errorf("WS = %q", env["WS"])
+	}
+	if got := spec.Ports(); !reflect.DeepEqual(got, []int{8080, 9090}) {
+		t.Errorf("Ports() = %v", got)
+	}
+	if got := spec.Setup(); !reflect.DeepEqual(got, []string{"cd /workspace && go mod download"}) {
+		t.Errorf("Setup() = %v", got)
+	}
+}
+
+func TestLoadBuild(t *testing.T) {
+	path := writeSpec(t, `{"build": {"dockerfile": "Dockerfile", "context": ".."}}`)
+
+	spec, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	dir := filepath.Dir(path)
+	if got := spec.DockerfilePath(); got != filepath.Join(dir, "Dockerfile") {
+		t.Errorf("DockerfilePath() = %q", got)
+	}
+	if got := spec.ContextDir(); got != filepath.Dir(dir) {
+		t.Errorf("ContextDir() = %q", got)
+	}
+}
+
+func TestLoadRequiresImageOrBuild(t *testing.T) {
+	if _, err := Load(writeSpec(t, `{"forwardPorts": [3000]}`)); err == nil {
+		t.Error("expected error for spec without image or build")
+	}
+}
+
+func TestPostCreateCommandForms(t *testing.T) {
+	tests := []struct {
+		json string
+		want []string
+	}{
+		{`"make setup"`, []string{"cd /workspace && make setup"}},
+		{`["npm", "run", "it's"]`, []string{`cd /workspace && 'npm' 'run' 'it'\''s'`}},
+		{`{"b": "two", "a": ["one"]}`, []string{"cd /workspace && 'one'", "cd /workspace && two"}},
+	}
+	for _, tt := range tests {
+		spec, err := Load(writeSpec(t, `{"image": "x", "postCreateCommand": `+tt.json+`}`))
+		if err != nil {
+			t.Fatalf("Load(%s) error: %v", tt.json, err)
+		}
+		if got := spec.Setup(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Setup() for %s = %q, want %q", tt.json, got, tt.want)
+		}
+	}
+}
+
+func TestResolveFeatures(t *testing.T) {
+	spec, err := Load(writeSpec(t, `{
+	"image": "x",
+	"features": {
+		"ghcr.io/devcontainers/features/node:1": {"version": "20"},
+		"ghcr.io/devcontainers/features/github-cli:1": {},
+		"ghcr.io/devcontainers/features/docker-outside-of-docker:1": {},
+		"ghcr.io/example/custom-thing:2": {}
+	}
+}`))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	c, unsupported := spec.ResolveFeatures()
+	if c.NodeMajor != "20" {
+		t.Errorf("NodeMajor = %q, want 20", c.NodeMajor)
+	}
+	if !c.DockerSocket {
+		t.Error("expected DockerSocket from docker-outside-of-docker")
+	}
+	if !reflect.DeepEqual(c.Packages, []string{"gh"}) {
+		t.Errorf("Packages = %v", c.Packages)
+	}
+	if !reflect.DeepEqual(unsupported, []string{"ghcr.io/example/custom-thing:2"}) {
+		t.Errorf("unsupported = %v", unsupported)
+	}
+}
@@ -0,0 +1,108 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Contribution is what the supported devcontainer features add to the
+// sandcastles image.
+type Contribution struct {
+	Packages     []string
+	DockerSocket bool
+	// NodeMajor is the Node.js major version requested by the node feature, or "".
+	NodeMajor string
+}
+
+// featurePackages maps feature names to the apt packages that provide them.
+var featurePackages = map[string][]string{
+	"common-utils": nil, // covered by the base sandcastles layer
+	"git":          {"git"},
+	"github-cli":   {"gh"},
+	"go":           {"golang-go"},
+	"python":       {"python3", "python3-pip", "python3-venv"},
+	"rust":         {"rustc", "cargo"},
+	"java":         {"default-jdk"},
+	"ruby":         {"ruby-full"},
+	"php":          {"php-cli"},
+	"sshd":         {"openssh-server"},
+	"node":         nil, // installed from NodeSource, see NodeMajor
+}
+
+// ResolveFeatures resolves the spec's features into an image contribution. IDs of
+// features that cannot be reproduced locally are returned separately.
+func (s *Spec) ResolveFeatures() (Contribution, []string) {
+	var c Contribution
+	var unsupported []string
+
+	ids := make([]string, 0, len(s.Features))
+	for id := range s.Features {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		name := featureName(id)
+		switch name {
+		case "docker-in-docker", "docker-outside-of-docker":
+			c.DockerSocket = true
+			continue
+		case "node":
+			c.NodeMajor = nodeMajor(featureVersion(s.Features[id]))
+		}
+		pkgs, ok := featurePackages[name]
+		if !ok {
+			unsupported = append(unsupported, id)
+			continue
+		}
+		c.Packages = append(c.Packages, pkgs...)
+	}
+	return c, unsupported
+}
+
+// featureName reduces a feature reference such as
+// "ghcr.io/devcontainers/features/node:1" to "node".
+func featureName(id string) string {
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	if i := strings.IndexAny(id, ":@"); i >= 0 {
+		id = id[:i]
+	}
+	return id
+}
+
+// featureVersion returns the "version" option of a feature, which may be
+// given either as a bare string or inside an options object.
+func featureVersion(raw json.RawMessage) string {
+	var version string
+	if err := json.Unmarshal(raw, &version); err == nil {
+		return version
+	}
+	var opts struct {
+		Version any `json:"version"`
+	}
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return ""
+	}
+	switch v := opts.Version.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// nodeMajor extracts a numeric major version, ignoring "lts" and "latest".
+func nodeMajor(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	for _, r := range major {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return major
+}
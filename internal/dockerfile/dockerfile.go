@@ -0,0 +1,265 @@
+// Package dockerfile renders the project Dockerfile from named templates.
+//
+// Built-in templates are embedded in the binary. Templates placed in
+// ~/.config/sandcastles/templates/<name>.Dockerfile.tmpl add new names or
+// override built-in ones, and can reuse the built-in partials ("from",
+// "apt-install", "nodesource", "docker-compose", "agent", "user", "custom",
+// "workspace").
+package dockerfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+//go:embed templates/*.tmpl
+var builtinFS embed.FS
+
+// DefaultTemplate is used when the config does not name a template.
+const DefaultTemplate = "ubuntu"
+
+const (
+	templateSuffix = ".Dockerfile.tmpl"
+	partialsFile   = "templates/_partials.tmpl"
+
+	// Lines delimiting the section of a generated Dockerfile that is kept
+	// verbatim when it is re-rendered.
+	customBegin = "# --- BEGIN CUSTOM: kept by `sc init --regen-dockerfile` ---"
+	customEnd   = "# --- END CUSTOM ---"
+
+	// stampPrefix starts the first line of a stamped Dockerfile, see Stamp.
+	stampPrefix = "# sandcastles-generated: "
+)
+
+// aptProvides are packages every apt-based template installs itself (or
+// replaces, in the case of nodejs/npm from NodeSource).
+var aptProvides = []string{"tmux", "curl", "ca-certificates", "gnupg", "openssh-client", "sudo", "locales", "nodejs", "npm"}
+
+// builtins describes the embedded templates.
+var builtins = map[string]struct {
+	base string
+	// provides lists packages the template or its base image already has
+	provides []string
+	// rename maps Debian package names to the template's package manager
+	rename map[string]string
+}{
+	"ubuntu":      {base: "ubuntu:24.04", provides: aptProvides},
+	"debian-slim": {base: "debian:bookworm-slim", provides: aptProvides},
+	"go":          {base: "golang:1.24-bookworm", provides: append(slices.Clone(aptProvides), "golang-go")},
+	"node":        {base: "node:22-bookworm", provides: aptProvides},
+	"python":      {base: "python:3.12-bookworm", provides: append(slices.Clone(aptProvides), "python3", "python3-pip", "python3-venv")},
+	"alpine": {
+		base:     "alpine:3.20",
+		provides: []string{"bash", "tmux", "curl", "ca-certificates", "gnupg", "openssh-client", "sudo", "locales", "nodejs", "npm"},
+		rename: map[string]string{
+			"golang-go":       "go",
+			"python3-pip":     "py3-pip",
+			"python3-venv":    "python3",
+			"rustc":           "rust",
+			"build-essential": "build-base",
+			"default-jdk":     "openjdk21-jdk",
+			"dotnet-sdk-8.0":  "dotnet8-sdk",
+			"php-cli":         "php83",
+			"php-xml":         "php83-xml",
+			"php-mbstring":    "php83-mbstring",
+			"php-curl":        "php83-curl",
+		},
+	},
+}
+
+// Params are the values a template is rendered with.
+type Params struct {
+	// Base is the image to build FROM; empty means the template's default.
+	Base string
+	// BaseArg emits the base as a BASE_IMAGE build argument instead of a
+	// literal FROM, for bases that are resolved at build time.
+	BaseArg      bool
+	Packages     []string
+	NodeMajor    string
+	DockerSocket bool
+	// Custom is the preserved user section, see ExtractCustom.
+	Custom string
+}
+
+// data is what templates see: Params plus the custom section markers.
+type data struct {
+	Params
+	CustomBegin string
+	CustomEnd   string
+}
+
+// Template is a loaded, parsed Dockerfile template.
+type Template struct {
+	Name string
+	// Builtin is false for templates loaded from the user template directory.
+	Builtin bool
+	tmpl    *template.Template
+}
+
+// Load returns the named template, preferring a user template over a
+// built-in one of the same name.
+func Load(name string) (*Template, error) {
+	if name == "" {
+		name = DefaultTemplate
+	}
+	partials, err := builtinFS.ReadFile(partialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	builtin := false
+	if dir, err := userTemplateDir(); err == nil {
+		body, _ = os.ReadFile(filepath.Join(dir, name+templateSuffix))
+	}
+	if body == nil {
+		body, err = builtinFS.ReadFile("templates/" + name + templateSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(Names(), ", "))
+		}
+		builtin = true
+	}
+
+	t, err := template.New(name).Parse(string(partials))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template partials: %w", err)
+	}
+	if _, err := t.Parse(string(body)); err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", name, err)
+	}
+	return &Template{Name: name, Builtin: builtin, tmpl: t}, nil
+}
+
+// Names lists the available template names, built-in and user-defined.
+func Names() []string {
+	seen := make(map[string]bool)
+	for name := range builtins {
+		seen[name] = true
+	}
+	if dir, err := userTemplateDir(); err == nil {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if name, ok := strings.CutSuffix(e.Name(), templateSuffix); ok && !e.IsDir() {
+				seen[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultBase returns the base image the template is designed for.
+func (t *Template) DefaultBase() string {
+	if b, ok := builtins[t.Name]; ok {
+		return b.base
+	}
+	return builtins[DefaultTemplate].base
+}
+
+// Render produces the Dockerfile contents.
+func (t *Template) Render(p Params) ([]byte, error) {
+	if p.Base == "" {
+		p.Base = t.DefaultBase()
+	}
+	if p.NodeMajor == "" {
+		p.NodeMajor = "22"
+	}
+	if p.Custom != "" && !strings.HasSuffix(p.Custom, "\n") {
+		p.Custom += "\n"
+	}
+	p.Packages = t.packages(p.Packages, p.DockerSocket)
+
+	var buf bytes.Buffer
+	err := t.tmpl.ExecuteTemplate(&buf, t.tmpl.Name(), data{Params: p, CustomBegin: customBegin, CustomEnd: customEnd})
+	if err != nil {
+		return nil, fmt.Errorf("rendering template %s: %w", t.Name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// packages translates and de-duplicates the configured packages, dropping
+// the ones the template already installs.
+func (t *Template) packages(pkgs []string, dockerSocket bool) []string {
+	provides := aptProvides
+	var rename map[string]string
+	if b, ok := builtins[t.Name]; ok {
+		provides, rename = b.provides, b.rename
+	}
+	var out []string
+	for _, p := range pkgs {
+		if r, ok := rename[p]; ok {
+			p = r
+		}
+		if slices.Contains(provides, p) || slices.Contains(out, p) {
+			continue
+		}
+		if dockerSocket && p == "docker.io" {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// ExtractCustom returns the user-editable section of a previously generated
+// Dockerfile, and whether the section markers were found.
+func ExtractCustom(content []byte) (string, bool) {
+	s := string(content)
+	start := strings.Index(s, customBegin+"\n")
+	if start < 0 {
+		return "", false
+	}
+	start += len(customBegin) + 1
+	end := strings.Index(s[start:], customEnd)
+	if end < 0 {
+		return "", false
+	}
+	return s[start : start+end], true
+}
+
+// generatedHash hashes a rendered Dockerfile without its custom section.
+func generatedHash(content []byte) string {
+	s := string(content)
+	if custom, ok := ExtractCustom(content); ok && custom != "" {
+		s = strings.Replace(s, customBegin+"\n"+custom, customBegin+"\n", 1)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+// Stamp prepends a line recording the hash of the rendered content outside
+// its custom section, so Pristine can later tell a Dockerfile that is merely
+// stale from one edited by hand.
+func Stamp(content []byte) []byte {
+	return append([]byte(stampPrefix+generatedHash(content)+"\n"), content...)
+}
+
+// Pristine reports whether a stamped Dockerfile is unchanged outside its
+// custom section since it was rendered, i.e. whether it is safe to re-render.
+func Pristine(content []byte) bool {
+	first, rest, ok := bytes.Cut(content, []byte("\n"))
+	stamp, found := strings.CutPrefix(string(first), stampPrefix)
+	return ok && found && stamp == generatedHash(rest)
+}
+
+// userTemplateDir returns ~/.config/sandcastles/templates.
+func userTemplateDir() (string, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
@@ -0,0 +1,187 @@
+package dockerfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTemplatesRender(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	for name := range builtins {
+		tmpl, err := Load(name)
+		if err != nil {
+			t.Fatalf("Load(%q): %v", name, err)
+		}
+		out, err := tmpl.Render(Params{Packages: []string{"git"}})
+		if err != nil {
+			t.Fatalf("Render(%q): %v", name, err)
+		}
+		s := string(out)
+		if !strings.HasPrefix(s, "FROM "+builtins[name].base+"\n") {
+			t.Errorf("%s: unexpected FROM line:\n%s", name, s)
+		}
+		for _, want := range []string{"@anthropic-ai/claude-code", "USER sandcastle", customBegin, customEnd, "    git \\"} {
+			if !strings.Contains(s, want) {
+				t.Errorf("%s: missing %q", name, want)
+			}
+		}
+		if strings.Contains(s, "<no value>") {
+			t.Errorf("%s: unresolved template value:\n%s", name, s)
+		}
+	}
+}
+
+func TestRenderPackages(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tmpl, err := Load("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tmpl.Render(Params{
+		Packages:     []string{"golang-go", "nodejs", "make", "make", "docker.io"},
+		DockerSocket: true,
+		NodeMajor:    "20",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	if strings.Contains(s, "golang-go") || strings.Contains(s, "    nodejs \\") {
+		t.Errorf("packages provided by the template should be dropped:\n%s", s)
+	}
+	if strings.Count(s, "    make \\") != 1 || strings.Count(s, "docker.io") != 1 {
+		t.Errorf("packages should be de-duplicated:\n%s", s)
+	}
+	if !strings.Contains(s, "setup_20.x") {
+		t.Errorf("NodeMajor not applied:\n%s", s)
+	}
+	if !strings.Contains(s, "usermod -aG sudo,docker") {
+		t.Errorf("docker group missing with DockerSocket:\n%s", s)
+	}
+}
+
+func TestRenderBaseArg(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tmpl, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tmpl.Render(Params{Base: "sc-app-devcontainer", BaseArg: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "ARG BASE_IMAGE=sc-app-devcontainer\nFROM ${BASE_IMAGE}\n") {
+		t.Errorf("unexpected header:\n%s", out)
+	}
+}
+
+func TestCustomSectionRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tmpl, err := Load("ubuntu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tmpl.Render(Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, ok := ExtractCustom(out)
+	if !ok || custom != "" {
+		t.Fatalf("ExtractCustom(fresh) = %q, %v; want empty, true", custom, ok)
+	}
+
+	edited := strings.Replace(string(out), customBegin+"\n", customBegin+"\nRUN pip install poetry\n", 1)
+	custom, ok = ExtractCustom([]byte(edited))
+	if !ok || custom != "RUN pip install poetry\n" {
+		t.Fatalf("ExtractCustom(edited) = %q, %v", custom, ok)
+	}
+
+	again, err := tmpl.Render(Params{Custom: custom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != edited {
+		t.Errorf("re-render lost the custom section:\n%s", again)
+	}
+
+	if _, ok := ExtractCustom([]byte("FROM ubuntu\n")); ok {
+		t.Error("ExtractCustom should report missing markers")
+	}
+}
+
+func TestUserTemplate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	dir := filepath.Join(home, "sandcastles", "templates")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	body := "{{template \"from\" .}}\nRUN echo team-image\n{{template \"workspace\" .}}\n"
+	if err := os.WriteFile(filepath.Join(dir, "team"+templateSuffix), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, n := range Names() {
+		if n == "team" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Names() = %v, want team listed", Names())
+	}
+
+	tmpl, err := Load("team")
+	if err != nil {
+		t.Fatalf("Load(team): %v", err)
+	}
+	if tmpl.Builtin {
+		t.Error("user template reported as builtin")
+	}
+	out, err := tmpl.Render(Params{Base: "registry.example.com/base:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "FROM registry.example.com/base:1") || !strings.Contains(string(out), "RUN echo team-image") {
+		t.Errorf("unexpected render:\n%s", out)
+	}
+
+	if _, err := Load("missing"); err == nil || !strings.Contains(err.Error(), "team") {
+		t.Errorf("Load(missing) error = %v, want list of available templates", err)
+	}
+}
+
+func TestStamp(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tmpl, err := Load("ubuntu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tmpl.Render(Params{Base: "sc-app-devcontainer", BaseArg: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamped := string(Stamp(out))
+	if !Pristine([]byte(stamped)) {
+		t.Fatalf("fresh render is not pristine:\n%s", stamped)
+	}
+
+	custom := strings.Replace(stamped, customBegin+"\n", customBegin+"\nRUN pip install poetry\n", 1)
+	if !Pristine([]byte(custom)) {
+		t.Error("editing the custom section should keep the Dockerfile pristine")
+	}
+	edited := strings.Replace(stamped, "tmux", "tmux htop", 1)
+	if Pristine([]byte(edited)) {
+		t.Error("editing outside the custom section should be detected")
+	}
+	if Pristine(out) {
+		t.Error("an unstamped Dockerfile can't be pristine")
+	}
+}
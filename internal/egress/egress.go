@@ -0,0 +1,214 @@
+// Package egress implements the filtering HTTP(S) proxy used to restrict
+// outbound network access from sandbox containers.
+package egress
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAllow lists the hosts an agent needs in allowlist mode: the
+// Anthropic API and the common package registries.
+var DefaultAllow = []string{
+	"api.anthropic.com",
+	"console.anthropic.com",
+	"statsig.anthropic.com",
+	"registry.npmjs.org",
+	"registry.yarnpkg.com",
+	"pypi.org",
+	"files.pythonhosted.org",
+	"proxy.golang.org",
+	"sum.golang.org",
+	"crates.io",
+	"index.crates.io",
+	"static.crates.io",
+	"github.com",
+	"codeload.github.com",
+	"objects.githubusercontent.com",
+}
+
+// DefaultConnectPorts are the ports CONNECT tunnels may open when none are
+// configured: HTTPS only, so an allowed host can't be used to reach its
+// other services.
+var DefaultConnectPorts = []int{443}
+
+// DefaultHTTPPorts are the ports plain HTTP requests may be forwarded to when
+// none are configured.
+var DefaultHTTPPorts = []int{80}
+
+// Allowlist matches hostnames against exact names and "*.domain" wildcards.
+type Allowlist []string
+
+// Allows reports whether host (with or without a port) is on the list.
+func (a Allowlist) Allows(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range a {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Proxy is an HTTP proxy that tunnels CONNECT requests and forwards plain
+// HTTP requests to allowed hosts, and rejects everything else.
+type Proxy struct {
+	Allow        Allowlist
+	ConnectPorts []int // ports CONNECT may tunnel to
+	HTTPPorts    []int // ports plain HTTP requests may be forwarded to
+	Log          *log.Logger
+
+	transport http.RoundTripper
+}
+
+// NewProxy returns a proxy permitting only the given hosts. Denied requests
+// are written to logger.
+func NewProxy(allow []string, logger *log.Logger) *Proxy {
+	return &Proxy{
+		Allow:        Allowlist(allow),
+		ConnectPorts: DefaultConnectPorts,
+		HTTPPorts:    DefaultHTTPPorts,
+		Log:          logger,
+		transport:    &http.Transport{Proxy: nil, DialContext: (&net.Dialer{Timeout: 10 * time.Second}).DialContext},
+	}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if r.Method != http.MethodConnect && r.URL.Host != "" {
+		host = r.URL.Host
+	}
+	if !p.Allow.Allows(host) {
+		p.Log.Printf("denied %s %s from %s", r.Method, host, r.RemoteAddr)
+		http.Error(w, "blocked by sandcastles egress policy", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		if !p.connectPortAllowed(r.Host) {
+			p.Log.Printf("denied %s %s from %s: port not allowed", r.Method, host, r.RemoteAddr)
+			http.Error(w, "blocked by sandcastles egress policy: port not allowed", http.StatusForbidden)
+			return
+		}
+		p.tunnel(w, r)
+		return
+	}
+	if !p.httpPortAllowed(r) {
+		p.Log.Printf("denied %s %s from %s: port not allowed", r.Method, host, r.RemoteAddr)
+		http.Error(w, "blocked by sandcastles egress policy: port not allowed", http.StatusForbidden)
+		return
+	}
+	p.forward(w, r)
+}
+
+// connectPortAllowed reports whether a CONNECT target's port is one tunnels
+// may use. A target without a port is refused.
+func (p *Proxy) connectPortAllowed(hostport string) bool {
+	_, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return false
+	}
+	port, err := strconv.Atoi(portStr)
+	return err == nil && slices.Contains(p.ConnectPorts, port)
+}
+
+// httpPortAllowed reports whether a plain HTTP request's port is one requests
+// may be forwarded to. Only http:// URLs are forwarded; a URL without a port
+// means 80.
+func (p *Proxy) httpPortAllowed(r *http.Request) bool {
+	if r.URL.Scheme != "http" {
+		return false
+	}
+	portStr := r.URL.Port()
+	if portStr == "" {
+		portStr = "80"
+	}
+	port, err := strconv.Atoi(portStr)
+	return err == nil && slices.Contains(p.HTTPPorts, port)
+}
+
+// tunnel handles a CONNECT request by splicing the client and upstream connections.
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		if tc, ok := dst.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+	}
+	go pipe(upstream, client)
+	go pipe(client, upstream)
+	wg.Wait()
+	client.Close()
+	upstream.Close()
+}
+
+// hopHeaders are connection-level headers that must not be forwarded.
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// forward relays a plain HTTP request to its destination.
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
@@ -0,0 +1,131 @@
+package egress
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAllowlist(t *testing.T) {
+	allow := Allowlist{"api.anthropic.com", "*.pythonhosted.org"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"api.anthropic.com", true},
+		{"api.anthropic.com:443", true},
+		{"API.Anthropic.com", true},
+		{"evil.com", false},
+		{"anthropic.com", false},
+		{"files.pythonhosted.org:443", true},
+		{"pythonhosted.org", false},
+		{"api.anthropic.com.evil.com", false},
+	}
+	for _, tt := range tests {
+		if got := allow.Allows(tt.host); got != tt.want {
+			t.Errorf("Allows(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestProxyDeniesAndLogs(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProxy([]string{"api.anthropic.com"}, log.New(&buf, "", 0))
+
+	req := httptest.NewRequest(http.MethodConnect, "http://evil.com:443", nil)
+	req.Host = "evil.com:443"
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if !strings.Contains(buf.String(), "denied CONNECT evil.com:443") {
+		t.Errorf("log = %q, want denied entry", buf.String())
+	}
+}
+
+func TestProxyForwardsAllowed(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	var buf bytes.Buffer
+	p := NewProxy([]string{"127.0.0.1"}, log.New(&buf, "", 0))
+	_, portStr, _ := net.SplitHostPort(host)
+	port, _ := strconv.Atoi(portStr)
+	p.HTTPPorts = []int{port}
+
+	req := httptest.NewRequest(http.MethodGet, upstream.URL+"/", nil)
+	req.Host = host
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("got %d %q, want 200 \"ok\"", rec.Code, rec.Body.String())
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected log output: %q", buf.String())
+	}
+}
+
+func TestProxyConnectPorts(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProxy([]string{"github.com"}, log.New(&buf, "", 0))
+
+	for _, target := range []string{"github.com:22", "github.com:8443", "github.com"} {
+		req := httptest.NewRequest(http.MethodConnect, "http://"+target, nil)
+		req.Host = target
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("CONNECT %s: status = %d, want %d", target, rec.Code, http.StatusForbidden)
+		}
+	}
+	if !strings.Contains(buf.String(), "denied CONNECT github.com:22 from") {
+		t.Errorf("log = %q, want denied entry", buf.String())
+	}
+
+	if !p.connectPortAllowed("github.com:443") {
+		t.Error("port 443 not allowed by default")
+	}
+	p.ConnectPorts = []int{443, 8443}
+	if !p.connectPortAllowed("github.com:8443") {
+		t.Error("configured port 8443 not allowed")
+	}
+}
+
+func TestProxyHTTPPorts(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProxy([]string{"github.com"}, log.New(&buf, "", 0))
+
+	for _, target := range []string{"http://github.com:22/", "http://github.com:8080/", "https://github.com/"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("GET %s: status = %d, want %d", target, rec.Code, http.StatusForbidden)
+		}
+	}
+	if !strings.Contains(buf.String(), "denied GET github.com:22 from") {
+		t.Errorf("log = %q, want denied entry", buf.String())
+	}
+
+	allowed := func(target string) bool {
+		return p.httpPortAllowed(httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	if !allowed("http://github.com/") || !allowed("http://github.com:80/") {
+		t.Error("port 80 not allowed by default")
+	}
+	p.HTTPPorts = []int{80, 8080}
+	if !allowed("http://github.com:8080/") {
+		t.Error("configured port 8080 not allowed")
+	}
+}
@@ -0,0 +1,188 @@
+// Package notify tells the user when an agent changes state: a terminal
+// bell/OSC 9 notification, notify-send, a user command or a webhook POST,
+// chosen per state by the notify rules in config.yaml.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+// Cooldown is the minimum time between notifications for the same sandbox
+// entering the same state, so a flapping state detection doesn't spam.
+const Cooldown = 30 * time.Second
+
+// Event is an agent entering a new state.
+type Event struct {
+	Project string    `json:"project"`
+	Sandbox string    `json:"sandbox"`
+	State   string    `json:"state"`
+	From    string    `json:"from"`
+	Time    time.Time `json:"time"`
+}
+
+// Message is the human-readable text of an event.
+func (e Event) Message() string {
+	switch e.State {
+	case "waiting":
+		return fmt.Sprintf("%s is waiting for input", e.Sandbox)
+	case "done":
+		return fmt.Sprintf("%s is done", e.Sandbox)
+	default:
+		return fmt.Sprintf("%s is %s", e.Sandbox, e.State)
+	}
+}
+
+// Transitions compares two snapshots of agent states (sandbox name →
+// state) and returns an event for each sandbox whose state changed.
+// Sandboxes seen for the first time are not reported.
+func Transitions(project string, prev, cur map[string]string) []Event {
+	var events []Event
+	now := time.Now()
+	for name, state := range cur {
+		from, ok := prev[name]
+		if !ok || from == state || state == "" {
+			continue
+		}
+		events = append(events, Event{Project: project, Sandbox: name, State: state, From: from, Time: now})
+	}
+	slices.SortFunc(events, func(a, b Event) int { return strings.Compare(a.Sandbox, b.Sandbox) })
+	return events
+}
+
+// Notifier matches events against the configured rules and delivers them.
+type Notifier struct {
+	rules  []config.NotifyRule
+	client *http.Client
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // sandbox/state → last notification
+}
+
+// New returns a Notifier for the given rules, or an error if one is invalid.
+func New(rules []config.NotifyRule) (*Notifier, error) {
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &Notifier{
+		rules:    rules,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		lastSent: make(map[string]time.Time),
+	}, nil
+}
+
+// Filter drops events with no matching rule and events for a sandbox that
+// entered the same state less than Cooldown ago.
+func (n *Notifier) Filter(events []Event) []Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var out []Event
+	for _, ev := range events {
+		if len(n.matching(ev)) == 0 {
+			continue
+		}
+		key := ev.Sandbox + "/" + ev.State
+		if last, ok := n.lastSent[key]; ok && ev.Time.Sub(last) < Cooldown {
+			continue
+		}
+		n.lastSent[key] = ev.Time
+		out = append(out, ev)
+	}
+	return out
+}
+
+// Terminal returns the escape sequence to write to the terminal for an
+// event: a bell followed by an OSC 9 notification, which terminals like
+// iTerm2, WezTerm and kitty show as a desktop notification. Returns "" if
+// no matching rule has bell set.
+func (n *Notifier) Terminal(ev Event) string {
+	for _, r := range n.matching(ev) {
+		if r.Bell {
+			return "\a\x1b]9;" + sanitize(ev.Message()) + "\x07"
+		}
+	}
+	return ""
+}
+
+// Dispatch runs the desktop, command and webhook channels of every rule
+// matching the event. It blocks until they finish; errors are joined.
+func (n *Notifier) Dispatch(ev Event) error {
+	var errs []error
+	for _, r := range n.matching(ev) {
+		if r.Desktop {
+			if out, err := exec.Command("notify-send", "sandcastles", ev.Message()).CombinedOutput(); err != nil {
+				errs = append(errs, fmt.Errorf("notify-send: %s: %w", strings.TrimSpace(string(out)), err))
+			}
+		}
+		if r.Command != "" {
+			cmd := exec.Command("sh", "-c", r.Command)
+			cmd.Env = append(os.Environ(),
+				"SC_PROJECT="+ev.Project,
+				"SC_SANDBOX="+ev.Sandbox,
+				"SC_STATE="+ev.State,
+				"SC_PREV_STATE="+ev.From,
+			)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs = append(errs, fmt.Errorf("notify command: %s: %w", strings.TrimSpace(string(out)), err))
+			}
+		}
+		if r.Webhook != "" {
+			if err := n.post(r.Webhook, ev); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matching returns the rules that fire for the event's state.
+func (n *Notifier) matching(ev Event) []config.NotifyRule {
+	var rules []config.NotifyRule
+	for _, r := range n.rules {
+		if slices.Contains(r.On, ev.State) {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// post sends the event to a webhook as JSON.
+func (n *Notifier) post(url string, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("notify webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify webhook: %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// sanitize strips control characters so text can't break out of an escape sequence.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
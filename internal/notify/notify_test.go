@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestTransitions(t *testing.T) {
+	prev := map[string]string{"api": "working", "web": "waiting", "docs": "done"}
+	cur := map[string]string{"api": "waiting", "web": "waiting", "docs": "working", "new": "working"}
+
+	events := Transitions("app", prev, cur)
+	if len(events) != 2 {
+		t.Fatalf("Transitions() = %+v, want 2 events", events)
+	}
+	if events[0].Sandbox != "api" || events[0].From != "working" || events[0].State != "waiting" {
+		t.Errorf("events[0] = %+v", events[0])
+	}
+	if events[1].Sandbox != "docs" || events[1].State != "working" {
+		t.Errorf("events[1] = %+v", events[1])
+	}
+}
+
+func TestFilterRulesAndCooldown(t *testing.T) {
+	n, err := New([]config.NotifyRule{{On: []string{"waiting", "done"}, Bell: true}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Now()
+	events := []Event{
+		{Sandbox: "api", State: "waiting", Time: now},
+		{Sandbox: "web", State: "working", Time: now}, // no rule
+	}
+	if got := n.Filter(events); len(got) != 1 || got[0].Sandbox != "api" {
+		t.Errorf("Filter() = %+v, want only api", got)
+	}
+	// Same sandbox and state within the cooldown is dropped
+	if got := n.Filter([]Event{{Sandbox: "api", State: "waiting", Time: now.Add(time.Second)}}); len(got) != 0 {
+		t.Errorf("Filter() within cooldown = %+v, want none", got)
+	}
+	if got := n.Filter([]Event{{Sandbox: "api", State: "waiting", Time: now.Add(Cooldown)}}); len(got) != 1 {
+		t.Errorf("Filter() after cooldown = %+v, want 1", got)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	n, _ := New([]config.NotifyRule{{On: []string{"done"}, Bell: true}})
+	got := n.Terminal(Event{Sandbox: "api\x1b]", State: "done"})
+	if got != "\a\x1b]9;api] is done\x07" {
+		t.Errorf("Terminal() = %q", got)
+	}
+	if got := n.Terminal(Event{Sandbox: "api", State: "waiting"}); got != "" {
+		t.Errorf("Terminal() for unmatched state = %q, want empty", got)
+	}
+}
+
+func TestDispatchCommandAndWebhook(t *testing.T) {
+	var received Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "out")
+	n, err := New([]config.NotifyRule{{
+		On:      []string{"done"},
+		Command: `echo "$SC_SANDBOX $SC_PREV_STATE $SC_STATE" > ` + out,
+		Webhook: srv.URL,
+	}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ev := Event{Project: "app", Sandbox: "api", From: "working", State: "done", Time: time.Now()}
+	if err := n.Dispatch(ev); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	data, _ := os.ReadFile(out)
+	if got := strings.TrimSpace(string(data)); got != "api working done" {
+		t.Errorf("command output = %q", got)
+	}
+	if received.Sandbox != "api" || received.State != "done" || received.Project != "app" {
+		t.Errorf("webhook received %+v", received)
+	}
+}
+
+func TestNewRejectsInvalidRule(t *testing.T) {
+	if _, err := New([]config.NotifyRule{{On: []string{"idle"}}}); err == nil {
+		t.Error("New with unknown state: want error")
+	}
+}
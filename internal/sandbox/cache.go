@@ -1,6 +1,15 @@
 package sandbox
 
-import "fmt"
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
 
 // cacheVolumeSpec defines a Docker volume mount for package manager caches.
 type cacheVolumeSpec struct {
@@ -20,15 +29,184 @@ var cacheVolumeSpecs = map[string][]cacheVolumeSpec{
 	"python": {
 		{"pip-cache", "/home/sandcastle/.cache/pip"},
 	},
+	"rust": {
+		{"cargo-registry", "/home/sandcastle/.cargo/registry"},
+		{"cargo-git", "/home/sandcastle/.cargo/git"},
+	},
+	"java": {
+		{"m2-cache", "/home/sandcastle/.m2"},
+		{"gradle-cache", "/home/sandcastle/.gradle"},
+	},
+	"ruby": {
+		{"gem-cache", "/home/sandcastle/.gem"},
+	},
+	"php": {
+		{"composer-cache", "/home/sandcastle/.cache/composer"},
+	},
+	"dotnet": {
+		{"nuget-cache", "/home/sandcastle/.nuget/packages"},
+	},
+	"elixir": {
+		{"hex-cache", "/home/sandcastle/.hex"},
+		{"mix-cache", "/home/sandcastle/.mix"},
+	},
 }
 
-// cacheVolumes returns Docker -v arguments for package manager cache volumes.
-func cacheVolumes(project, language string) []string {
-	specs := cacheVolumeSpecs[language]
+// cacheSpecs returns the cache volumes for a project: the package manager
+// caches of its languages followed by the caches from defaults.caches. A
+// configured cache with the same name as a built-in one replaces it.
+func cacheSpecs(cfg *config.Config) []cacheVolumeSpec {
+	var specs []cacheVolumeSpec
+	add := func(spec cacheVolumeSpec) {
+		for i, s := range specs {
+			if s.volume == spec.volume {
+				specs[i] = spec
+				return
+			}
+		}
+		specs = append(specs, spec)
+	}
+	for _, lang := range cfg.Language {
+		for _, s := range cacheVolumeSpecs[lang] {
+			add(s)
+		}
+	}
+	for _, c := range cfg.Defaults.Caches {
+		add(cacheVolumeSpec{c.Name, c.ContainerPath()})
+	}
+	return specs
+}
+
+// cacheVolumeName returns the Docker volume name for a cache.
+func cacheVolumeName(project, volume string) string {
+	return fmt.Sprintf("sc-%s-%s", project, volume)
+}
+
+// cacheVolumes returns Docker -v arguments for the project's cache volumes.
+func cacheVolumes(cfg *config.Config) []string {
 	var args []string
-	for _, s := range specs {
-		volName := fmt.Sprintf("sc-%s-%s", project, s.volume)
-		args = append(args, fmt.Sprintf("%s:%s", volName, s.mountPath))
+	for _, s := range cacheSpecs(cfg) {
+		args = append(args, fmt.Sprintf("%s:%s", cacheVolumeName(cfg.Project, s.volume), s.mountPath))
 	}
 	return args
 }
+
+// cacheMountPaths returns the container paths of the cache volumes plus
+// their parent directories inside the home directory. Docker creates missing
+// mount points as root, so these are chowned to the sandcastle user after
+// the container starts.
+func cacheMountPaths(cfg *config.Config) []string {
+	const home = "/home/sandcastle"
+	var paths []string
+	for _, s := range cacheSpecs(cfg) {
+		for p := s.mountPath; p != home && p != "/" && strings.HasPrefix(p, home+"/"); p = path.Dir(p) {
+			if !slices.Contains(paths, p) {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+// CacheInfo describes a project cache volume for `sc cache ls`.
+type CacheInfo struct {
+	Volume string // Docker volume name
+	Path   string // mount path in containers, or "" if no longer configured
+	Exists bool   // whether the volume has been created yet
+}
+
+// Caches lists the configured cache volumes and any leftover volumes of the
+// project that are no longer configured.
+func (m *Manager) Caches() ([]CacheInfo, error) {
+	existing, err := m.cacheVolumesOnHost()
+	if err != nil {
+		return nil, err
+	}
+	var infos []CacheInfo
+	seen := make(map[string]bool)
+	for _, s := range cacheSpecs(m.cfg) {
+		vol := cacheVolumeName(m.cfg.Project, s.volume)
+		seen[vol] = true
+		infos = append(infos, CacheInfo{Volume: vol, Path: s.mountPath, Exists: slices.Contains(existing, vol)})
+	}
+	for _, vol := range existing {
+		if !seen[vol] {
+			infos = append(infos, CacheInfo{Volume: vol, Exists: true})
+		}
+	}
+	return infos, nil
+}
+
+// ClearCaches removes the project's cache volumes. With names, only the
+// caches with those names (e.g. "npm-cache") or full volume names are
+// removed. Volumes mounted by running sandcastles cannot be removed.
+// Returns the removed volume names.
+func (m *Manager) ClearCaches(names []string) ([]string, error) {
+	existing, err := m.cacheVolumesOnHost()
+	if err != nil {
+		return nil, err
+	}
+	targets := existing
+	if len(names) > 0 {
+		targets = nil
+		for _, n := range names {
+			vol := n
+			if !slices.Contains(existing, vol) {
+				vol = cacheVolumeName(m.cfg.Project, n)
+			}
+			if !slices.Contains(existing, vol) {
+				return nil, fmt.Errorf("no cache volume %s", vol)
+			}
+			targets = append(targets, vol)
+		}
+	}
+
+	var removed []string
+	for _, vol := range targets {
+		if out, err := exec.Command("docker", "volume", "rm", vol).CombinedOutput(); err != nil {
+			return removed, fmt.Errorf("removing %s: %s: %w", vol, strings.TrimSpace(string(out)), err)
+		}
+		removed = append(removed, vol)
+	}
+	return removed, nil
+}
+
+// cacheLabel marks cache volumes with their project so leftover volumes of
+// caches that are no longer configured can still be found.
+const cacheLabel = "sandcastles.cache"
+
+// ensureCacheVolumes creates missing cache volumes with the project label.
+// Docker would create them implicitly on `run -v`, but without the label.
+func (m *Manager) ensureCacheVolumes() error {
+	for _, s := range cacheSpecs(m.cfg) {
+		vol := cacheVolumeName(m.cfg.Project, s.volume)
+		if exec.Command("docker", "volume", "inspect", vol).Run() == nil {
+			continue
+		}
+		out, err := exec.Command("docker", "volume", "create",
+			"--label", fmt.Sprintf("%s=%s", cacheLabel, m.cfg.Project), vol).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("creating cache volume %s: %s: %w", vol, strings.TrimSpace(string(out)), err)
+		}
+	}
+	return nil
+}
+
+// cacheVolumesOnHost lists the project's cache volumes that exist: labeled
+// ones plus configured ones created before volumes were labeled.
+func (m *Manager) cacheVolumesOnHost() ([]string, error) {
+	out, err := exec.Command("docker", "volume", "ls", "-q",
+		"--filter", fmt.Sprintf("label=%s=%s", cacheLabel, m.cfg.Project)).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("listing volumes: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	vols := strings.Fields(string(out))
+	for _, s := range cacheSpecs(m.cfg) {
+		vol := cacheVolumeName(m.cfg.Project, s.volume)
+		if !slices.Contains(vols, vol) && exec.Command("docker", "volume", "inspect", vol).Run() == nil {
+			vols = append(vols, vol)
+		}
+	}
+	sort.Strings(vols)
+	return vols, nil
+}
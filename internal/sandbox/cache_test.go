@@ -1,6 +1,10 @@
 package sandbox
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
 
 func TestCacheVolumes(t *testing.T) {
 	tests := []struct {
@@ -10,15 +14,64 @@ func TestCacheVolumes(t *testing.T) {
 		{"node", 1},
 		{"go", 2},
 		{"python", 1},
-		{"rust", 0},
+		{"rust", 2},
+		{"java", 2},
+		{"ruby", 1},
+		{"php", 1},
+		{"dotnet", 1},
+		{"elixir", 2},
 		{"unknown", 0},
 	}
 	for _, tt := range tests {
 		t.Run(tt.language, func(t *testing.T) {
-			vols := cacheVolumes("myproject", tt.language)
+			vols := cacheVolumes(&config.Config{Project: "myproject", Language: config.Languages{tt.language}})
 			if len(vols) != tt.expected {
 				t.Errorf("expected %d volumes for %s, got %d: %v", tt.expected, tt.language, len(vols), vols)
 			}
 		})
 	}
 }
+
+func TestCacheVolumesMultipleLanguages(t *testing.T) {
+	vols := cacheVolumes(&config.Config{Project: "myproject", Language: config.Languages{"go", "node", "go"}})
+	if len(vols) != 3 {
+		t.Errorf("expected 3 de-duplicated volumes, got %d: %v", len(vols), vols)
+	}
+}
+
+func TestCacheMountPaths(t *testing.T) {
+	paths := cacheMountPaths(&config.Config{Language: config.Languages{"rust"}})
+	want := []string{"/home/sandcastle/.cargo/registry", "/home/sandcastle/.cargo", "/home/sandcastle/.cargo/git"}
+	if len(paths) != len(want) {
+		t.Fatalf("cacheMountPaths(rust) = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("paths[%d] = %q, want %q", i, paths[i], want[i])
+		}
+	}
+}
+
+func TestCacheVolumesConfigured(t *testing.T) {
+	cfg := &config.Config{
+		Project:  "myproject",
+		Language: config.Languages{"node"},
+		Defaults: config.Defaults{Caches: []config.Cache{
+			{Name: "pnpm-store", Path: "~/.local/share/pnpm/store"},
+			{Name: "npm-cache", Path: "/cache/npm"},
+		}},
+	}
+	vols := cacheVolumes(cfg)
+	want := []string{
+		"sc-myproject-npm-cache:/cache/npm",
+		"sc-myproject-pnpm-store:/home/sandcastle/.local/share/pnpm/store",
+	}
+	if len(vols) != len(want) {
+		t.Fatalf("cacheVolumes = %v, want %v", vols, want)
+	}
+	for i := range want {
+		if vols[i] != want[i] {
+			t.Errorf("vols[%d] = %q, want %q", i, vols[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+// credentialsPath returns the host's Claude credentials file.
+func credentialsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".claude", ".credentials.json")
+}
+
+// credentialsModTime returns the modification time of the host credentials, or
+// the zero time if they don't exist.
+func credentialsModTime() time.Time {
+	info, err := os.Stat(credentialsPath())
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// credentialsWatchInterval is how often the credentials watcher checks the
+// host file.
+const credentialsWatchInterval = 5 * time.Second
+
+// A sandbox whose push failed is retried after credentialsRetryMin, doubling
+// up to credentialsRetryMax.
+const (
+	credentialsRetryMin = 10 * time.Second
+	credentialsRetryMax = 5 * time.Minute
+)
+
+// CredentialSync reports one pass of the credentials watcher.
+type CredentialSync struct {
+	Synced []string         // sandboxes that received rotated host credentials
+	Errors map[string]error // sandboxes whose push failed with an error not reported before
+}
+
+// credentialWatch tracks which credentials each running sandbox has.
+type credentialWatch struct {
+	baseline time.Time                     // host credentials when the watcher started
+	synced   map[string]time.Time          // host credentials last pushed to each sandbox
+	failed   map[string]*credentialFailure // sandboxes waiting to retry
+}
+
+// credentialFailure is a sandbox whose last push failed.
+type credentialFailure struct {
+	err   string        // last error, reported once
+	retry time.Time     // no new attempt before this
+	delay time.Duration // wait after the next failure
+}
+
+func newCredentialWatch(baseline time.Time) *credentialWatch {
+	return &credentialWatch{
+		baseline: baseline,
+		synced:   make(map[string]time.Time),
+		failed:   make(map[string]*credentialFailure),
+	}
+}
+
+// due returns the running sandboxes whose credentials are older than mod and
+// that aren't waiting to retry. A sandbox is current if it was updated since
+// mod, or if mod isn't newer than the credentials the watcher started with.
+func (w *credentialWatch) due(running []string, mod, now time.Time) []string {
+	if mod.IsZero() {
+		return nil
+	}
+	var names []string
+	for _, name := range running {
+		last := w.baseline
+		if t, ok := w.synced[name]; ok && t.After(last) {
+			last = t
+		}
+		if !mod.After(last) {
+			continue
+		}
+		if f, ok := w.failed[name]; ok && now.Before(f.retry) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// record notes the outcome of pushing the credentials modified at mod to a
+// sandbox. It reports whether err should be shown: failures back off, and
+// the same error is only reported once per sandbox.
+func (w *credentialWatch) record(name string, mod, now time.Time, err error) bool {
+	if err == nil {
+		w.synced[name] = mod
+		delete(w.failed, name)
+		return false
+	}
+	f, ok := w.failed[name]
+	if !ok {
+		f = &credentialFailure{delay: credentialsRetryMin}
+		w.failed[name] = f
+	}
+	f.retry = now.Add(f.delay)
+	f.delay = min(2*f.delay, credentialsRetryMax)
+	if f.err == err.Error() {
+		return false
+	}
+	f.err = err.Error()
+	return true
+}
+
+// forget drops sandboxes that are no longer running.
+func (w *credentialWatch) forget(running []string) {
+	live := make(map[string]bool, len(running))
+	for _, name := range running {
+		live[name] = true
+	}
+	for name := range w.synced {
+		if !live[name] {
+			delete(w.synced, name)
+		}
+	}
+	for name := range w.failed {
+		if !live[name] {
+			delete(w.failed, name)
+		}
+	}
+}
+
+// runningSandboxes lists the running sandboxes recorded in state.json. It
+// reads the file rather than m.state so a watcher sees sandboxes started by
+// other sc processes.
+func (m *Manager) runningSandboxes() []string {
+	state, err := loadState(m.projectDir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for name := range state.Sandboxes {
+		if inspectStatus(fmt.Sprintf("sc-%s", name)) == "running" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WatchCredentials pushes the host's credentials to every running sandbox
+// when they change (e.g. after Claude rotated its OAuth token), until done is
+// closed. report, if not nil, is called after each pass that updated a
+// sandbox or hit a new error.
+func (m *Manager) WatchCredentials(done <-chan struct{}, report func(CredentialSync)) {
+	m.watchCredentials(done, false, report)
+}
+
+// WatchCredentialsUntilIdle is WatchCredentials for a background process: it
+// returns once no sandbox is running, or right away if another background
+// watcher already runs for the project.
+func (m *Manager) WatchCredentialsUntilIdle(report func(CredentialSync)) error {
+	dir := filepath.Join(m.projectDir, config.Dir, config.LogDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, "credentials.lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return nil
+	}
+	m.watchCredentials(nil, true, report)
+	return nil
+}
+
+func (m *Manager) watchCredentials(done <-chan struct{}, untilIdle bool, report func(CredentialSync)) {
+	w := newCredentialWatch(m.credsModTime)
+	ticker := time.NewTicker(credentialsWatchInterval)
+	defer ticker.Stop()
+
+	var lastMod time.Time
+	for {
+		mod := credentialsModTime()
+		// Only list containers when there is something to do
+		if untilIdle || !mod.Equal(lastMod) || len(w.failed) > 0 {
+			lastMod = mod
+			running := m.runningSandboxes()
+			if untilIdle && len(running) == 0 {
+				return
+			}
+			w.forget(running)
+
+			var sync CredentialSync
+			now := time.Now()
+			for _, name := range w.due(running, mod, now) {
+				err := m.pushCredentials(name)
+				if w.record(name, mod, now, err) {
+					if sync.Errors == nil {
+						sync.Errors = make(map[string]error)
+					}
+					sync.Errors[name] = err
+				} else if err == nil {
+					sync.Synced = append(sync.Synced, name)
+				}
+			}
+			if report != nil && (len(sync.Synced) > 0 || len(sync.Errors) > 0) {
+				report(sync)
+			}
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package sandbox
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCredentialWatchDue(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	rotated := start.Add(time.Hour)
+	running := []string{"b", "a"}
+
+	for _, tc := range []struct {
+		name   string
+		synced map[string]time.Time
+		mod    time.Time
+		want   []string
+	}{
+		{"no credentials", nil, time.Time{}, nil},
+		{"unchanged", nil, start, nil},
+		{"rotated", nil, rotated, []string{"a", "b"}},
+		{"one updated", map[string]time.Time{"a": rotated}, rotated, []string{"b"}},
+		{"all updated", map[string]time.Time{"a": rotated, "b": rotated}, rotated, nil},
+		{"rotated again", map[string]time.Time{"a": rotated, "b": rotated}, rotated.Add(time.Hour), []string{"a", "b"}},
+	} {
+		w := newCredentialWatch(start)
+		for name, t := range tc.synced {
+			w.synced[name] = t
+		}
+		if got := w.due(running, tc.mod, rotated); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: due() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCredentialWatchBackoff(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	mod := start.Add(time.Minute)
+	now := mod
+	w := newCredentialWatch(start)
+	running := []string{"api"}
+	failure := errors.New("docker cp failed")
+
+	for _, tc := range []struct {
+		name   string
+		after  time.Duration // since the previous step
+		err    error
+		due    bool
+		report bool
+	}{
+		{"first failure is reported", 0, failure, true, true},
+		{"waits before retrying", 5 * time.Second, nil, false, false},
+		{"same error is not reported again", 5 * time.Second, failure, true, false},
+		{"backoff doubles", 15 * time.Second, nil, false, false},
+		{"new error is reported", 5 * time.Second, errors.New("chown failed"), true, true},
+		{"success clears the failure", 40 * time.Second, nil, true, false},
+		{"up to date", time.Hour, nil, false, false},
+	} {
+		now = now.Add(tc.after)
+		due := len(w.due(running, mod, now)) == 1
+		if due != tc.due {
+			t.Fatalf("%s: due = %v, want %v", tc.name, due, tc.due)
+		}
+		if !due {
+			continue
+		}
+		if report := w.record("api", mod, now, tc.err); report != tc.report {
+			t.Errorf("%s: record() = %v, want %v", tc.name, report, tc.report)
+		}
+	}
+	if len(w.failed) != 0 || !w.synced["api"].Equal(mod) {
+		t.Errorf("after success: failed = %v, synced = %v", w.failed, w.synced)
+	}
+
+	w.record("gone", mod, now, failure)
+	w.forget(nil)
+	if len(w.failed) != 0 || len(w.synced) != 0 {
+		t.Errorf("forget: failed = %v, synced = %v", w.failed, w.synced)
+	}
+}
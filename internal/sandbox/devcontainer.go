@@ -0,0 +1,162 @@
+package sandbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+	"github.com/zpdzap/sandcastles/internal/devcontainer"
+	"github.com/zpdzap/sandcastles/internal/dockerfile"
+)
+
+// devcontainerSpec loads the configured devcontainer.json, or returns nil if
+// the project does not use one.
+func (m *Manager) devcontainerSpec() (*devcontainer.Spec, error) {
+	if m.cfg.Image.Devcontainer == "" {
+		return nil, nil
+	}
+	return devcontainer.Load(filepath.Join(m.projectDir, m.cfg.Image.Devcontainer))
+}
+
+// buildDevcontainerBase builds the devcontainer's own Dockerfile, if it has
+// one, so the sandcastles Dockerfile can layer on top of it. Returns the
+// base image to pass as BASE_IMAGE, or "" without a devcontainer.
+func (m *Manager) buildDevcontainerBase(noCache bool) (string, error) {
+	spec, err := m.devcontainerSpec()
+	if err != nil || spec == nil {
+		return "", err
+	}
+	base := spec.BaseImage(m.cfg.Project)
+	dockerfile := spec.DockerfilePath()
+	if dockerfile == "" {
+		return base, nil
+	}
+
+	args := []string{"build", "-q"}
+	if noCache {
+		args = append(args, "--no-cache")
+	}
+	if spec.Build != nil {
+		keys := make([]string, 0, len(spec.Build.Args))
+		for k := range spec.Build.Args {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			args = append(args, "--build-arg", k+"="+spec.Build.Args[k])
+		}
+	}
+	args = append(args, "-t", base, "-f", dockerfile, spec.ContextDir())
+	if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("building devcontainer image: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return base, nil
+}
+
+// RenderDockerfile renders the project's Dockerfile from its template,
+// keeping the custom section of the existing Dockerfile. With a devcontainer
+// it layers on top of the devcontainer's image and adds the packages its
+// supported features need; BASE_IMAGE is passed at build time so changes to
+// the devcontainer's image are picked up without re-init. Such Dockerfiles
+// are stamped so the features can be re-rendered at build time (see
+// syncDockerfile).
+func RenderDockerfile(projectDir string, cfg *config.Config) ([]byte, error) {
+	tmpl, err := dockerfile.Load(cfg.Image.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	params := dockerfile.Params{
+		Base:         cfg.Image.Base,
+		Packages:     cfg.Image.Packages,
+		DockerSocket: cfg.Defaults.DockerSocket,
+	}
+	if cfg.Image.Devcontainer != "" {
+		spec, err := devcontainer.Load(filepath.Join(projectDir, cfg.Image.Devcontainer))
+		if err != nil {
+			return nil, err
+		}
+		features, _ := spec.ResolveFeatures()
+		params.Base = spec.BaseImage(cfg.Project)
+		params.BaseArg = true
+		params.Packages = append(slices.Clone(params.Packages), features.Packages...)
+		params.NodeMajor = features.NodeMajor
+	}
+
+	if existing, err := os.ReadFile(filepath.Join(projectDir, cfg.Image.Dockerfile)); err == nil {
+		params.Custom, _ = dockerfile.ExtractCustom(existing)
+	}
+
+	content, err := tmpl.Render(params)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Image.Devcontainer != "" {
+		content = dockerfile.Stamp(content)
+	}
+	return content, nil
+}
+
+// syncDockerfile re-renders a devcontainer project's Dockerfile before a
+// build, so edits to the devcontainer's features take effect. A Dockerfile
+// edited outside its custom section is not overwritten; the build fails
+// instead.
+func (m *Manager) syncDockerfile() error {
+	if m.cfg.Image.Devcontainer == "" {
+		return nil
+	}
+	path := filepath.Join(m.projectDir, m.cfg.Image.Dockerfile)
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	want, err := RenderDockerfile(m.projectDir, m.cfg)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, want) {
+		return nil
+	}
+	// Dockerfiles rendered before stamping are adopted if they still match
+	_, unstamped, _ := bytes.Cut(want, []byte("\n"))
+	if !dockerfile.Pristine(current) && !bytes.Equal(current, unstamped) {
+		return fmt.Errorf("%s is out of date with %s but was edited outside its custom section; "+
+			"run `sc init --regen-dockerfile` to regenerate it (move your changes into the custom section first)",
+			m.cfg.Image.Dockerfile, m.cfg.Image.Devcontainer)
+	}
+	return os.WriteFile(path, want, 0o644)
+}
+
+// imageHash hashes everything the project image is built from: the
+// sandcastles Dockerfile plus, with a devcontainer, its devcontainer.json and
+// Dockerfile.
+func (m *Manager) imageHash() (string, error) {
+	files := []string{filepath.Join(m.projectDir, m.cfg.Image.Dockerfile)}
+	spec, err := m.devcontainerSpec()
+	if err != nil {
+		return "", err
+	}
+	if spec != nil {
+		files = append(files, filepath.Join(m.projectDir, m.cfg.Image.Devcontainer))
+		if df := spec.DockerfilePath(); df != "" {
+			files = append(files, df)
+		}
+	}
+
+	h := sha256.New()
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		h.Write(content)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,69 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestSyncDockerfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devcontainer"), 0o755)
+	os.MkdirAll(filepath.Join(dir, config.Dir), 0o755)
+	writeSpec := func(features string) {
+		spec := `{"image": "mcr.microsoft.com/devcontainers/base:ubuntu", "features": {` + features + `}}`
+		os.WriteFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"), []byte(spec), 0o644)
+	}
+	cfg := &config.Config{Project: "app", Image: config.Image{
+		Devcontainer: ".devcontainer/devcontainer.json",
+		Dockerfile:   ".sandcastles/Dockerfile",
+	}}
+	m := &Manager{projectDir: dir, cfg: cfg}
+	path := filepath.Join(dir, cfg.Image.Dockerfile)
+	read := func() string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+
+	writeSpec(`"ghcr.io/devcontainers/features/go:1": {}`)
+	content, err := RenderDockerfile(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, content, 0o644)
+
+	// A new feature is rendered into an untouched Dockerfile
+	writeSpec(`"ghcr.io/devcontainers/features/go:1": {}, "ghcr.io/devcontainers/features/github-cli:1": {}`)
+	if err := m.syncDockerfile(); err != nil {
+		t.Fatalf("syncDockerfile: %v", err)
+	}
+	if !strings.Contains(read(), "    gh ") {
+		t.Errorf("github-cli package missing after sync:\n%s", read())
+	}
+
+	// Edits in the custom section survive
+	custom := strings.Replace(read(), "# --- END CUSTOM ---", "RUN make tools\n# --- END CUSTOM ---", 1)
+	os.WriteFile(path, []byte(custom), 0o644)
+	writeSpec(`"ghcr.io/devcontainers/features/rust:1": {}`)
+	if err := m.syncDockerfile(); err != nil {
+		t.Fatalf("syncDockerfile with custom section: %v", err)
+	}
+	if got := read(); !strings.Contains(got, "RUN make tools") || !strings.Contains(got, "cargo") {
+		t.Errorf("custom section or rust packages missing:\n%s", got)
+	}
+
+	// Hand edits elsewhere are drift
+	os.WriteFile(path, []byte(strings.Replace(read(), "cargo", "cargo htop", 1)), 0o644)
+	writeSpec(`"ghcr.io/devcontainers/features/go:1": {}`)
+	err = m.syncDockerfile()
+	if err == nil || !strings.Contains(err.Error(), "--regen-dockerfile") {
+		t.Errorf("syncDockerfile after hand edit: err = %v, want a regen hint", err)
+	}
+	if !strings.Contains(read(), "htop") {
+		t.Error("hand-edited Dockerfile was overwritten")
+	}
+}
@@ -0,0 +1,219 @@
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sidecarLabel marks helper containers that belong to a sandbox so Destroy
+// can find and remove them without tracking container IDs in state.
+const sidecarLabel = "sandcastles.sandbox"
+
+// exposeImage is the image used for port-forwarding sidecars.
+const exposeImage = "alpine/socat"
+
+// exposeContainerName returns the name of the socat sidecar forwarding a port.
+func exposeContainerName(name string, port int) string {
+	return fmt.Sprintf("sc-%s-expose-%d", name, port)
+}
+
+// ParsePort parses a TCP port number, e.g. from /expose.
+func ParsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port: %s", s)
+	}
+	return port, nil
+}
+
+// Expose forwards a container port of a running sandbox to a random host port.
+// In bridge mode a socat sidecar is started next to the sandbox container; in
+// host network mode the port is already reachable and is only recorded.
+// Returns the host port.
+func (m *Manager) Expose(name string, port int) (string, error) {
+	key := fmt.Sprintf("%d", port)
+	pending := fmt.Sprintf("%s/%d", name, port)
+
+	// Check and reserve the port under the lock so concurrent exposes of
+	// the same port can't both start a forward
+	m.mu.Lock()
+	sb, ok := m.state.Sandboxes[name]
+	if !ok {
+		m.mu.Unlock()
+		return "", fmt.Errorf("sandcastle %q not found", name)
+	}
+	if sb.Status != StatusRunning {
+		m.mu.Unlock()
+		return "", fmt.Errorf("sandcastle %q is not running", name)
+	}
+	if _, exists := sb.Ports[key]; exists || m.exposing[pending] {
+		m.mu.Unlock()
+		return "", fmt.Errorf("port %d is already exposed on %s", port, name)
+	}
+	if m.exposing == nil {
+		m.exposing = make(map[string]bool)
+	}
+	m.exposing[pending] = true
+	m.mu.Unlock()
+
+	hostPort := key
+	var err error
+	if !m.cfg.Defaults.IsHostNetwork() {
+		hostPort, err = m.startPortForward(name, port)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.exposing, pending)
+	if err != nil {
+		return "", err
+	}
+	if sb, ok = m.state.Sandboxes[name]; !ok {
+		return "", fmt.Errorf("sandcastle %q was stopped", name)
+	}
+	if sb.Ports == nil {
+		sb.Ports = make(map[string]string)
+	}
+	sb.Ports[key] = hostPort
+	sb.Exposed = append(sb.Exposed, port)
+	sort.Ints(sb.Exposed)
+	m.persist()
+	return hostPort, nil
+}
+
+// Unexpose removes a port forward previously added with Expose.
+func (m *Manager) Unexpose(name string, port int) error {
+	m.mu.Lock()
+	sb, ok := m.state.Sandboxes[name]
+	exposed := ok && slices.Contains(sb.Exposed, port)
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("sandcastle %q not found", name)
+	}
+	if !exposed {
+		return fmt.Errorf("port %d was not exposed with /expose on %s", port, name)
+	}
+
+	if !m.cfg.Defaults.IsHostNetwork() {
+		sidecar := exposeContainerName(name, port)
+		if out, err := exec.Command("docker", "rm", "-f", sidecar).CombinedOutput(); err != nil {
+			return fmt.Errorf("removing port forward: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sb, ok := m.state.Sandboxes[name]; ok {
+		sb.Exposed = slices.DeleteFunc(sb.Exposed, func(p int) bool { return p == port })
+		delete(sb.Ports, fmt.Sprintf("%d", port))
+		m.persist()
+	}
+	return nil
+}
+
+// startPortForward starts a socat sidecar publishing a random host port and
+// forwarding it to the sandbox container. The sidecar runs on the default
+// bridge (so it can publish ports) and joins a user-defined network shared
+// with the sandbox: the internal egress network, or else the sandbox's
+// private network. It connects to the sandbox by name, so the forward keeps
+// working after a restart gives the sandbox a new IP. Returns the host port.
+func (m *Manager) startPortForward(name string, port int) (string, error) {
+	containerName := fmt.Sprintf("sc-%s", name)
+	network, err := m.forwardNetwork(name)
+	if err != nil {
+		return "", err
+	}
+
+	sidecar := exposeContainerName(name, port)
+	out, err := exec.Command("docker", "run", "-d",
+		"--name", sidecar,
+		"--label", fmt.Sprintf("%s=%s", sidecarLabel, name),
+		"-p", fmt.Sprintf("0:%d", port),
+		exposeImage,
+		fmt.Sprintf("tcp-listen:%d,fork,reuseaddr", port),
+		fmt.Sprintf("tcp-connect:%s:%d", containerName, port),
+	).CombinedOutput()
+	if err != nil {
+		exec.Command("docker", "rm", "-f", sidecar).Run()
+		return "", fmt.Errorf("starting port forward: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	if out, err := exec.Command("docker", "network", "connect", network, sidecar).CombinedOutput(); err != nil {
+		exec.Command("docker", "rm", "-f", sidecar).Run()
+		return "", fmt.Errorf("connecting port forward: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	key := fmt.Sprintf("%d", port)
+	hostPort := m.queryPorts(sidecar)[key]
+	if hostPort == "" {
+		exec.Command("docker", "rm", "-f", sidecar).Run()
+		return "", fmt.Errorf("port forward for %d did not publish a host port", port)
+	}
+	return hostPort, nil
+}
+
+// forwardNetwork returns a network on which port-forward sidecars can reach
+// a sandbox by name. The default bridge has no name resolution, so a sandbox
+// on it is connected to its private network (shared with its services).
+func (m *Manager) forwardNetwork(name string) (string, error) {
+	network := m.sandboxNetwork()
+	if network != "bridge" {
+		return network, nil
+	}
+	containerName := fmt.Sprintf("sc-%s", name)
+	network = serviceNetworkName(name)
+	if containerIP(containerName, network) != "" {
+		return network, nil
+	}
+	if err := ensureNetwork(network, false); err != nil {
+		return "", err
+	}
+	if out, err := exec.Command("docker", "network", "connect", network, containerName).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("connecting %s to %s: %s: %w", containerName, network, strings.TrimSpace(string(out)), err)
+	}
+	return network, nil
+}
+
+// exposedPorts returns host port mappings for ports added with Expose.
+func (m *Manager) exposedPorts(sb *Sandbox) map[string]string {
+	ports := make(map[string]string)
+	for _, port := range sb.Exposed {
+		key := fmt.Sprintf("%d", port)
+		if m.cfg.Defaults.IsHostNetwork() {
+			ports[key] = key
+			continue
+		}
+		if hostPort := m.queryPorts(exposeContainerName(sb.Name, port))[key]; hostPort != "" {
+			ports[key] = hostPort
+		}
+	}
+	return ports
+}
+
+// removeSidecars force-removes every helper container labeled for a sandbox.
+func removeSidecars(name string) {
+	out, err := exec.Command("docker", "ps", "-aq",
+		"--filter", fmt.Sprintf("label=%s=%s", sidecarLabel, name)).Output()
+	if err != nil {
+		return
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return
+	}
+	exec.Command("docker", append([]string{"rm", "-f"}, ids...)...).Run()
+}
+
+// containerIP returns a container's IP address on the given Docker network.
+func containerIP(containerName, network string) string {
+	out, err := exec.Command("docker", "inspect", "-f",
+		fmt.Sprintf(`{{with index .NetworkSettings.Networks %q}}{{.IPAddress}}{{end}}`, network),
+		containerName).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
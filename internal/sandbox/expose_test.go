@@ -0,0 +1,75 @@
+package sandbox
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestParsePort(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"8080", 8080, true},
+		{"1", 1, true},
+		{"65535", 65535, true},
+		{"0", 0, false},
+		{"65536", 0, false},
+		{"-1", 0, false},
+		{"http", 0, false},
+		{"", 0, false},
+	} {
+		got, err := ParsePort(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParsePort(%q) = %d, %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestExposeContainerName(t *testing.T) {
+	if got := exposeContainerName("api", 5173); got != "sc-api-expose-5173" {
+		t.Errorf("exposeContainerName() = %q", got)
+	}
+}
+
+func TestExposeHostNetwork(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Defaults.Network = "host"
+	m := &Manager{projectDir: t.TempDir(), cfg: cfg, state: newState()}
+	m.state.Sandboxes["api"] = &Sandbox{Name: "api", Status: StatusRunning, Ports: map[string]string{"3000": "13000"}}
+	m.state.Sandboxes["idle"] = &Sandbox{Name: "idle", Status: StatusStopped}
+
+	if hostPort, err := m.Expose("api", 5173); err != nil || hostPort != "5173" {
+		t.Fatalf("Expose(5173) = %q, %v", hostPort, err)
+	}
+	for _, port := range []int{5173, 3000} {
+		if _, err := m.Expose("api", port); err == nil {
+			t.Errorf("Expose(%d) twice succeeded", port)
+		}
+	}
+	if _, err := m.Expose("idle", 5173); err == nil {
+		t.Error("Expose on a stopped sandbox succeeded")
+	}
+
+	m.exposing = map[string]bool{"api/8080": true}
+	if _, err := m.Expose("api", 8080); err == nil {
+		t.Error("Expose of a port being forwarded succeeded")
+	}
+
+	sb := m.state.Sandboxes["api"]
+	if !slices.Equal(sb.Exposed, []int{5173}) || sb.Ports["5173"] != "5173" {
+		t.Errorf("after Expose: Exposed = %v, Ports = %v", sb.Exposed, sb.Ports)
+	}
+	if err := m.Unexpose("api", 3000); err == nil {
+		t.Error("Unexpose of a configured port succeeded")
+	}
+	if err := m.Unexpose("api", 5173); err != nil {
+		t.Fatalf("Unexpose: %v", err)
+	}
+	if len(sb.Exposed) != 0 || sb.Ports["5173"] != "" {
+		t.Errorf("after Unexpose: Exposed = %v, Ports = %v", sb.Exposed, sb.Ports)
+	}
+}
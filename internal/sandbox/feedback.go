@@ -0,0 +1,86 @@
+package sandbox
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// feedbackLines is how many lines of each failed check's output are sent
+	// back to the agent.
+	feedbackLines = 40
+	// feedbackBytes caps the whole feedback prompt.
+	feedbackBytes = 6000
+)
+
+// Feedback handles a failed automatic verify run. While the sandbox has
+// iterations left under defaults.max_iterations it counts one and returns a
+// prompt with the failing output to send to the agent. Once they're used up
+// it flags the sandbox as needing attention and returns "". A run that failed
+// without a failing check (e.g. the log couldn't be created) isn't the
+// agent's to fix, so it returns "" without counting an iteration.
+func (m *Manager) Feedback(name string, v *Verification) string {
+	limit := m.cfg.Defaults.MaxIterations
+	if limit <= 0 || v == nil || v.Status != VerifyFailed || len(v.Failed()) == 0 {
+		return ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sb, ok := m.state.Sandboxes[name]
+	if !ok {
+		return ""
+	}
+	if sb.Iterations >= limit {
+		sb.NeedsAttention = true
+		m.persist()
+		return ""
+	}
+	sb.Iterations++
+	m.persist()
+	return FeedbackPrompt(v, sb.Iterations, limit)
+}
+
+// ClearAttention resets a sandbox's feedback loop, e.g. once a human has
+// stepped in by sending a prompt or attaching.
+func (m *Manager) ClearAttention(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sb, ok := m.state.Sandboxes[name]
+	if !ok || (sb.Iterations == 0 && !sb.NeedsAttention) {
+		return
+	}
+	sb.Iterations = 0
+	sb.NeedsAttention = false
+	m.persist()
+}
+
+// FeedbackPrompt builds the prompt that tells an agent its work failed the
+// checks: each failed command with the end of its output, trimmed to fit.
+func FeedbackPrompt(v *Verification, iteration, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The project's checks failed after you finished (attempt %d of %d). "+
+		"Fix the failures below, then run the commands again to confirm they pass.\n", iteration, limit)
+
+	for _, c := range v.Checks {
+		if c.ExitCode == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n$ %s (exit %d)\n", c.Command, c.ExitCode)
+		if c.ExitCode == -1 && c.Output == "" {
+			b.WriteString("(timed out or couldn't run)\n")
+			continue
+		}
+		lines := strings.Split(c.Output, "\n")
+		if len(lines) > feedbackLines {
+			lines = lines[len(lines)-feedbackLines:]
+		}
+		b.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+
+	prompt := strings.TrimSpace(b.String())
+	if len(prompt) > feedbackBytes {
+		prompt = strings.ToValidUTF8(prompt[:feedbackBytes], "") + "\n…(output truncated)"
+	}
+	return prompt
+}
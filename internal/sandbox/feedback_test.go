@@ -0,0 +1,64 @@
+package sandbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestFeedback(t *testing.T) {
+	m := &Manager{projectDir: t.TempDir(), cfg: &config.Config{}, state: newState()}
+	m.state.Sandboxes["api"] = &Sandbox{Name: "api"}
+	failed := &Verification{Status: VerifyFailed, Checks: []CheckResult{
+		{Command: "go vet ./...", ExitCode: 0},
+		{Command: "go test ./...", ExitCode: 1, Output: "--- FAIL: TestLogin\n    login_test.go:12: got 401"},
+	}}
+
+	if got := m.Feedback("api", failed); got != "" {
+		t.Errorf("Feedback with max_iterations unset = %q", got)
+	}
+
+	m.cfg.Defaults.MaxIterations = 2
+	sb := m.state.Sandboxes["api"]
+	for i := 1; i <= 2; i++ {
+		prompt := m.Feedback("api", failed)
+		if !strings.Contains(prompt, "$ go test ./... (exit 1)\n--- FAIL: TestLogin") || strings.Contains(prompt, "go vet") {
+			t.Errorf("attempt %d: prompt = %q", i, prompt)
+		}
+		if sb.Iterations != i || sb.NeedsAttention {
+			t.Errorf("attempt %d: Iterations = %d, NeedsAttention = %v", i, sb.Iterations, sb.NeedsAttention)
+		}
+	}
+	if got := m.Feedback("api", failed); got != "" || !sb.NeedsAttention {
+		t.Errorf("past the limit: prompt = %q, NeedsAttention = %v", got, sb.NeedsAttention)
+	}
+
+	m.ClearAttention("api")
+	if sb.Iterations != 0 || sb.NeedsAttention {
+		t.Errorf("after ClearAttention: Iterations = %d, NeedsAttention = %v", sb.Iterations, sb.NeedsAttention)
+	}
+}
+
+func TestFeedbackPromptTrimmed(t *testing.T) {
+	long := strings.Repeat("x\n", 100) + "last line"
+	v := &Verification{Checks: []CheckResult{
+		{Command: "make test", ExitCode: 2, Output: long},
+		{Command: "make lint", ExitCode: -1},
+	}}
+	prompt := FeedbackPrompt(v, 1, 3)
+	if !strings.Contains(prompt, "attempt 1 of 3") || !strings.Contains(prompt, "last line") {
+		t.Errorf("prompt = %q", prompt)
+	}
+	if n := strings.Count(prompt, "x\n"); n != feedbackLines-1 {
+		t.Errorf("kept %d output lines, want %d", n, feedbackLines-1)
+	}
+	if !strings.Contains(prompt, "$ make lint (exit -1)\n(timed out or couldn't run)") {
+		t.Errorf("prompt = %q", prompt)
+	}
+
+	v.Checks[0].Output = strings.Repeat("y", 3*feedbackBytes)
+	if prompt := FeedbackPrompt(v, 1, 3); len(prompt) > feedbackBytes+32 {
+		t.Errorf("prompt is %d bytes", len(prompt))
+	}
+}
@@ -0,0 +1,102 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+	"github.com/zpdzap/sandcastles/internal/shell"
+)
+
+// sshAuthSockPath is where the host's SSH agent socket is mounted in containers.
+const sshAuthSockPath = "/run/host-services/ssh-auth.sock"
+
+// gitHooksDir holds the hook dispatcher that adds Co-authored-by trailers.
+const gitHooksDir = "/home/sandcastle/.config/git/sandcastles-hooks"
+
+// gitHookNames are the client-side hooks the dispatcher is installed as, so
+// setting core.hooksPath doesn't silently disable the repository's own hooks.
+var gitHookNames = []string{
+	"applypatch-msg", "pre-applypatch", "post-applypatch",
+	"pre-commit", "pre-merge-commit", "prepare-commit-msg", "commit-msg", "post-commit",
+	"pre-rebase", "post-checkout", "post-merge", "pre-push", "post-rewrite",
+}
+
+// hostGitIdentity returns the host's git user.name and user.email for the project.
+func hostGitIdentity(projectDir string) (string, string) {
+	get := func(key string) string {
+		out, err := exec.Command("git", "-C", projectDir, "config", key).Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+	return get("user.name"), get("user.email")
+}
+
+// sshAgentArgs returns docker run arguments forwarding the host's SSH agent,
+// or nil if forwarding is disabled or no agent is running.
+func sshAgentArgs(git config.Git) []string {
+	if !git.SSHAgent {
+		return nil
+	}
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil
+	}
+	if _, err := os.Stat(sock); err != nil {
+		return nil
+	}
+	return []string{
+		"-v", fmt.Sprintf("%s:%s", sock, sshAuthSockPath),
+		"-e", "SSH_AUTH_SOCK=" + sshAuthSockPath,
+		"-e", "GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=accept-new",
+	}
+}
+
+// gitSetupScript returns the shell commands configuring git for the sandcastle user.
+func gitSetupScript(git config.Git, hostName, hostEmail string, sshForwarded bool) string {
+	var b strings.Builder
+
+	// Without an SSH agent, rewrite SSH remotes to https so public fetches work
+	if !sshForwarded {
+		b.WriteString("git config --global 'url.https://github.com/.insteadOf' 'git@github.com:'\n")
+	}
+
+	name, email := hostName, hostEmail
+	agentIdentity := git.Name != "" && git.Email != ""
+	if agentIdentity {
+		name, email = git.Name, git.Email
+	}
+	if name != "" {
+		fmt.Fprintf(&b, "git config --global user.name %s\n", shell.Quote(name))
+	}
+	if email != "" {
+		fmt.Fprintf(&b, "git config --global user.email %s\n", shell.Quote(email))
+	}
+
+	// Credit the human when the agent commits under its own identity. The
+	// dispatcher runs the repository's hook of the same name first.
+	if agentIdentity && hostName != "" && hostEmail != "" {
+		trailer := fmt.Sprintf("Co-authored-by: %s <%s>", hostName, hostEmail)
+		fmt.Fprintf(&b, "mkdir -p %s\n", gitHooksDir)
+		fmt.Fprintf(&b, "cat > %s/dispatch <<'SCHOOK'\n", gitHooksDir)
+		b.WriteString("#!/bin/sh\n")
+		b.WriteString(`hook="$(basename "$0")"` + "\n")
+		b.WriteString(`repo_hook="$(git rev-parse --git-common-dir)/hooks/$hook"` + "\n")
+		b.WriteString(`if [ -x "$repo_hook" ]; then "$repo_hook" "$@" || exit $?; fi` + "\n")
+		b.WriteString(`if [ "$hook" = prepare-commit-msg ]; then` + "\n")
+		fmt.Fprintf(&b, "  git interpret-trailers --in-place --if-exists addIfDifferent --trailer %s \"$1\"\n", shell.Quote(trailer))
+		b.WriteString("fi\n")
+		b.WriteString("SCHOOK\n")
+		fmt.Fprintf(&b, "chmod +x %s/dispatch\n", gitHooksDir)
+		for _, hook := range gitHookNames {
+			fmt.Fprintf(&b, "ln -sf dispatch %s/%s\n", gitHooksDir, hook)
+		}
+		fmt.Fprintf(&b, "git config --global core.hooksPath %s\n", gitHooksDir)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package sandbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestGitSetupScriptHostIdentity(t *testing.T) {
+	script := gitSetupScript(config.Git{}, "Ada Lovelace", "ada@example.com", false)
+
+	if !strings.Contains(script, "git config --global user.name 'Ada Lovelace'") {
+		t.Errorf("missing host user.name:\n%s", script)
+	}
+	if !strings.Contains(script, "git config --global user.email 'ada@example.com'") {
+		t.Errorf("missing host user.email:\n%s", script)
+	}
+	if !strings.Contains(script, "insteadOf") {
+		t.Errorf("expected https rewrite without SSH agent:\n%s", script)
+	}
+	if strings.Contains(script, "Co-authored-by") {
+		t.Errorf("host identity should not add a trailer:\n%s", script)
+	}
+}
+
+func TestGitSetupScriptAgentIdentity(t *testing.T) {
+	git := config.Git{Name: "Sandcastle Agent", Email: "agent@example.com"}
+	script := gitSetupScript(git, "O'Brien", "ob@example.com", true)
+
+	if !strings.Contains(script, "user.name 'Sandcastle Agent'") {
+		t.Errorf("missing agent user.name:\n%s", script)
+	}
+	if !strings.Contains(script, `'Co-authored-by: O'\''Brien <ob@example.com>'`) {
+		t.Errorf("missing quoted co-author trailer:\n%s", script)
+	}
+	if !strings.Contains(script, "core.hooksPath") {
+		t.Errorf("missing hooks path:\n%s", script)
+	}
+	if strings.Contains(script, "insteadOf") {
+		t.Errorf("https rewrite should be skipped with SSH agent:\n%s", script)
+	}
+}
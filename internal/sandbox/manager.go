@@ -2,11 +2,12 @@ package sandbox
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -23,6 +24,13 @@ type Manager struct {
 	projectDir string
 	cfg        *config.Config
 	state      *State
+
+	credsModTime time.Time  // host credentials when the manager started
+	warmMu       sync.Mutex // held while a warm image is being built
+	poolMu       sync.Mutex // held while the container pool is topped up or drained
+	transcriptMu sync.Mutex // guards the transcript index and usageCache
+	usageCache   map[string]usageCacheEntry
+	exposing     map[string]bool // "name/port" forwards being started, guarded by mu
 }
 
 // NewManager creates a new sandbox manager.
@@ -32,18 +40,32 @@ func NewManager(projectDir string, cfg *config.Config) *Manager {
 		state = newState()
 	}
 	return &Manager{
-		projectDir: projectDir,
-		cfg:        cfg,
-		state:      state,
+		projectDir:   projectDir,
+		cfg:          cfg,
+		state:        state,
+		credsModTime: credentialsModTime(),
 	}
 }
 
+// ProjectDir returns the root of the project the manager belongs to.
+func (m *Manager) ProjectDir() string {
+	return m.projectDir
+}
+
 // ProgressFunc is called with status updates during sandbox creation.
 type ProgressFunc func(phase string)
 
+// CreateOptions are per-sandbox choices for Create. The zero value branches
+// from HEAD and leaves the model to the agent.
+type CreateOptions struct {
+	Base  string // git ref the sandbox's branch starts from
+	Model string // model the agent runs with, recorded for agent.Start
+	Race  string // race the sandbox is an attempt in, see CreateRace
+}
+
 // Create spins up a new sandbox: creates a worktree, builds the image, starts a container.
 // If progress is non-nil, it's called with phase updates.
-func (m *Manager) Create(name, task string, progress ProgressFunc) (*Sandbox, error) {
+func (m *Manager) Create(name, task string, opts CreateOptions, progress ProgressFunc) (*Sandbox, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -57,11 +79,33 @@ func (m *Manager) Create(name, task string, progress ProgressFunc) (*Sandbox, er
 		return nil, fmt.Errorf("sandbox %q already exists", name)
 	}
 
-	// Create git worktree
-	report("Creating worktree...")
-	wtPath, branch, err := worktree.Create(m.projectDir, name)
+	baseCommit := ""
+	if opts.Base != "" {
+		commit, err := worktree.ResolveRef(m.projectDir, opts.Base)
+		if err != nil {
+			return nil, err
+		}
+		baseCommit = commit
+	}
+
+	// Set up the restricted network (and allowlist proxy) before anything else
+	// so a bad egress config fails fast.
+	if err := m.cfg.Defaults.Egress.Validate(); err != nil {
+		return nil, err
+	}
+	for _, c := range m.cfg.Defaults.Caches {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.ensureCacheVolumes(); err != nil {
+		return nil, err
+	}
+
+	// Read secrets from the host up front; they are only ever held in memory
+	secrets, err := resolveSecrets(m.projectDir, m.cfg.Defaults.Secrets)
 	if err != nil {
-		return nil, fmt.Errorf("creating worktree: %w", err)
+		return nil, err
 	}
 
 	// Build the Docker image (skip if Dockerfile unchanged and image exists)
@@ -70,38 +114,229 @@ func (m *Manager) Create(name, task string, progress ProgressFunc) (*Sandbox, er
 	} else {
 		report("Building image (may take a minute on first run)...")
 		if err := m.buildImage(); err != nil {
-			worktree.Remove(m.projectDir, name)
 			return nil, fmt.Errorf("building image: %w", err)
 		}
 	}
 
 	// Determine whether to use the warm image (setup already baked in)
-	useWarm := false
 	baseID := m.baseImageID()
-	if m.warmImageExists() && warmImageUpToDate(m.projectDir, baseID, m.cfg.Language) {
-		useWarm = true
+	inputs := computeWarmInputs(m.projectDir, baseID, m.cfg)
+	useWarm, warmStatus := m.warmDecision(baseID, inputs)
+	if useWarm {
 		report("Using warm image (setup cached)...")
 	}
 
+	if m.cfg.Defaults.Egress.Restricted() {
+		report("Setting up egress policy...")
+		if err := m.ensureEgress(); err != nil {
+			return nil, fmt.Errorf("setting up egress: %w", err)
+		}
+	}
+
 	startImage := m.imageName()
 	if useWarm {
 		startImage = warmImageName(m.cfg.Project)
 	}
 
-	// Start container
-	report("Starting container...")
+	// Take an idle container from the pool if one was started from the same
+	// image and config; it only needs the worktree and the agent.
 	containerName := fmt.Sprintf("sc-%s", name)
+	var pooled *pooledContainer
+	if m.poolEnabled() {
+		pooled = m.claimPooled(startImage, containerName)
+	}
+
+	// Create git worktree
+	report("Creating worktree...")
+	var wtPath, branch string
+	if pooled != nil {
+		wtPath, branch, err = m.adoptPooled(pooled, name, baseCommit)
+	} else {
+		wtPath, branch, err = worktree.Create(m.projectDir, name, baseCommit)
+	}
+	if err != nil {
+		if pooled != nil {
+			exec.Command("docker", "rm", "-f", containerName).Run()
+		}
+		return nil, fmt.Errorf("creating worktree: %w", err)
+	}
+
+	cleanup := func() {
+		if pooled != nil {
+			exec.Command("docker", "rm", "-f", containerName).Run()
+		}
+		removeSidecars(name)
+		removeServiceNetwork(name)
+		worktree.Remove(m.projectDir, name)
+	}
+
+	// Sidecar services start first so setup commands can use them
+	services, err := m.startServices(name, report)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("starting services: %w", err)
+	}
+
+	// Start container
+	portOffset := 0
+	var containerID string
+	if pooled != nil {
+		report("Using pooled container...")
+		containerID = pooled.id
+	} else {
+		report("Starting container...")
+		args := []string{
+			"run", "-d",
+			"--name", containerName,
+			"-v", fmt.Sprintf("%s:/workspace", wtPath),
+		}
+
+		// Host-network sandboxes can't remap ports, so they get a unique
+		// offset and are told which ports to bind via env vars.
+		if m.cfg.Defaults.IsHostNetwork() {
+			offset, err := nextPortOffset(m.state.Sandboxes, m.cfg.Defaults.Ports)
+			if err != nil {
+				cleanup()
+				return nil, err
+			}
+			vars, err := portEnv(m.cfg.Defaults.Ports, offset, m.cfg.Defaults.Env)
+			if err != nil {
+				cleanup()
+				return nil, err
+			}
+			portOffset = offset
+			args = append(args, "--network", "host")
+			for _, env := range vars {
+				args = append(args, "-e", env)
+			}
+		}
+		args = append(args, m.runArgs()...)
+
+		// Secrets: env vars go through a private env-file (deleted once the
+		// container is created), file secrets into a tmpfs written after start
+		envFile, err := writeSecretEnvFile(secrets)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		if envFile != "" {
+			defer os.Remove(envFile)
+			args = append(args, "--env-file", envFile)
+		}
+		if hasFileSecrets(secrets) {
+			args = append(args, "--tmpfs", fmt.Sprintf("%s:mode=0700,uid=%d,gid=%d", secretsMountPath, os.Getuid(), os.Getgid()))
+		}
+
+		args = append(args, startImage, "sleep", "infinity")
+
+		cmd := exec.Command("docker", args...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("docker run failed: %s: %w", redact(strings.TrimSpace(string(out)), secrets), err)
+		}
+		if envFile != "" {
+			os.Remove(envFile)
+		}
+
+		containerID = strings.TrimSpace(string(out))
+		if len(containerID) > 12 {
+			containerID = containerID[:12]
+		}
+	}
+
+	// Join the services' private network so they resolve by name
+	if len(services) > 0 {
+		if out, err := exec.Command("docker", "network", "connect", serviceNetworkName(name), containerName).CombinedOutput(); err != nil {
+			exec.Command("docker", "rm", "-f", containerName).Run()
+			cleanup()
+			return nil, fmt.Errorf("connecting to services: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+	}
+
+	report("Configuring environment...")
+	if err := writeFileSecrets(containerName, secrets); err != nil {
+		exec.Command("docker", "rm", "-f", containerName).Run()
+		cleanup()
+		return nil, err
+	}
+
+	// User setup script: git config, setup commands (single docker exec as sandcastle).
+	// Pooled containers were configured when they started; only their
+	// credentials may have rotated since.
+	var userScript strings.Builder
+	if pooled != nil {
+		m.copyCredentials(containerName)
+	} else {
+		m.configureContainer(containerName)
+		userScript.WriteString(m.gitSetup())
+	}
+
+	if len(m.cfg.Defaults.Setup) > 0 && !useWarm {
+		report("Running setup commands...")
+		for _, cmd := range m.cfg.Defaults.Setup {
+			userScript.WriteString(fmt.Sprintf("(%s) || true\n", cmd))
+		}
+	}
+
+	report("Starting tmux session...")
+	userScript.WriteString(tmuxScript(name))
+
+	userCmd := exec.Command("docker", "exec", "-i", containerName, "bash", "-s")
+	userCmd.Stdin = strings.NewReader(userScript.String())
+	userCmd.CombinedOutput()
+
+	// Query port mappings
+	var ports map[string]string
+	var exposed []int
+	if m.cfg.Defaults.IsHostNetwork() {
+		ports, _ = hostNetworkPorts(m.cfg.Defaults.Ports, portOffset) // checked by nextPortOffset
+	} else if m.cfg.Defaults.Egress.Restricted() {
+		ports = make(map[string]string)
+		for _, port := range m.cfg.Defaults.Ports {
+			if hostPort, err := m.startPortForward(name, port); err == nil {
+				ports[fmt.Sprintf("%d", port)] = hostPort
+				exposed = append(exposed, port)
+			}
+		}
+	} else {
+		ports = m.queryPorts(containerName)
+	}
+
+	sb := &Sandbox{
+		Name:         name,
+		ContainerID:  containerID,
+		Status:       StatusRunning,
+		Task:         task,
+		Branch:       branch,
+		Base:         opts.Base,
+		Model:        opts.Model,
+		Race:         opts.Race,
+		WorktreePath: wtPath,
+		Ports:        ports,
+		Exposed:      exposed,
+		PortOffset:   portOffset,
+		Services:     services,
+		Warm:         warmStatus,
+		CreatedAt:    time.Now(),
+	}
+	m.state.Sandboxes[name] = sb
+	m.persist()
+
+	return sb, nil
+}
+
+// runArgs returns the docker run flags shared by sandbox and pooled
+// containers: the git dir, Docker socket, bridge or restricted networking,
+// environment, SSH agent, extra mounts and caches. Callers add the name,
+// the workspace mount, host networking and secrets. The output is
+// deterministic so it can key the container pool.
+func (m *Manager) runArgs() []string {
 	// The worktree's .git file contains an absolute path back to the main repo's
 	// .git/worktrees/<name> directory. Mount the main repo's .git at its host path
 	// so git operations resolve correctly inside the container.
 	gitDir := fmt.Sprintf("%s/.git", m.projectDir)
-
-	args := []string{
-		"run", "-d",
-		"--name", containerName,
-		"-v", fmt.Sprintf("%s:/workspace", wtPath),
-		"-v", fmt.Sprintf("%s:%s", gitDir, gitDir),
-	}
+	args := []string{"-v", fmt.Sprintf("%s:%s", gitDir, gitDir)}
 
 	// Docker socket mount
 	if m.cfg.Defaults.DockerSocket {
@@ -113,10 +348,15 @@ func (m *Manager) Create(name, task string, progress ProgressFunc) (*Sandbox, er
 		}
 	}
 
-	// Network mode and port mappings
-	if m.cfg.Defaults.IsHostNetwork() {
-		args = append(args, "--network", "host")
-	} else {
+	// Network mode and port mappings (host networking is set up by Create)
+	if m.cfg.Defaults.Egress.Restricted() && !m.cfg.Defaults.IsHostNetwork() {
+		// Internal networks can't publish ports; configured ports are
+		// forwarded through sidecars once the container is up.
+		args = append(args, "--network", m.sandboxNetwork())
+		for _, env := range m.egressEnv() {
+			args = append(args, "-e", env)
+		}
+	} else if !m.cfg.Defaults.IsHostNetwork() {
 		for _, port := range m.cfg.Defaults.Ports {
 			args = append(args, "-p", fmt.Sprintf("0:%d", port))
 		}
@@ -126,8 +366,8 @@ func (m *Manager) Create(name, task string, progress ProgressFunc) (*Sandbox, er
 	if key := os.Getenv("ANTHROPIC_API_KEY"); key != "" {
 		args = append(args, "-e", "ANTHROPIC_API_KEY")
 	}
-	for k, v := range m.cfg.Defaults.Env {
-		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+	for _, k := range slices.Sorted(maps.Keys(m.cfg.Defaults.Env)) {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", k, m.cfg.Defaults.Env[k]))
 	}
 
 	// GPU passthrough for X11 forwarding (headed browsers)
@@ -137,32 +377,24 @@ func (m *Manager) Create(name, task string, progress ProgressFunc) (*Sandbox, er
 		}
 	}
 
+	// SSH agent forwarding for private git remotes
+	args = append(args, sshAgentArgs(m.cfg.Defaults.Git)...)
+
 	// Extra mounts
 	for _, mount := range m.cfg.Defaults.Mounts {
 		args = append(args, "-v", mount)
 	}
 
-	// Cache volumes for package manager caches (persist across containers)
-	for _, vol := range cacheVolumes(m.cfg.Project, m.cfg.Language) {
+	// Cache volumes for package manager and configured caches (persist across containers)
+	for _, vol := range cacheVolumes(m.cfg) {
 		args = append(args, "-v", vol)
 	}
+	return args
+}
 
-	args = append(args, startImage, "sleep", "infinity")
-
-	cmd := exec.Command("docker", args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		worktree.Remove(m.projectDir, name)
-		return nil, fmt.Errorf("docker run failed: %s: %w", strings.TrimSpace(string(out)), err)
-	}
-
-	containerID := strings.TrimSpace(string(out))
-	if len(containerID) > 12 {
-		containerID = containerID[:12]
-	}
-
-	// Copy config files into container via batched operations.
-	report("Configuring environment...")
+// configureContainer copies the host's Claude config into a freshly started
+// container and runs the root setup script (patches, ownership, symlinks).
+func (m *Manager) configureContainer(containerName string) {
 	home, _ := os.UserHomeDir()
 
 	// Batch-copy from ~/.claude/ via tar (--dereference resolves symlinks
@@ -248,6 +480,9 @@ PYEOF
 	}
 
 	rootScript.WriteString("chown -R sandcastle:sandcastle /home/sandcastle/.claude /home/sandcastle/.claude.json 2>/dev/null || true\n")
+	if paths := cacheMountPaths(m.cfg); len(paths) > 0 {
+		rootScript.WriteString(fmt.Sprintf("chown sandcastle:sandcastle %s 2>/dev/null || true\n", strings.Join(paths, " ")))
+	}
 
 	rootCmd := exec.Command("docker", "exec", "--user", "root", "-i", containerName, "bash", "-s")
 	rootCmd.Stdin = strings.NewReader(rootScript.String())
@@ -267,61 +502,37 @@ PYEOF
 		merge.Stdin = bytes.NewReader(xauthOut)
 		merge.Run()
 	}
+}
 
-	// User setup script: git config, setup commands (single docker exec as sandcastle)
-	var userScript strings.Builder
-	userScript.WriteString("git config --global 'url.https://github.com/.insteadOf' 'git@github.com:'\n")
-
-	if len(m.cfg.Defaults.Setup) > 0 && !useWarm {
-		report("Running setup commands...")
-		for _, cmd := range m.cfg.Defaults.Setup {
-			userScript.WriteString(fmt.Sprintf("(%s) || true\n", cmd))
-		}
+// copyCredentials pushes the host's current Claude credentials into a
+// container that was configured earlier, e.g. one claimed from the pool.
+func (m *Manager) copyCredentials(containerName string) {
+	hostPath := credentialsPath()
+	if _, err := os.Stat(hostPath); err != nil {
+		return
 	}
+	containerPath := "/home/sandcastle/.claude/.credentials.json"
+	exec.Command("docker", "cp", hostPath, containerName+":"+containerPath).Run()
+	exec.Command("docker", "exec", "--user", "root", containerName,
+		"chown", "sandcastle:sandcastle", containerPath).Run()
+}
 
-	report("Starting tmux session...")
-	userScript.WriteString("tmux new-session -d -s main || true\n")
-	userScript.WriteString(fmt.Sprintf(
+// gitSetup returns the script configuring git for the sandcastle user.
+func (m *Manager) gitSetup() string {
+	hostName, hostEmail := hostGitIdentity(m.projectDir)
+	sshForwarded := len(sshAgentArgs(m.cfg.Defaults.Git)) > 0
+	return gitSetupScript(m.cfg.Defaults.Git, hostName, hostEmail, sshForwarded)
+}
+
+// tmuxScript starts the main tmux session (if it isn't already running)
+// and labels its status bar with the sandbox name.
+func tmuxScript(name string) string {
+	return "tmux new-session -d -s main || true\n" + fmt.Sprintf(
 		`tmux set -t main status on && `+
 			`tmux set -t main status-left " sandcastle: %s " && `+
 			`tmux set -t main status-right " ctrl-b d to exit " && `+
 			`tmux set -t main status-left-length 40 || true`+"\n",
-		name))
-
-	userCmd := exec.Command("docker", "exec", "-i", containerName, "bash", "-s")
-	userCmd.Stdin = strings.NewReader(userScript.String())
-	userCmd.CombinedOutput()
-
-	// Auto-warm: snapshot container as warm image after first setup
-	if !useWarm && len(m.cfg.Defaults.Setup) > 0 {
-		report("Creating warm image for future fast starts...")
-		if _, err := exec.Command("docker", "commit", containerName, warmImageName(m.cfg.Project)).CombinedOutput(); err == nil {
-			saveWarmHash(m.projectDir, baseID, m.cfg.Language)
-		}
-	}
-
-	// Query port mappings
-	var ports map[string]string
-	if m.cfg.Defaults.IsHostNetwork() {
-		ports = m.identityPorts()
-	} else {
-		ports = m.queryPorts(containerName)
-	}
-
-	sb := &Sandbox{
-		Name:         name,
-		ContainerID:  containerID,
-		Status:       StatusRunning,
-		Task:         task,
-		Branch:       branch,
-		WorktreePath: wtPath,
-		Ports:        ports,
-		CreatedAt:    time.Now(),
-	}
-	m.state.Sandboxes[name] = sb
-	m.persist()
-
-	return sb, nil
+		name)
 }
 
 // MarkStopping sets a sandbox to "stopping" status so the TUI shows feedback immediately.
@@ -334,13 +545,17 @@ func (m *Manager) MarkStopping(name string) {
 	}
 }
 
-// Destroy stops and removes a sandbox container and its worktree.
+// Destroy stops and removes a sandbox container and its worktree. The
+// agent's transcripts are mirrored to the host first.
 func (m *Manager) Destroy(name string) error {
 	containerName := fmt.Sprintf("sc-%s", name)
 
 	// Slow Docker operations — run WITHOUT holding the lock so TUI doesn't freeze
+	m.SyncTranscripts(name)
 	exec.Command("docker", "stop", containerName).Run()
 	exec.Command("docker", "rm", containerName).Run()
+	removeSidecars(name)
+	removeServiceNetwork(name)
 	worktree.Remove(m.projectDir, name)
 
 	// Now grab the lock briefly to update state
@@ -357,8 +572,12 @@ func (m *Manager) Destroy(name string) error {
 // the visual flash when Bubble Tea exits alt screen during the handoff.
 func (m *Manager) ConnectCmd(name string) *exec.Cmd {
 	containerName := fmt.Sprintf("sc-%s", name)
-	return exec.Command("bash", "-c",
+	cmd := exec.Command("bash", "-c",
 		fmt.Sprintf(`printf '\033[?1049h\033[H' && exec docker exec -it %s tmux attach-session -t main`, containerName))
+	// Attach straight to the terminal: docker exec -it needs a TTY, not
+	// whatever writer the caller would otherwise hand it
+	cmd.Stdout = os.Stdout
+	return cmd
 }
 
 // List returns all sandboxes sorted by creation time.
@@ -410,10 +629,13 @@ func (m *Manager) Reconcile() error {
 		// Refresh port mappings for running containers
 		if newStatus == StatusRunning {
 			if m.cfg.Defaults.IsHostNetwork() {
-				sb.Ports = m.identityPorts()
+				sb.Ports, _ = hostNetworkPorts(m.cfg.Defaults.Ports, sb.PortOffset)
 			} else {
 				sb.Ports = m.queryPorts(containerName)
 			}
+			for k, v := range m.exposedPorts(sb) {
+				sb.Ports[k] = v
+			}
 		}
 	}
 
@@ -464,6 +686,10 @@ func (m *Manager) RefreshStatuses() {
 		} else {
 			sb.Status = dockerToStatus(status)
 		}
+
+		for i := range sb.Services {
+			sb.Services[i].Status = serviceStatus(serviceContainerName(name, sb.Services[i].Name))
+		}
 	}
 }
 
@@ -510,8 +736,12 @@ func (m *Manager) RefreshCredentials(name string) error {
 		return fmt.Errorf("sandcastle %q is not running", name)
 	}
 
-	home, _ := os.UserHomeDir()
-	hostPath := home + "/.claude/.credentials.json"
+	return m.pushCredentials(name)
+}
+
+// pushCredentials copies the host's credentials into a sandbox's container.
+func (m *Manager) pushCredentials(name string) error {
+	hostPath := credentialsPath()
 	if _, err := os.Stat(hostPath); err != nil {
 		return fmt.Errorf("no credentials file found at %s", hostPath)
 	}
@@ -611,9 +841,39 @@ func (m *Manager) imageName() string {
 	return fmt.Sprintf("sc-%s", m.cfg.Project)
 }
 
+// warmDecision reports whether the warm image can be used and describes
+// why, e.g. "warm image reused" or "setup ran: setup commands changed".
+// Returns "" when there are no setup commands to cache.
+func (m *Manager) warmDecision(baseID string, current warmInputs) (bool, string) {
+	if len(m.cfg.Defaults.Setup) == 0 {
+		return false, ""
+	}
+	if !m.warmImageExists() {
+		return false, "setup ran: no warm image yet"
+	}
+	if warmImageUpToDate(m.projectDir, baseID, m.cfg) {
+		return true, "warm image reused"
+	}
+	built := builtWarmInputs(m.cfg.Project)
+	if built == nil {
+		return false, "setup ran: warm image has no build record"
+	}
+	changed := current.changed(built)
+	if len(changed) == 0 {
+		return false, "setup ran: warm hash mismatch"
+	}
+	return false, "setup ran: " + strings.Join(changed, ", ") + " changed"
+}
+
 // baseImageID returns the Docker image ID for the base image.
 func (m *Manager) baseImageID() string {
-	out, err := exec.Command("docker", "image", "inspect", "-f", "{{.Id}}", m.imageName()).CombinedOutput()
+	return imageID(m.imageName())
+}
+
+// imageID returns the Docker image ID for an image name, or "" if it
+// doesn't exist.
+func imageID(image string) string {
+	out, err := exec.Command("docker", "image", "inspect", "-f", "{{.Id}}", image).CombinedOutput()
 	if err != nil {
 		return ""
 	}
@@ -636,6 +896,13 @@ func (m *Manager) buildImage() error {
 }
 
 func (m *Manager) buildImageWithOptions(noCache bool) error {
+	if err := m.syncDockerfile(); err != nil {
+		return err
+	}
+	base, err := m.buildDevcontainerBase(noCache)
+	if err != nil {
+		return err
+	}
 	dockerfilePath := m.cfg.Image.Dockerfile
 	uid := fmt.Sprintf("%d", os.Getuid())
 	gid := fmt.Sprintf("%d", os.Getgid())
@@ -643,6 +910,9 @@ func (m *Manager) buildImageWithOptions(noCache bool) error {
 		"--build-arg", "HOST_UID=" + uid,
 		"--build-arg", "HOST_GID=" + gid,
 	}
+	if base != "" {
+		args = append(args, "--build-arg", "BASE_IMAGE="+base)
+	}
 	if noCache {
 		args = append(args, "--no-cache")
 	}
@@ -664,21 +934,20 @@ func (m *Manager) Rebuild() error {
 }
 
 // imageUpToDate returns true if the Docker image exists locally and was built
-// from the current Dockerfile contents (hash matches the stored value).
+// from the current Dockerfile and devcontainer contents (hash matches the
+// stored value).
 func (m *Manager) imageUpToDate() bool {
 	// Check if image exists locally
 	if err := exec.Command("docker", "image", "inspect", m.imageName()).Run(); err != nil {
 		return false
 	}
 
-	// Compare Dockerfile content hash
-	dfPath := filepath.Join(m.projectDir, m.cfg.Image.Dockerfile)
-	content, err := os.ReadFile(dfPath)
+	// Compare the hash of the Dockerfile (and devcontainer) contents
+	hash, err := m.imageHash()
 	if err != nil {
 		return false
 	}
 
-	hash := fmt.Sprintf("%x", sha256.Sum256(content))
 	hashFile := filepath.Join(m.projectDir, config.Dir, ".image-hash")
 	stored, err := os.ReadFile(hashFile)
 	if err != nil {
@@ -688,28 +957,16 @@ func (m *Manager) imageUpToDate() bool {
 	return strings.TrimSpace(string(stored)) == hash
 }
 
-// saveImageHash writes the current Dockerfile's content hash to .sandcastles/.image-hash.
+// saveImageHash writes the current image inputs' content hash to .sandcastles/.image-hash.
 func (m *Manager) saveImageHash() {
-	dfPath := filepath.Join(m.projectDir, m.cfg.Image.Dockerfile)
-	content, err := os.ReadFile(dfPath)
+	hash, err := m.imageHash()
 	if err != nil {
 		return
 	}
-	hash := fmt.Sprintf("%x", sha256.Sum256(content))
 	hashFile := filepath.Join(m.projectDir, config.Dir, ".image-hash")
 	os.WriteFile(hashFile, []byte(hash+"\n"), 0o644)
 }
 
-// identityPorts returns port mappings where host and container ports are identical (for host networking).
-func (m *Manager) identityPorts() map[string]string {
-	ports := make(map[string]string)
-	for _, port := range m.cfg.Defaults.Ports {
-		p := fmt.Sprintf("%d", port)
-		ports[p] = p
-	}
-	return ports
-}
-
 func (m *Manager) queryPorts(containerName string) map[string]string {
 	ports := make(map[string]string)
 	out, err := exec.Command("docker", "port", containerName).CombinedOutput()
@@ -0,0 +1,262 @@
+package sandbox
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"debug/elf"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+	"github.com/zpdzap/sandcastles/internal/egress"
+)
+
+const (
+	egressProxyPort  = 3128
+	egressHashLabel  = "sandcastles.egress"
+	egressDeniedLog  = "egress-denied.log"
+	egressBinaryPath = "/usr/local/bin/sc-egress"
+)
+
+// egressNetworkName returns the per-project internal network used when egress is restricted.
+func egressNetworkName(project string) string {
+	return fmt.Sprintf("sc-%s-egress", project)
+}
+
+// egressProxyName returns the container name of the per-project allowlist proxy.
+func egressProxyName(project string) string {
+	return fmt.Sprintf("sc-%s-egress-proxy", project)
+}
+
+// sandboxNetwork returns the Docker network sandbox containers are attached to.
+func (m *Manager) sandboxNetwork() string {
+	if m.cfg.Defaults.IsHostNetwork() {
+		return "host"
+	}
+	if m.cfg.Defaults.Egress.Restricted() {
+		return egressNetworkName(m.cfg.Project)
+	}
+	return "bridge"
+}
+
+// egressAllowlist returns the hosts the proxy permits: the defaults plus the project's additions.
+func (m *Manager) egressAllowlist() []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, h := range append(append([]string{}, egress.DefaultAllow...), m.cfg.Defaults.Egress.Allow...) {
+		if !seen[h] {
+			seen[h] = true
+			hosts = append(hosts, h)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// egressConnectPorts returns the ports HTTPS tunnels through the proxy may
+// use: 443 plus the project's additions.
+func (m *Manager) egressConnectPorts() []int {
+	ports := append(append([]int{}, egress.DefaultConnectPorts...), m.cfg.Defaults.Egress.ConnectPorts...)
+	sort.Ints(ports)
+	return slices.Compact(ports)
+}
+
+// egressHTTPPorts returns the ports plain HTTP requests through the proxy
+// may use: 80 plus the project's additions.
+func (m *Manager) egressHTTPPorts() []int {
+	ports := append(append([]int{}, egress.DefaultHTTPPorts...), m.cfg.Defaults.Egress.HTTPPorts...)
+	sort.Ints(ports)
+	return slices.Compact(ports)
+}
+
+// egressEnv returns the proxy environment variables for allowlist mode.
+func (m *Manager) egressEnv() []string {
+	if m.cfg.Defaults.Egress.Mode != config.EgressAllowlist {
+		return nil
+	}
+	proxy := fmt.Sprintf("http://%s:%d", egressProxyName(m.cfg.Project), egressProxyPort)
+	noProxy := "localhost,127.0.0.1"
+	return []string{
+		"HTTP_PROXY=" + proxy, "HTTPS_PROXY=" + proxy,
+		"http_proxy=" + proxy, "https_proxy=" + proxy,
+		"NO_PROXY=" + noProxy, "no_proxy=" + noProxy,
+	}
+}
+
+// ensureEgress creates the internal network and, in allowlist mode, starts
+// (or restarts, if the allowlist changed) the filtering proxy.
+func (m *Manager) ensureEgress() error {
+	if err := m.cfg.Defaults.Egress.Validate(); err != nil {
+		return err
+	}
+	if !m.cfg.Defaults.Egress.Restricted() {
+		return nil
+	}
+	if m.cfg.Defaults.IsHostNetwork() {
+		return fmt.Errorf("egress mode %q requires bridge networking (network: host is set)", m.cfg.Defaults.Egress.Mode)
+	}
+
+	network := egressNetworkName(m.cfg.Project)
+	if err := ensureNetwork(network, true); err != nil {
+		return err
+	}
+	if m.cfg.Defaults.Egress.Mode != config.EgressAllowlist {
+		return nil
+	}
+
+	allow := m.egressAllowlist()
+	var ports, httpPorts []string
+	for _, port := range m.egressConnectPorts() {
+		ports = append(ports, strconv.Itoa(port))
+	}
+	for _, port := range m.egressHTTPPorts() {
+		httpPorts = append(httpPorts, strconv.Itoa(port))
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(allow, ",")+"|"+strings.Join(ports, ",")+"|"+strings.Join(httpPorts, ","))))[:16]
+	proxy := egressProxyName(m.cfg.Project)
+
+	if inspectStatus(proxy) == "running" && containerLabel(proxy, egressHashLabel) == hash {
+		return nil
+	}
+	exec.Command("docker", "rm", "-f", proxy).Run()
+
+	scBin, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating sc binary: %w", err)
+	}
+	if err := checkProxyBinary(scBin, imageArch(m.imageName())); err != nil {
+		return err
+	}
+	logDir := filepath.Join(m.projectDir, config.Dir, config.LogDir)
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return fmt.Errorf("creating log dir: %w", err)
+	}
+
+	// The proxy is the sc binary itself, mounted into the project image, so it
+	// must be a static Linux build for the image's architecture (checked
+	// above). It sits on the default bridge for outbound access and joins the
+	// internal network so sandboxes can reach it by name.
+	out, err := exec.Command("docker", "run", "-d",
+		"--name", proxy,
+		"--label", fmt.Sprintf("%s=%s", egressHashLabel, hash),
+		"--restart", "unless-stopped",
+		"-v", fmt.Sprintf("%s:%s:ro", scBin, egressBinaryPath),
+		"-v", fmt.Sprintf("%s:/logs", logDir),
+		m.imageName(),
+		egressBinaryPath, "egress-proxy",
+		"--listen", fmt.Sprintf(":%d", egressProxyPort),
+		"--allow", strings.Join(allow, ","),
+		"--connect-ports", strings.Join(ports, ","),
+		"--http-ports", strings.Join(httpPorts, ","),
+		"--log", "/logs/"+egressDeniedLog,
+	).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("starting egress proxy: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	if err := waitProxyRunning(proxy); err != nil {
+		return err
+	}
+	if out, err := exec.Command("docker", "network", "connect", network, proxy).CombinedOutput(); err != nil {
+		return fmt.Errorf("connecting egress proxy: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// proxyStartupGrace is how long the egress proxy must stay up after starting
+// before it counts as running.
+const proxyStartupGrace = time.Second
+
+// waitProxyRunning checks that the egress proxy didn't exit right after
+// starting. Under --restart unless-stopped a proxy that can't run would
+// restart forever and leave sandboxes without network, so it is removed and
+// its output returned instead.
+func waitProxyRunning(proxy string) error {
+	time.Sleep(proxyStartupGrace)
+	out, _ := exec.Command("docker", "inspect", "-f", "{{.State.Status}} {{.RestartCount}}", proxy).Output()
+	if strings.TrimSpace(string(out)) == "running 0" {
+		return nil
+	}
+	logs, _ := exec.Command("docker", "logs", "--tail", "20", proxy).CombinedOutput()
+	exec.Command("docker", "rm", "-f", proxy).Run()
+	return fmt.Errorf("egress proxy exited right after starting: %s", strings.TrimSpace(string(logs)))
+}
+
+// elfArch maps ELF machine types to Docker architecture names.
+var elfArch = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_386:     "386",
+	elf.EM_ARM:     "arm",
+	elf.EM_RISCV:   "riscv64",
+	elf.EM_PPC64:   "ppc64le",
+	elf.EM_S390:    "s390x",
+}
+
+// checkProxyBinary checks that the sc binary at path can run inside an image
+// of the given architecture: a Linux ELF file for that architecture without
+// a dynamic loader, since the image's libc may differ from the host's. An
+// empty arch skips the architecture check.
+func checkProxyBinary(path, arch string) error {
+	const rebuild = "rebuild sc with CGO_ENABLED=0 GOOS=linux (make build does this)"
+	f, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("egress allowlist runs sc inside a Linux container, but %s is not a Linux binary; %s", path, rebuild)
+	}
+	defer f.Close()
+	if got := elfArch[f.Machine]; arch != "" && got != arch {
+		return fmt.Errorf("egress allowlist runs sc inside the %s image, but %s is built for %s; %s with GOARCH=%s",
+			arch, path, cmp.Or(got, f.Machine.String()), rebuild, arch)
+	}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return fmt.Errorf("egress allowlist runs sc inside a container, but %s is dynamically linked; %s", path, rebuild)
+		}
+	}
+	return nil
+}
+
+// imageArch returns a Docker image's architecture, or "" if it can't be inspected.
+func imageArch(image string) string {
+	out, err := exec.Command("docker", "image", "inspect", "-f", "{{.Architecture}}", image).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ensureNetwork creates a Docker network if it doesn't exist yet.
+func ensureNetwork(name string, internal bool) error {
+	if exec.Command("docker", "network", "inspect", name).Run() == nil {
+		return nil
+	}
+	args := []string{"network", "create"}
+	if internal {
+		args = append(args, "--internal")
+	}
+	args = append(args, name)
+	if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("creating network %s: %s: %w", name, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// containerLabel returns the value of a label on a container, or "" if unset.
+func containerLabel(containerName, label string) string {
+	out, err := exec.Command("docker", "inspect", "-f",
+		fmt.Sprintf(`{{index .Config.Labels %q}}`, label), containerName).Output()
+	if err != nil {
+		return ""
+	}
+	value := strings.TrimSpace(string(out))
+	if value == "<no value>" {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,55 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestCheckProxyBinary(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "sc")
+	os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755)
+	if err := checkProxyBinary(script, "amd64"); err == nil || !strings.Contains(err.Error(), "not a Linux binary") {
+		t.Errorf("non-ELF file: err = %v", err)
+	}
+
+	if runtime.GOOS != "linux" {
+		t.Skip("needs a Linux test binary")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := "arm64"
+	if runtime.GOARCH == "arm64" {
+		other = "amd64"
+	}
+	if err := checkProxyBinary(self, other); err == nil || !strings.Contains(err.Error(), "GOARCH="+other) {
+		t.Errorf("wrong architecture: err = %v", err)
+	}
+}
+
+func TestEgressConnectPorts(t *testing.T) {
+	cfg := &config.Config{}
+	m := &Manager{cfg: cfg}
+	if got := m.egressConnectPorts(); !slices.Equal(got, []int{443}) {
+		t.Errorf("default ports = %v", got)
+	}
+	cfg.Defaults.Egress.ConnectPorts = []int{8443, 443}
+	if got := m.egressConnectPorts(); !slices.Equal(got, []int{443, 8443}) {
+		t.Errorf("ports = %v", got)
+	}
+
+	if got := m.egressHTTPPorts(); !slices.Equal(got, []int{80}) {
+		t.Errorf("default http ports = %v", got)
+	}
+	cfg.Defaults.Egress.HTTPPorts = []int{8080, 80}
+	if got := m.egressHTTPPorts(); !slices.Equal(got, []int{80, 8080}) {
+		t.Errorf("http ports = %v", got)
+	}
+}
@@ -0,0 +1,318 @@
+package sandbox
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+	"github.com/zpdzap/sandcastles/internal/worktree"
+)
+
+const (
+	// poolLabel marks pre-started idle containers with their project.
+	poolLabel = "sandcastles.pool"
+	// poolKeyLabel records the image and run flags a pooled container was
+	// started with, so containers from an older image or config aren't claimed.
+	poolKeyLabel = "sandcastles.pool.key"
+)
+
+// pooledContainer is an idle container waiting in the pool.
+type pooledContainer struct {
+	name    string
+	id      string
+	key     string
+	running bool
+	slot    string // placeholder directory bind-mounted at /workspace
+}
+
+// PoolStatus summarizes the container pool for `sc pool`.
+type PoolStatus struct {
+	Size  int // defaults.pool_size
+	Ready int // idle containers matching the current image and config
+	Stale int // idle containers that will be replaced on the next fill
+}
+
+// poolContainerName returns the name of a pooled container.
+func poolContainerName(project, slot string) string {
+	return fmt.Sprintf("sc-%s-pool-%s", project, slot)
+}
+
+// poolDir is where pooled containers' placeholder workspaces live.
+func (m *Manager) poolDir() string {
+	return filepath.Join(m.projectDir, config.Dir, config.PoolDir)
+}
+
+// poolEnabled reports whether new sandboxes can come from the pool. Host
+// networking gives each sandbox its own port offset and secrets are read
+// when a sandbox starts, so neither can be prepared ahead of time.
+func (m *Manager) poolEnabled() bool {
+	d := m.cfg.Defaults
+	return d.PoolSize > 0 && !d.IsHostNetwork() && len(d.Secrets) == 0
+}
+
+// poolKey hashes the image ID and run flags a container starts with.
+// Returns "" if the image doesn't exist.
+func (m *Manager) poolKey(image string) string {
+	id := imageID(image)
+	if id == "" {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s", id, strings.Join(m.runArgs(), "\n"))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// poolImage returns the image pooled containers start from: the warm image
+// when it is current, otherwise the base image.
+func (m *Manager) poolImage() string {
+	if len(m.cfg.Defaults.Setup) > 0 && m.warmImageExists() &&
+		warmImageUpToDate(m.projectDir, m.baseImageID(), m.cfg) {
+		return warmImageName(m.cfg.Project)
+	}
+	return m.imageName()
+}
+
+// pooledContainers lists the project's idle pooled containers. Claimed
+// containers keep their labels but are renamed, so they're skipped by name.
+func (m *Manager) pooledContainers() []pooledContainer {
+	out, err := exec.Command("docker", "ps", "-a",
+		"--filter", fmt.Sprintf("label=%s=%s", poolLabel, m.cfg.Project),
+		"--format", fmt.Sprintf(`{{.Names}}\t{{.ID}}\t{{.State}}\t{{.Label "%s"}}`, poolKeyLabel)).Output()
+	if err != nil {
+		return nil
+	}
+	prefix := poolContainerName(m.cfg.Project, "")
+	var pool []pooledContainer
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 || !strings.HasPrefix(fields[0], prefix) {
+			continue
+		}
+		pool = append(pool, pooledContainer{
+			name:    fields[0],
+			id:      fields[1],
+			running: fields[2] == "running",
+			key:     fields[3],
+			slot:    filepath.Join(m.poolDir(), strings.TrimPrefix(fields[0], prefix)),
+		})
+	}
+	return pool
+}
+
+// claimPooled takes an idle container started from image with the current
+// config and renames it to containerName. The rename is the claim, so two
+// sc instances can't take the same container. Returns nil if none is ready.
+func (m *Manager) claimPooled(image, containerName string) *pooledContainer {
+	key := m.poolKey(image)
+	if key == "" {
+		return nil
+	}
+	for _, c := range m.pooledContainers() {
+		if !c.running || c.key != key {
+			continue
+		}
+		if exec.Command("docker", "rename", c.name, containerName).Run() == nil {
+			c.name = containerName
+			return &c
+		}
+	}
+	return nil
+}
+
+// adoptPooled turns a claimed container's placeholder directory into the
+// sandbox's worktree. The directory is moved rather than remounted, since
+// the bind mount follows it, and a symlink is left at the old path so the
+// container still finds its workspace if Docker restarts it.
+// Returns the absolute worktree path and branch name.
+func (m *Manager) adoptPooled(c *pooledContainer, name, base string) (string, string, error) {
+	wtPath := filepath.Join(m.projectDir, config.Dir, config.WorktreeDir, name)
+	if err := os.MkdirAll(filepath.Dir(wtPath), 0o755); err != nil {
+		return "", "", err
+	}
+	if err := os.Rename(c.slot, wtPath); err != nil {
+		return "", "", fmt.Errorf("moving pooled workspace: %w", err)
+	}
+	if rel, err := filepath.Rel(filepath.Dir(c.slot), wtPath); err == nil {
+		os.Symlink(rel, c.slot)
+	}
+
+	// git worktree add accepts the existing directory as long as it's empty
+	wtPath, branch, err := worktree.Create(m.projectDir, name, base)
+	if err != nil {
+		os.Remove(filepath.Join(m.projectDir, config.Dir, config.WorktreeDir, name))
+		return "", "", err
+	}
+	return wtPath, branch, nil
+}
+
+// FillPool tops the container pool up to defaults.pool_size, first removing
+// pooled containers that stopped, are surplus, or were started from an
+// older image or config. When pooling is off it drains the pool instead.
+// Nothing is started while the base image is stale; the next /start
+// rebuilds it. Returns how many containers were started.
+func (m *Manager) FillPool() (int, error) {
+	if !m.poolMu.TryLock() {
+		return 0, nil
+	}
+	defer m.poolMu.Unlock()
+
+	if !m.poolEnabled() {
+		m.drainPool()
+		return 0, nil
+	}
+	if !m.imageUpToDate() {
+		return 0, nil
+	}
+	for _, c := range m.cfg.Defaults.Caches {
+		if err := c.Validate(); err != nil {
+			return 0, err
+		}
+	}
+
+	image := m.poolImage()
+	key := m.poolKey(image)
+	if key == "" {
+		return 0, nil
+	}
+
+	// Hold the manager lock while pruning so a concurrent Create can't
+	// claim a container whose workspace is being removed.
+	m.mu.Lock()
+	ready := 0
+	for _, c := range m.pooledContainers() {
+		if c.running && c.key == key && ready < m.cfg.Defaults.PoolSize {
+			ready++
+			continue
+		}
+		removePooled(c)
+	}
+	m.pruneSlots()
+	m.mu.Unlock()
+
+	if ready >= m.cfg.Defaults.PoolSize {
+		return 0, nil
+	}
+	if m.cfg.Defaults.Egress.Restricted() {
+		if err := m.ensureEgress(); err != nil {
+			return 0, fmt.Errorf("setting up egress: %w", err)
+		}
+	}
+	if err := m.ensureCacheVolumes(); err != nil {
+		return 0, err
+	}
+
+	started := 0
+	for ready+started < m.cfg.Defaults.PoolSize {
+		if err := m.startPooled(image, key); err != nil {
+			return started, err
+		}
+		started++
+	}
+	return started, nil
+}
+
+// DrainPool removes every idle pooled container. Returns how many were removed.
+func (m *Manager) DrainPool() int {
+	m.poolMu.Lock()
+	defer m.poolMu.Unlock()
+	return m.drainPool()
+}
+
+func (m *Manager) drainPool() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pool := m.pooledContainers()
+	for _, c := range pool {
+		removePooled(c)
+	}
+	m.pruneSlots()
+	return len(pool)
+}
+
+// Pool reports how many idle containers are ready for the current image
+// and config and how many are stale.
+func (m *Manager) Pool() PoolStatus {
+	status := PoolStatus{Size: m.cfg.Defaults.PoolSize}
+	key := m.poolKey(m.poolImage())
+	for _, c := range m.pooledContainers() {
+		if c.running && key != "" && c.key == key {
+			status.Ready++
+		} else {
+			status.Stale++
+		}
+	}
+	return status
+}
+
+// startPooled starts an idle container on a fresh placeholder workspace and
+// configures it like a new sandbox, short of the worktree and setup commands.
+func (m *Manager) startPooled(image, key string) error {
+	b := make([]byte, 4)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	slot := filepath.Join(m.poolDir(), id)
+	if err := os.MkdirAll(slot, 0o755); err != nil {
+		return err
+	}
+
+	containerName := poolContainerName(m.cfg.Project, id)
+	args := []string{
+		"run", "-d",
+		"--name", containerName,
+		"--label", fmt.Sprintf("%s=%s", poolLabel, m.cfg.Project),
+		"--label", fmt.Sprintf("%s=%s", poolKeyLabel, key),
+		"-v", fmt.Sprintf("%s:/workspace", slot),
+	}
+	args = append(args, m.runArgs()...)
+	args = append(args, image, "sleep", "infinity")
+	if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		os.Remove(slot)
+		return fmt.Errorf("docker run failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+
+	m.configureContainer(containerName)
+	setup := exec.Command("docker", "exec", "-i", containerName, "bash", "-s")
+	setup.Stdin = strings.NewReader(m.gitSetup() + "tmux new-session -d -s main || true\n")
+	setup.CombinedOutput()
+	return nil
+}
+
+// removePooled deletes an idle pooled container and its placeholder workspace.
+func removePooled(c pooledContainer) {
+	exec.Command("docker", "rm", "-f", c.name).Run()
+	os.RemoveAll(c.slot)
+}
+
+// pruneSlots removes placeholder workspaces whose container is gone and
+// symlinks left by claimed containers whose worktree has been removed.
+// Callers hold m.mu.
+func (m *Manager) pruneSlots() {
+	entries, err := os.ReadDir(m.poolDir())
+	if err != nil {
+		return
+	}
+	live := make(map[string]bool)
+	for _, c := range m.pooledContainers() {
+		live[filepath.Base(c.slot)] = true
+	}
+	for _, e := range entries {
+		path := filepath.Join(m.poolDir(), e.Name())
+		if e.Type()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(path); err != nil {
+				os.Remove(path)
+			}
+			continue
+		}
+		if e.IsDir() && !live[e.Name()] {
+			os.RemoveAll(path)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package sandbox
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestRunArgsDeterministic(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	cfg := &config.Config{Project: "app"}
+	cfg.Defaults.Env = map[string]string{"ZED": "1", "ALPHA": "2", "MID": "3", "BETA": "4"}
+	cfg.Defaults.Ports = []int{3000}
+	m := &Manager{projectDir: "/src/app", cfg: cfg}
+
+	first := m.runArgs()
+	for range 20 {
+		if got := m.runArgs(); !slices.Equal(got, first) {
+			t.Fatalf("runArgs() not deterministic:\n%v\n%v", first, got)
+		}
+	}
+
+	var env []string
+	for i, a := range first {
+		if a == "-e" && i+1 < len(first) {
+			env = append(env, first[i+1])
+		}
+	}
+	want := []string{"ALPHA=2", "BETA=4", "MID=3", "ZED=1"}
+	if !slices.Equal(env, want) {
+		t.Errorf("env args = %v, want %v", env, want)
+	}
+	if !slices.Contains(first, "0:3000") {
+		t.Errorf("runArgs() = %v, want port 3000 published", first)
+	}
+}
+
+func TestPoolEnabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults config.Defaults
+		want     bool
+	}{
+		{"off by default", config.Defaults{}, false},
+		{"bridge", config.Defaults{PoolSize: 2}, true},
+		{"host network", config.Defaults{PoolSize: 2, Network: "host"}, false},
+		{"secrets", config.Defaults{PoolSize: 2, Secrets: []config.Secret{{Name: "TOKEN"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{cfg: &config.Config{Defaults: tt.defaults}}
+			if got := m.poolEnabled(); got != tt.want {
+				t.Errorf("poolEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+// nextPortOffset returns the smallest free port offset for a sandbox with the
+// given ports: not taken by another sandbox, and not shifting any port onto a
+// host port another sandbox already uses (e.g. 3000 in one slot and 4000 in
+// the next both landing on 14000, or a port added with /expose). Only
+// config.HostPortSlots offsets are tried.
+func nextPortOffset(sandboxes map[string]*Sandbox, ports []int) (int, error) {
+	usedOffsets := make(map[int]bool, len(sandboxes))
+	usedPorts := make(map[string]bool)
+	for _, sb := range sandboxes {
+		usedOffsets[sb.PortOffset] = true
+		for _, hostPort := range sb.Ports {
+			usedPorts[hostPort] = true
+		}
+		// Ports may not be recorded yet, e.g. while the sandbox is starting
+		mapped, _ := hostNetworkPorts(ports, sb.PortOffset)
+		for _, hostPort := range mapped {
+			usedPorts[hostPort] = true
+		}
+	}
+
+next:
+	for slot := range config.HostPortSlots {
+		offset := config.HostPortBase + slot*config.HostPortStep
+		if usedOffsets[offset] {
+			continue
+		}
+		mapped, err := hostNetworkPorts(ports, offset)
+		if err != nil {
+			return 0, fmt.Errorf("no free host port offset for ports %v: %w", ports, err)
+		}
+		for _, hostPort := range mapped {
+			if usedPorts[hostPort] {
+				continue next
+			}
+		}
+		return offset, nil
+	}
+	return 0, fmt.Errorf("no free host port offset for ports %v: all %d slots are in use", ports, config.HostPortSlots)
+}
+
+// shiftPort applies a port offset. It fails if the shifted port would be out
+// of range rather than falling back to the port itself, which every other
+// sandbox would then share.
+func shiftPort(port, offset int) (int, error) {
+	if port+offset > 65535 {
+		return 0, fmt.Errorf("port %d shifted by %d is above 65535", port, offset)
+	}
+	return port + offset, nil
+}
+
+// hostNetworkPorts returns the port mappings for a host-network sandbox.
+// An offset of 0 (sandboxes created before offsets existed) maps ports to
+// themselves. Ports that can't be shifted are left out and reported.
+func hostNetworkPorts(ports []int, offset int) (map[string]string, error) {
+	mapped := make(map[string]string, len(ports))
+	var errs []error
+	for _, port := range ports {
+		hostPort, err := shiftPort(port, offset)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		mapped[fmt.Sprintf("%d", port)] = fmt.Sprintf("%d", hostPort)
+	}
+	return mapped, errors.Join(errs...)
+}
+
+// portEnv returns the SC_PORT_<port> variables telling the agent which host
+// port to bind in place of each configured port, plus PORT for the first one.
+// PORT is omitted if the project sets it explicitly.
+func portEnv(ports []int, offset int, env map[string]string) ([]string, error) {
+	sorted := make([]int, len(ports))
+	copy(sorted, ports)
+	sort.Ints(sorted)
+
+	var vars []string
+	for _, port := range sorted {
+		hostPort, err := shiftPort(port, offset)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, fmt.Sprintf("SC_PORT_%d=%d", port, hostPort))
+	}
+	if _, ok := env["PORT"]; !ok && len(ports) > 0 {
+		hostPort, _ := shiftPort(ports[0], offset) // checked above
+		vars = append(vars, fmt.Sprintf("PORT=%d", hostPort))
+	}
+	return vars, nil
+}
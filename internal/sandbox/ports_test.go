@@ -0,0 +1,115 @@
+package sandbox
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestNextPortOffset(t *testing.T) {
+	sandboxes := map[string]*Sandbox{}
+	if got, err := nextPortOffset(sandboxes, []int{8080}); err != nil || got != 10000 {
+		t.Fatalf("first offset = %d, %v, want 10000", got, err)
+	}
+
+	sandboxes["a"] = &Sandbox{PortOffset: 10000}
+	sandboxes["b"] = &Sandbox{PortOffset: 12000}
+	if got, _ := nextPortOffset(sandboxes, []int{8080}); got != 11000 {
+		t.Fatalf("offset with gap = %d, want 11000", got)
+	}
+
+	sandboxes["c"] = &Sandbox{PortOffset: 11000}
+	if got, _ := nextPortOffset(sandboxes, []int{8080}); got != 13000 {
+		t.Fatalf("offset after filling gap = %d, want 13000", got)
+	}
+}
+
+func TestNextPortOffsetCollisions(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		sandboxes map[string]*Sandbox
+		ports     []int
+		want      int
+	}{
+		{
+			// 3000 in slot 1 and 4000 in slot 0 both land on 14000
+			name:      "ports 1000 apart",
+			sandboxes: map[string]*Sandbox{"a": {PortOffset: 10000}},
+			ports:     []int{3000, 4000},
+			want:      12000,
+		},
+		{
+			name: "exposed port",
+			sandboxes: map[string]*Sandbox{"a": {PortOffset: 10000, Exposed: []int{8080},
+				Ports: map[string]string{"8080": "18080", "5173": "5173"}}},
+			ports: []int{8080},
+			want:  11000,
+		},
+		{
+			name:      "legacy sandbox without offset",
+			sandboxes: map[string]*Sandbox{"a": {Ports: map[string]string{"13000": "13000"}}},
+			ports:     []int{3000},
+			want:      11000,
+		},
+	} {
+		got, err := nextPortOffset(tc.sandboxes, tc.ports)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: nextPortOffset() = %d, %v, want %d", tc.name, got, err, tc.want)
+		}
+	}
+
+	if _, err := nextPortOffset(map[string]*Sandbox{}, []int{60000}); err == nil {
+		t.Error("nextPortOffset succeeded for a port that can't be shifted")
+	}
+
+	full := make(map[string]*Sandbox)
+	for slot := range config.HostPortSlots {
+		full[fmt.Sprint(slot)] = &Sandbox{PortOffset: config.HostPortBase + slot*config.HostPortStep}
+	}
+	if got, err := nextPortOffset(full, []int{3000}); err == nil {
+		t.Errorf("nextPortOffset with every slot taken = %d", got)
+	}
+}
+
+func TestHostNetworkPorts(t *testing.T) {
+	ports, err := hostNetworkPorts([]int{8080, 3000}, 10000)
+	if err != nil || ports["8080"] != "18080" || ports["3000"] != "13000" {
+		t.Errorf("ports = %v, %v, want 8080→18080 and 3000→13000", ports, err)
+	}
+
+	legacy, _ := hostNetworkPorts([]int{8080}, 0)
+	if legacy["8080"] != "8080" {
+		t.Errorf("zero offset should map to identity, got %v", legacy)
+	}
+
+	high, err := hostNetworkPorts([]int{60000, 3000}, 10000)
+	if err == nil || high["60000"] != "" || high["3000"] != "13000" {
+		t.Errorf("out-of-range port: ports = %v, err = %v", high, err)
+	}
+}
+
+func TestPortEnv(t *testing.T) {
+	vars, err := portEnv([]int{8080, 3000}, 10000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"SC_PORT_3000=13000", "SC_PORT_8080=18080", "PORT=18080"}
+	if len(vars) != len(want) {
+		t.Fatalf("vars = %v, want %v", vars, want)
+	}
+	for i := range want {
+		if vars[i] != want[i] {
+			t.Errorf("vars[%d] = %q, want %q", i, vars[i], want[i])
+		}
+	}
+
+	vars, _ = portEnv([]int{8080}, 10000, map[string]string{"PORT": "9000"})
+	if len(vars) != 1 {
+		t.Errorf("explicit PORT should not be overridden, got %v", vars)
+	}
+
+	if _, err := portEnv([]int{60000}, 10000, nil); err == nil {
+		t.Error("portEnv succeeded for a port that can't be shifted")
+	}
+}
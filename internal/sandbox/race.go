@@ -0,0 +1,119 @@
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/worktree"
+)
+
+// MaxRace bounds how many attempts a race can start at once.
+const MaxRace = 8
+
+// RaceOptions are the choices shared by every attempt in a race. Every
+// attempt gets the same task; the model is the only thing that can vary.
+type RaceOptions struct {
+	Base   string   // git ref every attempt starts from; empty for HEAD
+	Models []string // assigned to the attempts in turn; empty for the agent's default
+}
+
+// RaceNames returns the sandbox names for an n-attempt race: race-1 ... race-n.
+func RaceNames(race string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", race, i+1)
+	}
+	return names
+}
+
+// CreateRace creates n sandboxes for the same task, all branched from the
+// same commit and recorded as one race. It stops at the first failure and
+// returns the sandboxes created so far; starting the agents is left to the
+// caller so the attempts can begin together.
+func (m *Manager) CreateRace(race, task string, n int, opts RaceOptions, progress ProgressFunc) ([]*Sandbox, error) {
+	if n < 2 || n > MaxRace {
+		return nil, fmt.Errorf("a race needs 2 to %d attempts", MaxRace)
+	}
+	names := RaceNames(race, n)
+	for _, name := range names {
+		if _, exists := m.Get(name); exists {
+			return nil, fmt.Errorf("sandbox %q already exists", name)
+		}
+	}
+
+	// Resolve the base once so a commit landing mid-race can't split it
+	base := opts.Base
+	if base == "" {
+		base = "HEAD"
+	}
+	commit, err := worktree.ResolveRef(m.projectDir, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []*Sandbox
+	for i, name := range names {
+		report := func(phase string) {
+			if progress != nil {
+				progress(fmt.Sprintf("[%d/%d] %s", i+1, n, phase))
+			}
+		}
+		createOpts := CreateOptions{Base: commit, Race: race}
+		if len(opts.Models) > 0 {
+			createOpts.Model = opts.Models[i%len(opts.Models)]
+		}
+		sb, err := m.Create(name, task, createOpts, report)
+		if err != nil {
+			return created, fmt.Errorf("creating %s: %w", name, err)
+		}
+		created = append(created, sb)
+	}
+	return created, nil
+}
+
+// Race returns the sandboxes in a race, sorted by name.
+func (m *Manager) Race(race string) []*Sandbox {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var members []*Sandbox
+	for _, sb := range m.state.Sandboxes {
+		if race != "" && sb.Race == race {
+			members = append(members, sb)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members
+}
+
+// FinishRace merges the winning attempt of a race into the current branch
+// and returns the merge result and the other attempts, which the caller
+// stops. Nothing is stopped unless the merge succeeded.
+func (m *Manager) FinishRace(winner string) (string, []string, error) {
+	sb, ok := m.Get(winner)
+	if !ok {
+		return "", nil, fmt.Errorf("sandcastle %q not found", winner)
+	}
+	if sb.Race == "" {
+		return "", nil, fmt.Errorf("sandcastle %q is not part of a race", winner)
+	}
+
+	// A winner without commits would throw away every attempt
+	out, _ := exec.Command("git", "-C", m.projectDir, "rev-list", "--count", "HEAD.."+sb.Branch).Output()
+	if strings.TrimSpace(string(out)) == "0" {
+		return "", nil, fmt.Errorf("%s has no new commits to merge — have the agent commit first", winner)
+	}
+
+	result, err := m.Merge(winner)
+	if err != nil {
+		return "", nil, err
+	}
+	var rest []string
+	for _, other := range m.Race(sb.Race) {
+		if other.Name != winner {
+			rest = append(rest, other.Name)
+		}
+	}
+	return result, rest, nil
+}
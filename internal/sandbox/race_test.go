@@ -0,0 +1,61 @@
+package sandbox
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestRace(t *testing.T) {
+	if got := RaceNames("api", 3); !slices.Equal(got, []string{"api-1", "api-2", "api-3"}) {
+		t.Errorf("RaceNames() = %v", got)
+	}
+
+	m := &Manager{projectDir: t.TempDir(), cfg: &config.Config{}, state: newState()}
+	for _, sb := range []*Sandbox{
+		{Name: "api-2", Race: "api"},
+		{Name: "api-1", Race: "api"},
+		{Name: "api-solo"},
+		{Name: "web-1", Race: "web"},
+	} {
+		m.state.Sandboxes[sb.Name] = sb
+	}
+
+	var names []string
+	for _, sb := range m.Race("api") {
+		names = append(names, sb.Name)
+	}
+	if !slices.Equal(names, []string{"api-1", "api-2"}) {
+		t.Errorf("Race(api) = %v", names)
+	}
+	if got := m.Race(""); len(got) != 0 {
+		t.Errorf("Race(\"\") returned %d sandboxes", len(got))
+	}
+
+	if _, _, err := m.FinishRace("api-solo"); err == nil || !strings.Contains(err.Error(), "not part of a race") {
+		t.Errorf("FinishRace(api-solo) err = %v", err)
+	}
+}
+
+func TestCreateRaceErrors(t *testing.T) {
+	m := &Manager{projectDir: t.TempDir(), cfg: &config.Config{}, state: newState()}
+	m.state.Sandboxes["api-2"] = &Sandbox{Name: "api-2"}
+
+	for _, tc := range []struct {
+		race string
+		n    int
+		want string
+	}{
+		{"api", 1, "2 to"},
+		{"api", MaxRace + 1, "2 to"},
+		{"api", 3, `"api-2" already exists`},
+		{"web", 2, "unknown git ref"},
+	} {
+		_, err := m.CreateRace(tc.race, "fix it", tc.n, RaceOptions{}, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("CreateRace(%s, %d) err = %v, want %q", tc.race, tc.n, err, tc.want)
+		}
+	}
+}
@@ -1,6 +1,13 @@
 package sandbox
 
-import "time"
+import (
+	"regexp"
+	"time"
+)
+
+// ValidName matches sandcastle names and tags: alphanumeric, with hyphens
+// after the first character (e.g. my-sandbox).
+var ValidName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]*$`)
 
 // Status represents the current state of a sandbox container.
 type Status string
@@ -15,12 +22,30 @@ const (
 
 // Sandbox represents a single sandboxed container with its associated worktree.
 type Sandbox struct {
-	Name         string            `json:"name"`
-	ContainerID  string            `json:"container_id"`
-	Status       Status            `json:"status"`
-	Task         string            `json:"task"`
-	Branch       string            `json:"branch"`
-	WorktreePath string            `json:"worktree_path"`
-	Ports        map[string]string `json:"ports"`       // container port → host port
-	CreatedAt    time.Time         `json:"created_at"`
+	Name           string            `json:"name"`
+	ContainerID    string            `json:"container_id"`
+	Status         Status            `json:"status"`
+	Task           string            `json:"task"`
+	Branch         string            `json:"branch"`
+	Base           string            `json:"base,omitempty"`  // ref the branch started from; empty for HEAD
+	Model          string            `json:"model,omitempty"` // model the agent was started with; empty for its default
+	Race           string            `json:"race,omitempty"`  // race this is one attempt in, started by /race
+	WorktreePath   string            `json:"worktree_path"`
+	Ports          map[string]string `json:"ports"`                 // container port → host port
+	Exposed        []int             `json:"exposed,omitempty"`     // ports forwarded at runtime via /expose
+	PortOffset     int               `json:"port_offset,omitempty"` // host port shift in host-network mode
+	Services       []ServiceInfo     `json:"services,omitempty"`
+	Warm           string            `json:"warm,omitempty"`            // why the warm image was reused or setup ran
+	Tags           []string          `json:"tags,omitempty"`            // labels for addressing groups, e.g. /broadcast --tag
+	Verify         *Verification     `json:"verify,omitempty"`          // last run of defaults.verify
+	Iterations     int               `json:"iterations,omitempty"`      // failing checks sent back to the agent in a row
+	NeedsAttention bool              `json:"needs_attention,omitempty"` // checks still fail after max_iterations
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// ServiceInfo describes a sidecar service container belonging to a sandbox.
+type ServiceInfo struct {
+	Name   string            `json:"name"`
+	Ports  map[string]string `json:"ports,omitempty"`  // container port → host port
+	Status string            `json:"status,omitempty"` // health status if it has a healthcheck, else container state
 }
@@ -0,0 +1,190 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+// secretsMountPath is the tmpfs mount holding file secrets inside containers.
+const secretsMountPath = "/run/secrets"
+
+var validSecretName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// secretValue is a resolved secret. It only ever lives in memory.
+type secretValue struct {
+	name   string
+	value  string
+	asFile bool
+}
+
+// resolveSecrets reads the value of every configured secret from the host.
+func resolveSecrets(projectDir string, secrets []config.Secret) ([]secretValue, error) {
+	dotenvs := make(map[string]map[string]string)
+	var values []secretValue
+
+	for _, s := range secrets {
+		if !validSecretName.MatchString(s.Name) {
+			return nil, fmt.Errorf("invalid secret name %q", s.Name)
+		}
+
+		var value string
+		switch {
+		case s.FromFile != "":
+			path := s.FromFile
+			if !filepath.IsAbs(path) {
+				dir, err := config.UserDir()
+				if err != nil {
+					return nil, fmt.Errorf("secret %s: %w", s.Name, err)
+				}
+				path = filepath.Join(dir, "secrets", path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("secret %s: reading %s: %w", s.Name, path, err)
+			}
+			value = string(data)
+			if !s.AsFile {
+				value = strings.TrimRight(value, "\r\n")
+			}
+
+		case s.FromDotenv != "":
+			path := s.FromDotenv
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(projectDir, path)
+			}
+			vars, ok := dotenvs[path]
+			if !ok {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return nil, fmt.Errorf("secret %s: reading %s: %w", s.Name, path, err)
+				}
+				vars = parseDotenv(data)
+				dotenvs[path] = vars
+			}
+			if value, ok = vars[s.Name]; !ok {
+				return nil, fmt.Errorf("secret %s: not set in %s", s.Name, s.FromDotenv)
+			}
+
+		default:
+			envName := s.FromEnv
+			if envName == "" {
+				envName = s.Name
+			}
+			v, ok := os.LookupEnv(envName)
+			if !ok {
+				return nil, fmt.Errorf("secret %s: host environment variable %s is not set", s.Name, envName)
+			}
+			value = v
+		}
+
+		if !s.AsFile && strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("secret %s: multi-line values must use as_file", s.Name)
+		}
+		values = append(values, secretValue{name: s.Name, value: value, asFile: s.AsFile})
+	}
+	return values, nil
+}
+
+// parseDotenv parses KEY=value lines, ignoring comments, blank lines and an
+// optional "export " prefix. Matching single or double quotes are stripped.
+func parseDotenv(data []byte) map[string]string {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[key] = value
+	}
+	return vars
+}
+
+// writeSecretEnvFile writes env secrets to a private temp file for docker's
+// --env-file. Returns "" if there are none. The caller removes the file.
+func writeSecretEnvFile(values []secretValue) (string, error) {
+	var b strings.Builder
+	for _, v := range values {
+		if !v.asFile {
+			fmt.Fprintf(&b, "%s=%s\n", v.name, v.value)
+		}
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+
+	f, err := os.CreateTemp("", "sc-secrets-*.env")
+	if err != nil {
+		return "", fmt.Errorf("creating secrets env file: %w", err)
+	}
+	defer f.Close()
+	if err := f.Chmod(0o600); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("securing secrets env file: %w", err)
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("writing secrets env file: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// hasFileSecrets returns true if any secret is mounted as a file.
+func hasFileSecrets(values []secretValue) bool {
+	for _, v := range values {
+		if v.asFile {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFileSecrets writes the file secrets into a running container's
+// secrets tmpfs.
+func writeFileSecrets(containerName string, values []secretValue) error {
+	for _, sec := range values {
+		if !sec.asFile {
+			continue
+		}
+		write := exec.Command("docker", "exec", "-i", containerName, "sh", "-c",
+			fmt.Sprintf("umask 077 && cat > %s/%s", secretsMountPath, sec.name))
+		write.Stdin = strings.NewReader(sec.value)
+		if out, err := write.CombinedOutput(); err != nil {
+			return fmt.Errorf("writing secret %s: %s: %w", sec.name, redact(strings.TrimSpace(string(out)), values), err)
+		}
+	}
+	return nil
+}
+
+// redact replaces every secret value in s with a placeholder, so errors that
+// echo docker output can't leak them.
+func redact(s string, values []secretValue) string {
+	for _, v := range values {
+		if len(v.value) < 4 {
+			continue
+		}
+		s = strings.ReplaceAll(s, v.value, "[redacted:"+v.name+"]")
+		if trimmed := strings.TrimSpace(v.value); trimmed != v.value && len(trimmed) >= 4 {
+			s = strings.ReplaceAll(s, trimmed, "[redacted:"+v.name+"]")
+		}
+	}
+	return s
+}
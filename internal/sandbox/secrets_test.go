@@ -0,0 +1,107 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("SC_TEST_TOKEN", "tok-from-env")
+	t.Setenv("SC_TEST_OTHER", "other-value")
+
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("# comment\nexport DB_PASSWORD=\"hunter22\"\nEMPTY=\n"), 0o644)
+	secretsDir := filepath.Join(dir, "xdg", "sandcastles", "secrets")
+	os.MkdirAll(secretsDir, 0o700)
+	os.WriteFile(filepath.Join(secretsDir, "npm-token"), []byte("npm-secret\n"), 0o600)
+
+	values, err := resolveSecrets(dir, []config.Secret{
+		{Name: "SC_TEST_TOKEN"},
+		{Name: "RENAMED", FromEnv: "SC_TEST_OTHER"},
+		{Name: "DB_PASSWORD", FromDotenv: ".env"},
+		{Name: "NPM_TOKEN", FromFile: "npm-token"},
+		{Name: "npmrc", FromFile: "npm-token", AsFile: true},
+	})
+	if err != nil {
+		t.Fatalf("resolveSecrets: %v", err)
+	}
+
+	want := map[string]string{
+		"SC_TEST_TOKEN": "tok-from-env",
+		"RENAMED":       "other-value",
+		"DB_PASSWORD":   "hunter22",
+		"NPM_TOKEN":     "npm-secret",
+		"npmrc":         "npm-secret\n",
+	}
+	for _, v := range values {
+		if v.value != want[v.name] {
+			t.Errorf("%s = %q, want %q", v.name, v.value, want[v.name])
+		}
+	}
+	if !hasFileSecrets(values) {
+		t.Error("expected a file secret")
+	}
+}
+
+func TestResolveSecretsMissing(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("A=1\n"), 0o644)
+
+	tests := []config.Secret{
+		{Name: "SC_TEST_DEFINITELY_UNSET"},
+		{Name: "B", FromDotenv: ".env"},
+		{Name: "C", FromFile: filepath.Join(dir, "nope")},
+		{Name: "bad name"},
+	}
+	for _, s := range tests {
+		if _, err := resolveSecrets(dir, []config.Secret{s}); err == nil {
+			t.Errorf("expected error for %+v", s)
+		}
+	}
+}
+
+func TestSecretEnvFile(t *testing.T) {
+	path, err := writeSecretEnvFile([]secretValue{
+		{name: "TOKEN", value: "abc123"},
+		{name: "cert", value: "file-only", asFile: true},
+	})
+	if err != nil {
+		t.Fatalf("writeSecretEnvFile: %v", err)
+	}
+	defer os.Remove(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "TOKEN=abc123\n" {
+		t.Errorf("env file = %q", data)
+	}
+
+	if path, _ := writeSecretEnvFile(nil); path != "" {
+		t.Errorf("expected no file without env secrets, got %s", path)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	values := []secretValue{{name: "TOKEN", value: "s3cr3t-value"}, {name: "SHORT", value: "ab"}}
+	got := redact("error: invalid value s3cr3t-value for ab", values)
+	if strings.Contains(got, "s3cr3t-value") {
+		t.Errorf("secret not redacted: %q", got)
+	}
+	if !strings.Contains(got, "[redacted:TOKEN]") {
+		t.Errorf("missing placeholder: %q", got)
+	}
+	if !strings.Contains(got, " ab") {
+		t.Errorf("short values should be left alone: %q", got)
+	}
+}
@@ -0,0 +1,141 @@
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// serviceHealthTimeout bounds how long Create waits for services to become healthy.
+const serviceHealthTimeout = 90 * time.Second
+
+// serviceNetworkName returns the private network shared by a sandbox, its
+// services and its port forwards.
+func serviceNetworkName(name string) string {
+	return fmt.Sprintf("sc-%s-net", name)
+}
+
+// serviceContainerName returns the container name of a sandbox's service.
+// Sandbox names can't contain underscores, so this can't collide with another sandbox.
+func serviceContainerName(name, service string) string {
+	return fmt.Sprintf("sc-%s_%s", name, service)
+}
+
+// serviceNames returns the configured service names in a stable order.
+func (m *Manager) serviceNames() []string {
+	names := make([]string, 0, len(m.cfg.Services))
+	for svc := range m.cfg.Services {
+		names = append(names, svc)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// startServices starts the configured services for a sandbox on its private
+// network and waits for those with a healthcheck to report healthy.
+// The network is internal: services publish ports through the default bridge,
+// but the sandbox gains no outbound route by joining it.
+func (m *Manager) startServices(name string, report ProgressFunc) ([]ServiceInfo, error) {
+	if len(m.cfg.Services) == 0 {
+		return nil, nil
+	}
+	if m.cfg.Defaults.IsHostNetwork() {
+		return nil, fmt.Errorf("services require bridge networking (network: host is set)")
+	}
+	for _, svc := range m.serviceNames() {
+		if err := m.cfg.Services[svc].Validate(svc); err != nil {
+			return nil, err
+		}
+	}
+
+	network := serviceNetworkName(name)
+	if err := ensureNetwork(network, true); err != nil {
+		return nil, err
+	}
+
+	for _, svc := range m.serviceNames() {
+		spec := m.cfg.Services[svc]
+		report(fmt.Sprintf("Starting service %s...", svc))
+
+		containerName := serviceContainerName(name, svc)
+		args := []string{
+			"run", "-d",
+			"--name", containerName,
+			"--label", fmt.Sprintf("%s=%s", sidecarLabel, name),
+		}
+		for k, v := range spec.Env {
+			args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+		}
+		for _, port := range spec.Ports {
+			args = append(args, "-p", fmt.Sprintf("0:%d", port))
+		}
+		if spec.Healthcheck != "" {
+			args = append(args,
+				"--health-cmd", spec.Healthcheck,
+				"--health-interval", "2s",
+				"--health-timeout", "5s",
+				"--health-retries", "3")
+		}
+		args = append(args, spec.Image)
+
+		if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("starting service %s: %s: %w", svc, strings.TrimSpace(string(out)), err)
+		}
+		if out, err := exec.Command("docker", "network", "connect", "--alias", svc, network, containerName).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("connecting service %s: %s: %w", svc, strings.TrimSpace(string(out)), err)
+		}
+	}
+
+	// Wait for healthchecks. Services that never become healthy are left
+	// running — their status shows in the dashboard.
+	deadline := time.Now().Add(serviceHealthTimeout)
+	for _, svc := range m.serviceNames() {
+		if m.cfg.Services[svc].Healthcheck == "" {
+			continue
+		}
+		report(fmt.Sprintf("Waiting for %s to become healthy...", svc))
+		for time.Now().Before(deadline) {
+			status := serviceStatus(serviceContainerName(name, svc))
+			if status == "healthy" || status == "unhealthy" || status == "exited" || status == "" {
+				break
+			}
+			time.Sleep(time.Second)
+		}
+	}
+
+	return m.serviceInfos(name), nil
+}
+
+// serviceInfos returns the current status and port mappings of a sandbox's services.
+func (m *Manager) serviceInfos(name string) []ServiceInfo {
+	var infos []ServiceInfo
+	for _, svc := range m.serviceNames() {
+		containerName := serviceContainerName(name, svc)
+		infos = append(infos, ServiceInfo{
+			Name:   svc,
+			Ports:  m.queryPorts(containerName),
+			Status: serviceStatus(containerName),
+		})
+	}
+	return infos
+}
+
+// removeServiceNetwork removes a sandbox's private network, if any.
+func removeServiceNetwork(name string) {
+	exec.Command("docker", "network", "rm", serviceNetworkName(name)).Run()
+}
+
+// serviceStatus returns the health status of a container if it has a
+// healthcheck, otherwise its state ("running", "exited", ...). Empty if the
+// container doesn't exist.
+func serviceStatus(containerName string) string {
+	out, err := exec.Command("docker", "inspect", "-f",
+		"{{if .State.Health}}{{.State.Health.Status}}{{else}}{{.State.Status}}{{end}}",
+		containerName).CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
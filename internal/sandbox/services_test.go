@@ -0,0 +1,54 @@
+package sandbox
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestServiceNames(t *testing.T) {
+	if got := serviceNetworkName("api"); got != "sc-api-net" {
+		t.Errorf("serviceNetworkName() = %q", got)
+	}
+	if got := serviceContainerName("api", "db"); got != "sc-api_db" {
+		t.Errorf("serviceContainerName() = %q", got)
+	}
+
+	m := &Manager{cfg: &config.Config{Services: map[string]config.Service{
+		"redis": {Image: "redis:7"},
+		"db":    {Image: "postgres:16"},
+	}}}
+	if got := m.serviceNames(); !slices.Equal(got, []string{"db", "redis"}) {
+		t.Errorf("serviceNames() = %v", got)
+	}
+}
+
+func TestStartServicesErrors(t *testing.T) {
+	report := func(string) {}
+
+	m := &Manager{cfg: &config.Config{}}
+	if infos, err := m.startServices("api", report); infos != nil || err != nil {
+		t.Errorf("no services: %v, %v", infos, err)
+	}
+
+	// Each fails before any docker command runs
+	for _, tc := range []struct {
+		name     string
+		network  string
+		services map[string]config.Service
+		want     string
+	}{
+		{"host network", "host", map[string]config.Service{"db": {Image: "postgres:16"}}, "bridge networking"},
+		{"bad name", "", map[string]config.Service{"my_db": {Image: "postgres:16"}}, `service name "my_db"`},
+		{"no image", "", map[string]config.Service{"db": {}}, "has no image"},
+	} {
+		cfg := &config.Config{Services: tc.services}
+		cfg.Defaults.Network = tc.network
+		m := &Manager{cfg: cfg}
+		if _, err := m.startServices("api", report); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
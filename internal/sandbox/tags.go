@@ -0,0 +1,50 @@
+package sandbox
+
+import (
+	"fmt"
+	"slices"
+)
+
+// HasTag reports whether the sandbox has the given tag.
+func (sb *Sandbox) HasTag(tag string) bool {
+	return slices.Contains(sb.Tags, tag)
+}
+
+// Tag adds tags to a sandbox, e.g. to address a group of sandcastles with
+// /broadcast. Tags it already has are ignored.
+func (m *Manager) Tag(name string, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sb, ok := m.state.Sandboxes[name]
+	if !ok {
+		return fmt.Errorf("sandcastle %q not found", name)
+	}
+	for _, tag := range tags {
+		if !sb.HasTag(tag) {
+			sb.Tags = append(sb.Tags, tag)
+		}
+	}
+	slices.Sort(sb.Tags)
+	m.persist()
+	return nil
+}
+
+// Untag removes tags from a sandbox.
+func (m *Manager) Untag(name string, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sb, ok := m.state.Sandboxes[name]
+	if !ok {
+		return fmt.Errorf("sandcastle %q not found", name)
+	}
+	sb.Tags = slices.DeleteFunc(sb.Tags, func(t string) bool {
+		return slices.Contains(tags, t)
+	})
+	if len(sb.Tags) == 0 {
+		sb.Tags = nil
+	}
+	m.persist()
+	return nil
+}
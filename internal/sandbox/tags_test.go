@@ -0,0 +1,58 @@
+package sandbox
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestTagUntag(t *testing.T) {
+	m := &Manager{projectDir: t.TempDir(), cfg: &config.Config{}, state: newState()}
+	m.state.Sandboxes["api"] = &Sandbox{Name: "api"}
+
+	if err := m.Tag("api", "backend", "auth", "backend"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	sb, _ := m.Get("api")
+	if want := []string{"auth", "backend"}; !slices.Equal(sb.Tags, want) {
+		t.Errorf("Tags = %v, want %v", sb.Tags, want)
+	}
+	if !sb.HasTag("auth") || sb.HasTag("frontend") {
+		t.Errorf("HasTag mismatch for %v", sb.Tags)
+	}
+
+	if err := m.Untag("api", "auth", "backend"); err != nil {
+		t.Fatalf("Untag: %v", err)
+	}
+	if sb.Tags != nil {
+		t.Errorf("Tags = %v, want nil", sb.Tags)
+	}
+
+	// Tags survive a reload
+	m.Tag("api", "docs")
+	loaded, err := loadState(m.projectDir)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if got := loaded.Sandboxes["api"].Tags; !slices.Equal(got, []string{"docs"}) {
+		t.Errorf("persisted Tags = %v, want [docs]", got)
+	}
+
+	if err := m.Tag("missing", "x"); err == nil {
+		t.Error("Tag on unknown sandbox: want error")
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for _, name := range []string{"api", "fix-login-2", "A1"} {
+		if !ValidName.MatchString(name) {
+			t.Errorf("ValidName rejected %q", name)
+		}
+	}
+	for _, name := range []string{"", "-api", "my_api", "api.v2", "a b", "api/1"} {
+		if ValidName.MatchString(name) {
+			t.Errorf("ValidName accepted %q", name)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+	"github.com/zpdzap/sandcastles/internal/transcript"
+)
+
+// containerTranscriptDir is where Claude Code writes session JSONL inside a
+// sandbox, one subdirectory per working directory.
+const containerTranscriptDir = "/home/sandcastle/.claude/projects"
+
+// transcriptIndexFile lists every mirrored session with the task it ran.
+const transcriptIndexFile = "index.json"
+
+// TranscriptSession is one agent session mirrored to the host.
+type TranscriptSession struct {
+	Sandbox string `json:"sandbox"`
+	Task    string `json:"task"`
+	Branch  string `json:"branch"`
+	Path    string `json:"path"` // relative to the transcripts directory
+	Size    int64  `json:"size"` // file size when last summarized
+	transcript.Summary
+}
+
+// transcriptsDir is the host directory transcripts are mirrored into.
+func (m *Manager) transcriptsDir() string {
+	return filepath.Join(m.projectDir, config.Dir, config.TranscriptDir)
+}
+
+// TranscriptPath returns the absolute path of a mirrored session.
+func (m *Manager) TranscriptPath(s TranscriptSession) string {
+	return filepath.Join(m.transcriptsDir(), s.Path)
+}
+
+// SyncTranscripts copies a sandbox's session transcripts into
+// .sandcastles/transcripts/<name>/ and records new or grown sessions in the
+// index under the sandbox's current task. Works on stopped containers too.
+func (m *Manager) SyncTranscripts(name string) error {
+	m.mu.Lock()
+	sb, ok := m.state.Sandboxes[name]
+	var task, branch string
+	if ok {
+		task, branch = sb.Task, sb.Branch
+	}
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("sandbox %q not found", name)
+	}
+
+	m.transcriptMu.Lock()
+	defer m.transcriptMu.Unlock()
+
+	dir := filepath.Join(m.transcriptsDir(), name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// Copying the directory's contents ("/.") merges into what's already
+	// mirrored, so sessions from an earlier sandbox of the same name survive.
+	out, err := exec.Command("docker", "cp",
+		fmt.Sprintf("sc-%s:%s/.", name, containerTranscriptDir), dir).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if strings.Contains(msg, "Could not find the file") {
+			return nil // agent hasn't written a session yet
+		}
+		return fmt.Errorf("copying transcripts: %s: %w", msg, err)
+	}
+
+	index := m.loadTranscriptIndex()
+	byPath := make(map[string]int, len(index))
+	for i, s := range index {
+		byPath[s.Path] = i
+	}
+	changed := false
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".jsonl" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(m.transcriptsDir(), path)
+		i, seen := byPath[rel]
+		if seen && index[i].Size == info.Size() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		summary, err := transcript.Summarize(f)
+		f.Close()
+		if err != nil {
+			return nil
+		}
+		if !seen {
+			index = append(index, TranscriptSession{Sandbox: name, Task: task, Branch: branch, Path: rel})
+			i = len(index) - 1
+		}
+		index[i].Size = info.Size()
+		index[i].Summary = summary
+		changed = true
+		return nil
+	})
+	if !changed {
+		return nil
+	}
+	return m.saveTranscriptIndex(index)
+}
+
+// SyncAllTranscripts mirrors the transcripts of every running sandbox.
+// Returns the first error; the rest are still synced.
+func (m *Manager) SyncAllTranscripts() error {
+	m.mu.Lock()
+	var names []string
+	for name, sb := range m.state.Sandboxes {
+		if sb.Status == StatusRunning {
+			names = append(names, name)
+		}
+	}
+	m.mu.Unlock()
+
+	var firstErr error
+	for _, name := range names {
+		if err := m.SyncTranscripts(name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Transcripts returns the mirrored sessions, oldest first, optionally only
+// those of one sandbox. Sessions outlive their sandbox.
+func (m *Manager) Transcripts(name string) []TranscriptSession {
+	m.transcriptMu.Lock()
+	index := m.loadTranscriptIndex()
+	m.transcriptMu.Unlock()
+
+	var sessions []TranscriptSession
+	for _, s := range index {
+		if name == "" || s.Sandbox == name {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Started.Before(sessions[j].Started)
+	})
+	return sessions
+}
+
+func (m *Manager) loadTranscriptIndex() []TranscriptSession {
+	data, err := os.ReadFile(filepath.Join(m.transcriptsDir(), transcriptIndexFile))
+	if err != nil {
+		return nil
+	}
+	var index []TranscriptSession
+	json.Unmarshal(data, &index)
+	return index
+}
+
+// saveTranscriptIndex writes the index atomically, since the TUI and CLI
+// may both be syncing.
+func (m *Manager) saveTranscriptIndex(index []TranscriptSession) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling transcript index: %w", err)
+	}
+	path := filepath.Join(m.transcriptsDir(), transcriptIndexFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
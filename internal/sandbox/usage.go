@@ -0,0 +1,104 @@
+package sandbox
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/usage"
+)
+
+// UsageFilter selects which usage records are counted.
+type UsageFilter struct {
+	Sandbox string    // only this sandbox's sessions; "" for all
+	Since   time.Time // zero for no lower bound
+	Until   time.Time // exclusive; zero for no upper bound
+}
+
+// TaskUsage is the token usage and estimated cost of the sessions a sandbox
+// ran for one task.
+type TaskUsage struct {
+	Sandbox  string `json:"sandbox"`
+	Task     string `json:"task"`
+	Sessions int    `json:"sessions"`
+	usage.Totals
+}
+
+// usageCacheEntry holds a transcript's parsed usage records, reused until
+// the file grows.
+type usageCacheEntry struct {
+	size    int64
+	records []usage.Record
+}
+
+// Usage aggregates the token usage in mirrored transcripts per sandbox and
+// task, most expensive first. Costs use the built-in price table with the
+// config's pricing on top. Sync transcripts first for current numbers.
+func (m *Manager) Usage(f UsageFilter) []TaskUsage {
+	prices := usage.NewPriceTable(m.cfg.Pricing)
+
+	type key struct{ sandbox, task string }
+	groups := make(map[key]*TaskUsage)
+	for _, s := range m.Transcripts(f.Sandbox) {
+		counted := false
+		for _, rec := range m.usageRecords(s) {
+			if (!f.Since.IsZero() && rec.Time.Before(f.Since)) ||
+				(!f.Until.IsZero() && !rec.Time.Before(f.Until)) {
+				continue
+			}
+			k := key{s.Sandbox, s.Task}
+			g, ok := groups[k]
+			if !ok {
+				g = &TaskUsage{Sandbox: s.Sandbox, Task: s.Task}
+				groups[k] = g
+			}
+			if !counted {
+				g.Sessions++
+				counted = true
+			}
+			g.Add(rec, prices)
+		}
+	}
+
+	result := make([]TaskUsage, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cost != result[j].Cost {
+			return result[i].Cost > result[j].Cost
+		}
+		return result[i].Sandbox < result[j].Sandbox
+	})
+	return result
+}
+
+// usageRecords returns a session's usage records, parsing the file only if
+// it changed since the last call.
+func (m *Manager) usageRecords(s TranscriptSession) []usage.Record {
+	path := m.TranscriptPath(s)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	m.transcriptMu.Lock()
+	defer m.transcriptMu.Unlock()
+	if c, ok := m.usageCache[path]; ok && c.size == info.Size() {
+		return c.records
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	records, err := usage.Parse(f)
+	if err != nil {
+		return nil
+	}
+	if m.usageCache == nil {
+		m.usageCache = make(map[string]usageCacheEntry)
+	}
+	m.usageCache[path] = usageCacheEntry{size: info.Size(), records: records}
+	return records
+}
@@ -0,0 +1,81 @@
+package sandbox
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func writeSession(t *testing.T, m *Manager, rel string, lines ...string) {
+	t.Helper()
+	path := filepath.Join(m.transcriptsDir(), rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := ""
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assistantLine(id, ts string, output int64) string {
+	return fmt.Sprintf(`{"type":"assistant","timestamp":%q,"message":{"id":%q,"model":"claude-sonnet-4-5","usage":{"input_tokens":0,"output_tokens":%d}}}`,
+		ts, id, output)
+}
+
+func TestUsage(t *testing.T) {
+	m := &Manager{
+		projectDir: t.TempDir(),
+		cfg:        &config.Config{Pricing: map[string]config.ModelPrice{"claude-sonnet-4": {Output: 10}}},
+		state:      newState(),
+	}
+	writeSession(t, m, "api/-workspace/a.jsonl",
+		assistantLine("m1", "2025-06-01T10:00:00Z", 1000),
+		assistantLine("m2", "2025-06-02T10:00:00Z", 2000))
+	writeSession(t, m, "api/-workspace/b.jsonl",
+		assistantLine("m3", "2025-06-03T10:00:00Z", 500))
+	writeSession(t, m, "web/-workspace/c.jsonl",
+		assistantLine("m4", "2025-06-02T12:00:00Z", 100000))
+	if err := m.saveTranscriptIndex([]TranscriptSession{
+		{Sandbox: "api", Task: "fix login", Path: "api/-workspace/a.jsonl"},
+		{Sandbox: "api", Task: "add logout", Path: "api/-workspace/b.jsonl"},
+		{Sandbox: "web", Task: "restyle", Path: "web/-workspace/c.jsonl"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	all := m.Usage(UsageFilter{})
+	if len(all) != 3 || all[0].Sandbox != "web" {
+		t.Fatalf("Usage() = %+v, want 3 groups with web first", all)
+	}
+	if math.Abs(all[0].Cost-1.0) > 1e-9 {
+		t.Errorf("web cost = %v, want 1.0", all[0].Cost)
+	}
+
+	api := m.Usage(UsageFilter{
+		Sandbox: "api",
+		Since:   time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+		Until:   time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC),
+	})
+	if len(api) != 1 || api[0].Task != "fix login" || api[0].Output != 2000 || api[0].Sessions != 1 {
+		t.Errorf("Usage(api, Jun 2) = %+v", api)
+	}
+
+	// A grown file is re-read
+	writeSession(t, m, "api/-workspace/b.jsonl",
+		assistantLine("m3", "2025-06-03T10:00:00Z", 500),
+		assistantLine("m5", "2025-06-03T11:00:00Z", 700))
+	for _, u := range m.Usage(UsageFilter{Sandbox: "api"}) {
+		if u.Task == "add logout" && u.Output != 1200 {
+			t.Errorf("add logout output = %d, want 1200", u.Output)
+		}
+	}
+}
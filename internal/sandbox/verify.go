@@ -0,0 +1,253 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+// VerifyStatus is the state of a sandbox's verify run.
+type VerifyStatus string
+
+const (
+	VerifyRunning VerifyStatus = "running"
+	VerifyPassed  VerifyStatus = "passed"
+	VerifyFailed  VerifyStatus = "failed"
+)
+
+// verifyTimeout bounds each verify command.
+const verifyTimeout = 15 * time.Minute
+
+var (
+	// ErrVerifyRunning is returned when a sandbox's checks are already running.
+	ErrVerifyRunning = errors.New("checks are already running")
+	// ErrNoVerify is returned when defaults.verify is empty.
+	ErrNoVerify = errors.New("no verify commands configured (set defaults.verify)")
+)
+
+// Verification is the result of running defaults.verify in a sandbox.
+type Verification struct {
+	Status      VerifyStatus  `json:"status"`
+	Fingerprint string        `json:"fingerprint,omitempty"` // worktree state that was checked
+	Started     time.Time     `json:"started"`
+	Finished    time.Time     `json:"finished,omitzero"`
+	Checks      []CheckResult `json:"checks,omitempty"`
+	Error       string        `json:"error,omitempty"` // why the checks couldn't run
+}
+
+// CheckResult is the outcome of one verify command.
+type CheckResult struct {
+	Command  string        `json:"command"`
+	ExitCode int           `json:"exit_code"` // -1 if it couldn't run or timed out
+	Duration time.Duration `json:"duration"`
+	Output   string        `json:"output,omitempty"` // end of the output, kept for failures
+}
+
+// Failed returns the commands that didn't exit 0.
+func (v *Verification) Failed() []string {
+	var failed []string
+	for _, c := range v.Checks {
+		if c.ExitCode != 0 {
+			failed = append(failed, c.Command)
+		}
+	}
+	return failed
+}
+
+// VerifyLogPath is where the output of a sandbox's last verify run is kept.
+func (m *Manager) VerifyLogPath(name string) string {
+	return filepath.Join(m.projectDir, config.Dir, config.LogDir, fmt.Sprintf("verify-%s.log", name))
+}
+
+// VerifyNeeded reports whether a sandbox has verify commands to run and its
+// worktree changed since they last ran, so an agent flipping between
+// working and done without touching files doesn't rerun the checks.
+func (m *Manager) VerifyNeeded(name string) bool {
+	if len(m.cfg.Defaults.Verify) == 0 {
+		return false
+	}
+	m.mu.Lock()
+	sb, ok := m.state.Sandboxes[name]
+	var last *Verification
+	var wtPath string
+	if ok {
+		last, wtPath = sb.Verify, sb.WorktreePath
+	}
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if last == nil {
+		return true
+	}
+	if last.Status == VerifyRunning {
+		return false
+	}
+	return worktreeFingerprint(wtPath) != last.Fingerprint
+}
+
+// Verify runs defaults.verify in a sandbox's /workspace, one command after
+// another, and records the result on the sandbox. Every command runs even if
+// an earlier one fails; the output goes to VerifyLogPath. Passing checks
+// reset the sandbox's feedback loop.
+func (m *Manager) Verify(name string) (*Verification, error) {
+	cmds := m.cfg.Defaults.Verify
+	if len(cmds) == 0 {
+		return nil, ErrNoVerify
+	}
+
+	m.mu.Lock()
+	sb, ok := m.state.Sandboxes[name]
+	if !ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("sandbox %q not found", name)
+	}
+	if sb.Status != StatusRunning {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("sandbox %q is not running", name)
+	}
+	// A run left "running" by an instance that exited is stale after the timeout
+	if v := sb.Verify; v != nil && v.Status == VerifyRunning &&
+		time.Since(v.Started) < verifyTimeout*time.Duration(len(cmds)) {
+		m.mu.Unlock()
+		return nil, ErrVerifyRunning
+	}
+	wtPath := sb.WorktreePath
+	sb.Verify = &Verification{Status: VerifyRunning, Started: time.Now()}
+	m.persist()
+	m.mu.Unlock()
+
+	v := &Verification{Started: time.Now(), Fingerprint: worktreeFingerprint(wtPath)}
+	err := m.runVerify(name, cmds, v)
+	if err != nil {
+		v.Error = err.Error()
+	}
+	v.Finished = time.Now()
+	v.Status = VerifyPassed
+	if err != nil || len(v.Failed()) > 0 {
+		v.Status = VerifyFailed
+	}
+
+	m.mu.Lock()
+	if sb, ok := m.state.Sandboxes[name]; ok {
+		sb.Verify = v
+		if v.Status == VerifyPassed {
+			sb.Iterations = 0
+			sb.NeedsAttention = false
+		}
+		m.persist()
+	}
+	m.mu.Unlock()
+	return v, err
+}
+
+// runVerify runs the commands in the container, appending their output and
+// results to the log and to v.Checks.
+func (m *Manager) runVerify(name string, cmds []string, v *Verification) error {
+	logPath := m.VerifyLogPath(name)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		return fmt.Errorf("creating log dir: %w", err)
+	}
+	log, err := os.Create(logPath)
+	if err != nil {
+		return fmt.Errorf("creating verify log: %w", err)
+	}
+	defer log.Close()
+
+	containerName := fmt.Sprintf("sc-%s", name)
+	for _, command := range cmds {
+		fmt.Fprintf(log, "$ %s\n", command)
+		ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+		cmd := exec.CommandContext(ctx, "docker", "exec", "-w", "/workspace", containerName, "bash", "-lc", command)
+		tail := &tailBuffer{max: checkOutputBytes}
+		cmd.Stdout = io.MultiWriter(log, tail)
+		cmd.Stderr = cmd.Stdout
+		start := time.Now()
+		runErr := cmd.Run()
+		timedOut := ctx.Err() == context.DeadlineExceeded
+		cancel()
+
+		result := CheckResult{Command: command, Duration: time.Since(start).Round(100 * time.Millisecond)}
+		var exitErr *exec.ExitError
+		switch {
+		case timedOut:
+			result.ExitCode = -1
+			fmt.Fprintf(log, "[timed out after %s]\n\n", verifyTimeout)
+			v.Checks = append(v.Checks, result)
+			continue
+		case errors.As(runErr, &exitErr):
+			result.ExitCode = exitErr.ExitCode()
+		case runErr != nil:
+			result.ExitCode = -1
+			fmt.Fprintf(log, "%v\n", runErr)
+		}
+		fmt.Fprintf(log, "[exit %d after %s]\n\n", result.ExitCode, result.Duration)
+		if result.ExitCode != 0 {
+			result.Output = tail.String()
+		}
+		v.Checks = append(v.Checks, result)
+	}
+	return nil
+}
+
+// checkOutputBytes is how much of a failed check's output is kept on the
+// sandbox, e.g. to send back to the agent.
+const checkOutputBytes = 4096
+
+// ansiRe matches terminal escape sequences, such as colors in test output.
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	buf     []byte
+	max     int
+	dropped bool // earlier output was discarded
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > 2*t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+		t.dropped = true
+	}
+	return len(p), nil
+}
+
+// String returns the kept output from its first whole line, without
+// escape sequences.
+func (t *tailBuffer) String() string {
+	b := t.buf
+	if len(b) > t.max || t.dropped {
+		b = b[max(0, len(b)-t.max):]
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			b = b[i+1:]
+		}
+	}
+	return strings.TrimSpace(ansiRe.ReplaceAllString(string(b), ""))
+}
+
+// worktreeFingerprint hashes a worktree's HEAD and uncommitted changes.
+func worktreeFingerprint(wtPath string) string {
+	h := sha256.New()
+	for _, args := range [][]string{
+		{"rev-parse", "HEAD"},
+		{"status", "--porcelain"},
+		{"diff", "HEAD"},
+	} {
+		out, _ := exec.Command("git", append([]string{"-C", wtPath}, args...)...).Output()
+		h.Write(out)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,99 @@
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+func TestVerifyNeeded(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, out)
+		}
+	}
+
+	m := &Manager{projectDir: dir, cfg: &config.Config{}, state: newState()}
+	m.state.Sandboxes["api"] = &Sandbox{Name: "api", WorktreePath: dir}
+	if m.VerifyNeeded("api") {
+		t.Error("VerifyNeeded with no verify commands")
+	}
+
+	m.cfg.Defaults.Verify = []string{"go test ./..."}
+	if !m.VerifyNeeded("api") {
+		t.Error("VerifyNeeded = false before the first run")
+	}
+
+	sb := m.state.Sandboxes["api"]
+	sb.Verify = &Verification{Status: VerifyPassed, Fingerprint: worktreeFingerprint(dir)}
+	if m.VerifyNeeded("api") {
+		t.Error("VerifyNeeded = true with an unchanged worktree")
+	}
+
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644)
+	if !m.VerifyNeeded("api") {
+		t.Error("VerifyNeeded = false after a file was added")
+	}
+
+	sb.Verify = &Verification{Status: VerifyRunning, Started: time.Now()}
+	if m.VerifyNeeded("api") {
+		t.Error("VerifyNeeded = true while checks are running")
+	}
+}
+
+func TestVerificationFailed(t *testing.T) {
+	v := &Verification{Checks: []CheckResult{
+		{Command: "go vet ./...", ExitCode: 0},
+		{Command: "go test ./...", ExitCode: 1},
+		{Command: "npm run lint", ExitCode: -1},
+	}}
+	if got := v.Failed(); !slices.Equal(got, []string{"go test ./...", "npm run lint"}) {
+		t.Errorf("Failed() = %v", got)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	tail := &tailBuffer{max: 22}
+	for i := 0; i < 10; i++ {
+		tail.Write([]byte("line\n"))
+	}
+	tail.Write([]byte("\x1b[31mFAIL\x1b[0m\n"))
+	if got := tail.String(); got != "line\nFAIL" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestVerifyCouldNotRun(t *testing.T) {
+	dir := t.TempDir()
+	// A file where the log directory should be makes runVerify fail before
+	// any command runs
+	os.WriteFile(filepath.Join(dir, config.Dir), nil, 0o644)
+
+	m := &Manager{projectDir: dir, cfg: &config.Config{}, state: newState()}
+	m.cfg.Defaults.Verify = []string{"go test ./..."}
+	m.cfg.Defaults.MaxIterations = 3
+	m.state.Sandboxes["api"] = &Sandbox{Name: "api", Status: StatusRunning, WorktreePath: dir}
+
+	v, err := m.Verify("api")
+	if err == nil {
+		t.Fatal("Verify succeeded without a log directory")
+	}
+	if v.Status != VerifyFailed || v.Error == "" || len(v.Checks) != 0 {
+		t.Errorf("Verify = %+v", v)
+	}
+	if prompt := m.Feedback("api", v); prompt != "" {
+		t.Errorf("Feedback = %q, want none for checks that didn't run", prompt)
+	}
+	if sb := m.state.Sandboxes["api"]; sb.Iterations != 0 || sb.NeedsAttention {
+		t.Errorf("Iterations = %d, NeedsAttention = %v", sb.Iterations, sb.NeedsAttention)
+	}
+}
@@ -2,34 +2,86 @@ package sandbox
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/zpdzap/sandcastles/internal/config"
 )
 
-// manifestFiles maps language to the dependency manifest files to track.
+// manifestFiles maps language to the dependency manifest files (names or
+// globs) to track.
 var manifestFiles = map[string][]string{
 	"node":   {"package.json", "package-lock.json"},
 	"go":     {"go.mod", "go.sum"},
 	"python": {"requirements.txt", "pyproject.toml"},
 	"rust":   {"Cargo.toml", "Cargo.lock"},
+	"java":   {"pom.xml", "build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts", "gradle.lockfile"},
+	"ruby":   {"Gemfile", "Gemfile.lock"},
+	"php":    {"composer.json", "composer.lock"},
+	"dotnet": {"*.sln", "*.csproj", "*.fsproj", "packages.lock.json", "Directory.Packages.props"},
+	"elixir": {"mix.exs", "mix.lock"},
 }
 
-// manifestHash computes a SHA256 hash of all relevant manifest file contents
-// for the given language. Files that don't exist are skipped.
-func manifestHash(projectDir, language string) string {
-	files := manifestFiles[language]
-	sorted := make([]string, len(files))
-	copy(sorted, files)
-	sort.Strings(sorted)
+// warmManifests returns the manifest files tracked for the warm image: the
+// per-language manifests anywhere in the project (see config.FindFiles) plus
+// the files matching defaults.warm_manifests. Paths are relative and sorted.
+func warmManifests(projectDir string, cfg *config.Config) []string {
+	var names []string
+	for _, lang := range cfg.Language {
+		names = append(names, manifestFiles[lang]...)
+	}
+	var rooted []string
+	for _, p := range cfg.Defaults.WarmManifests {
+		if strings.Contains(p, "/") {
+			rooted = append(rooted, p)
+		} else {
+			names = append(names, p)
+		}
+	}
 
+	files := config.FindFiles(projectDir, names)
+	for _, p := range rooted {
+		matches, _ := filepath.Glob(filepath.Join(projectDir, filepath.FromSlash(p)))
+		for _, match := range matches {
+			if rel, err := filepath.Rel(projectDir, match); err == nil && !slices.Contains(files, rel) {
+				files = append(files, rel)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// committedFileReader returns a function reading project files (relative
+// paths) as committed at HEAD, which is what warm builds and sandboxes check
+// out, so uncommitted edits don't make the warm image look stale. Outside a
+// git repository it reads the working copy.
+func committedFileReader(projectDir string) func(string) ([]byte, error) {
+	if exec.Command("git", "-C", projectDir, "rev-parse", "--verify", "-q", "HEAD").Run() != nil {
+		return func(rel string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(projectDir, rel))
+		}
+	}
+	return func(rel string) ([]byte, error) {
+		return exec.Command("git", "-C", projectDir, "show", "HEAD:"+filepath.ToSlash(rel)).Output()
+	}
+}
+
+// manifestHash computes a SHA256 hash of the committed contents of the warm
+// manifests.
+func manifestHash(projectDir string, cfg *config.Config) string {
+	read := committedFileReader(projectDir)
 	h := sha256.New()
-	for _, f := range sorted {
-		data, err := os.ReadFile(filepath.Join(projectDir, f))
+	for _, f := range warmManifests(projectDir, cfg) {
+		data, err := read(f)
 		if err != nil {
 			continue
 		}
@@ -48,27 +100,226 @@ func warmHashPath(projectDir string) string {
 	return filepath.Join(projectDir, config.Dir, ".warm-hash")
 }
 
-// computeWarmHash combines the base image ID and manifest hash into a single hash.
-func computeWarmHash(projectDir, baseImageID, language string) string {
-	mh := manifestHash(projectDir, language)
-	combined := fmt.Sprintf("%s:%s", baseImageID, mh)
-	return fmt.Sprintf("%x", sha256.Sum256([]byte(combined)))
+// warmLabelPrefix prefixes the image labels recording what a warm image was
+// built from, e.g. sandcastles.warm.setup=<hash>.
+const warmLabelPrefix = "sandcastles.warm."
+
+// warmComponents are the inputs of a warm image, in hashing order, with the
+// description shown when one of them changes.
+var warmComponents = []struct{ key, desc string }{
+	{"base", "base image"},
+	{"manifests", "dependency manifests"},
+	{"setup", "setup commands"},
+	{"config", "config"},
+	{"files", "setup files"},
+}
+
+// warmInputs maps each warm component to a hash of its current state.
+type warmInputs map[string]string
+
+// computeWarmInputs hashes everything the warm image depends on: the base
+// image, dependency manifests, setup commands, the config fields that affect
+// setup, and project files the setup commands reference. Files are hashed as
+// committed at HEAD (see committedFileReader).
+func computeWarmInputs(projectDir, baseImageID string, cfg *config.Config) warmInputs {
+	// Secrets are tracked by source only; their values never touch disk
+	configJSON, _ := json.Marshal(struct {
+		Language     config.Languages
+		Env          map[string]string
+		Mounts       []string
+		Secrets      []config.Secret
+		Caches       []config.Cache
+		DockerSocket bool
+		Network      string
+		Egress       config.Egress
+		Git          config.Git
+	}{cfg.Language, cfg.Defaults.Env, cfg.Defaults.Mounts, cfg.Defaults.Secrets, cfg.Defaults.Caches, cfg.Defaults.DockerSocket, cfg.Defaults.Network,
+		cfg.Defaults.Egress, cfg.Defaults.Git})
+
+	read := committedFileReader(projectDir)
+	files := sha256.New()
+	for _, f := range setupFiles(projectDir, cfg.Defaults.Setup) {
+		data, err := read(f)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(files, "%s:%x\n", f, sha256.Sum256(data))
+	}
+
+	return warmInputs{
+		"base":      baseImageID,
+		"manifests": manifestHash(projectDir, cfg),
+		"setup":     fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(cfg.Defaults.Setup, "\n")))),
+		"config":    fmt.Sprintf("%x", sha256.Sum256(configJSON)),
+		"files":     fmt.Sprintf("%x", files.Sum(nil)),
+	}
+}
+
+// hash combines the components into the warm hash.
+func (w warmInputs) hash() string {
+	h := sha256.New()
+	for _, c := range warmComponents {
+		fmt.Fprintf(h, "%s=%s\n", c.key, w[c.key])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// commitChanges returns `docker commit --change` arguments labeling the
+// image with its inputs.
+func (w warmInputs) commitChanges() []string {
+	var args []string
+	for _, c := range warmComponents {
+		args = append(args, "--change", fmt.Sprintf("LABEL %s%s=%s", warmLabelPrefix, c.key, w[c.key]))
+	}
+	return args
+}
+
+// runEnvNames returns the names of the variables set with -e in docker run
+// arguments, in order.
+func runEnvNames(args []string) []string {
+	var names []string
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] != "-e" {
+			continue
+		}
+		name, _, _ := strings.Cut(args[i+1], "=")
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resetEnvChanges returns `docker commit --change` arguments restoring the
+// given variables to their value in the base image's environment (or blanking
+// them), so run-time settings like proxies, the SSH agent socket and secrets
+// aren't baked into a committed image. Sandboxes set them again when started.
+func resetEnvChanges(names, baseEnv []string) []string {
+	base := make(map[string]string, len(baseEnv))
+	for _, kv := range baseEnv {
+		k, v, _ := strings.Cut(kv, "=")
+		base[k] = v
+	}
+	var args []string
+	for _, name := range names {
+		args = append(args, "--change", fmt.Sprintf("ENV %s=%s", name, strconv.Quote(base[name])))
+	}
+	return args
+}
+
+// imageEnv returns an image's configured environment as KEY=value pairs.
+func imageEnv(image string) []string {
+	out, err := exec.Command("docker", "image", "inspect", "-f", "{{json .Config.Env}}", image).Output()
+	if err != nil {
+		return nil
+	}
+	var env []string
+	json.Unmarshal(out, &env)
+	return env
+}
+
+// changed describes the components that differ from the inputs an image was
+// built from.
+func (w warmInputs) changed(built warmInputs) []string {
+	var descs []string
+	for _, c := range warmComponents {
+		if w[c.key] != built[c.key] {
+			descs = append(descs, c.desc)
+		}
+	}
+	return descs
+}
+
+// builtWarmInputs reads the inputs recorded on the warm image, or nil if the
+// image is missing or predates input labels.
+func builtWarmInputs(project string) warmInputs {
+	out, err := exec.Command("docker", "image", "inspect", "-f", "{{json .Config.Labels}}", warmImageName(project)).Output()
+	if err != nil {
+		return nil
+	}
+	var labels map[string]string
+	if json.Unmarshal(out, &labels) != nil {
+		return nil
+	}
+	built := make(warmInputs)
+	for k, v := range labels {
+		if key, ok := strings.CutPrefix(k, warmLabelPrefix); ok {
+			built[key] = v
+		}
+	}
+	if len(built) == 0 {
+		return nil
+	}
+	return built
+}
+
+// computeWarmHash returns the combined hash of the current warm inputs.
+func computeWarmHash(projectDir, baseImageID string, cfg *config.Config) string {
+	return computeWarmInputs(projectDir, baseImageID, cfg).hash()
 }
 
 // warmImageUpToDate checks if the warm image hash matches the current state.
-func warmImageUpToDate(projectDir, baseImageID, language string) bool {
+func warmImageUpToDate(projectDir, baseImageID string, cfg *config.Config) bool {
 	stored, err := os.ReadFile(warmHashPath(projectDir))
 	if err != nil {
 		return false
 	}
-	current := computeWarmHash(projectDir, baseImageID, language)
+	current := computeWarmHash(projectDir, baseImageID, cfg)
 	return strings.TrimSpace(string(stored)) == current
 }
 
-// saveWarmHash writes the current warm hash to disk.
-func saveWarmHash(projectDir, baseImageID, language string) {
-	hash := computeWarmHash(projectDir, baseImageID, language)
-	os.WriteFile(warmHashPath(projectDir), []byte(hash+"\n"), 0o644)
+// setupFiles returns the project files (relative paths) that setup commands
+// reference, such as scripts or requirement files, following `cd` into
+// subdirectories. /workspace paths are mapped back to the project.
+func setupFiles(projectDir string, setup []string) []string {
+	var files []string
+	isSep := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("&|;()<>'\"", r)
+	}
+	for _, cmd := range setup {
+		dir := projectDir
+		prev := ""
+		for _, tok := range strings.FieldsFunc(cmd, isSep) {
+			p := resolveSetupPath(projectDir, dir, tok)
+			if prev == "cd" && p != "" {
+				dir = p
+			} else if p != "" && !strings.HasPrefix(tok, "-") {
+				if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+					if rel, err := filepath.Rel(projectDir, p); err == nil && !slices.Contains(files, rel) {
+						files = append(files, rel)
+					}
+				}
+			}
+			prev = tok
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// resolveSetupPath maps a path in a setup command to a host path inside the
+// project, or "" if it points elsewhere.
+func resolveSetupPath(projectDir, dir, tok string) string {
+	var p string
+	switch {
+	case tok == "/workspace" || strings.HasPrefix(tok, "/workspace/"):
+		p = filepath.Join(projectDir, strings.TrimPrefix(tok, "/workspace"))
+	case filepath.IsAbs(tok) || strings.HasPrefix(tok, "~") || strings.HasPrefix(tok, "$"):
+		return ""
+	default:
+		p = filepath.Join(dir, tok)
+	}
+	if p != projectDir && !strings.HasPrefix(p, projectDir+string(filepath.Separator)) {
+		return ""
+	}
+	return p
+}
+
+// saveWarmHash writes the hash of the inputs a warm image was built from to
+// disk. The image itself carries the per-component hashes as labels (see
+// commitChanges).
+func saveWarmHash(projectDir string, inputs warmInputs) {
+	os.WriteFile(warmHashPath(projectDir), []byte(inputs.hash()+"\n"), 0o644)
 }
 
 // removeWarmHash deletes the warm hash file.
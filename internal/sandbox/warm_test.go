@@ -2,64 +2,224 @@ package sandbox
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/config"
 )
 
+// langs returns a config for a project with the given languages.
+func langs(languages ...string) *config.Config {
+	return &config.Config{Language: languages}
+}
+
 func TestManifestHash(t *testing.T) {
 	dir := t.TempDir()
 
 	// No manifest files -> empty hash (but still deterministic)
-	h1 := manifestHash(dir, "unknown")
+	h1 := manifestHash(dir, langs("unknown"))
 	if h1 == "" {
 		t.Fatal("expected non-empty hash even with no manifests")
 	}
 
 	// Create a package.json
 	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name":"test"}`), 0o644)
-	h2 := manifestHash(dir, "node")
+	h2 := manifestHash(dir, langs("node"))
 	if h2 == h1 {
 		t.Fatal("expected different hash after adding manifest")
 	}
 
 	// Same content -> same hash
-	h3 := manifestHash(dir, "node")
+	h3 := manifestHash(dir, langs("node"))
 	if h3 != h2 {
 		t.Fatal("expected same hash for same content")
 	}
 
 	// Change content -> different hash
 	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name":"changed"}`), 0o644)
-	h4 := manifestHash(dir, "node")
+	h4 := manifestHash(dir, langs("node"))
 	if h4 == h2 {
 		t.Fatal("expected different hash after content change")
 	}
 }
 
+func TestManifestHashSubdirectories(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x"), 0o644)
+	os.MkdirAll(filepath.Join(dir, "frontend"), 0o755)
+	os.WriteFile(filepath.Join(dir, "frontend", "package.json"), []byte(`{}`), 0o644)
+
+	h1 := manifestHash(dir, langs("go", "node"))
+	if h1 == manifestHash(dir, langs("go")) {
+		t.Fatal("expected nested node manifest to contribute to the hash")
+	}
+
+	os.WriteFile(filepath.Join(dir, "frontend", "package.json"), []byte(`{"name":"ui"}`), 0o644)
+	if manifestHash(dir, langs("go", "node")) == h1 {
+		t.Fatal("expected different hash after nested manifest change")
+	}
+}
+
 func TestWarmHash(t *testing.T) {
 	dir := t.TempDir()
 	scDir := filepath.Join(dir, ".sandcastles")
 	os.MkdirAll(scDir, 0o755)
 
 	// No stored hash -> not up to date
-	if warmImageUpToDate(dir, "abc123", "node") {
+	if warmImageUpToDate(dir, "abc123", langs("node")) {
 		t.Fatal("expected not up to date with no stored hash")
 	}
 
 	// Save and verify
-	saveWarmHash(dir, "abc123", "node")
-	if !warmImageUpToDate(dir, "abc123", "node") {
+	saveWarmHash(dir, computeWarmInputs(dir, "abc123", langs("node")))
+	if !warmImageUpToDate(dir, "abc123", langs("node")) {
 		t.Fatal("expected up to date after saving")
 	}
 
 	// Different base image -> stale
-	if warmImageUpToDate(dir, "def456", "node") {
+	if warmImageUpToDate(dir, "def456", langs("node")) {
 		t.Fatal("expected stale with different base image ID")
 	}
 
 	// Change manifest -> stale
 	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name":"new"}`), 0o644)
-	if warmImageUpToDate(dir, "abc123", "node") {
+	if warmImageUpToDate(dir, "abc123", langs("node")) {
 		t.Fatal("expected stale after manifest change")
 	}
 }
+
+func TestManifestHashWarmManifests(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "web"), 0o755)
+	os.WriteFile(filepath.Join(dir, "web", "pnpm-lock.yaml"), []byte("a"), 0o644)
+	os.WriteFile(filepath.Join(dir, "tools.lock"), []byte("a"), 0o644)
+
+	cfg := langs("node")
+	cfg.Defaults.WarmManifests = []string{"pnpm-lock.yaml", "*.lock"}
+	got := warmManifests(dir, cfg)
+	// "*.lock" has no slash, so it matches by name anywhere
+	if len(got) != 2 || got[0] != "tools.lock" || got[1] != filepath.Join("web", "pnpm-lock.yaml") {
+		t.Errorf("warmManifests = %v", got)
+	}
+
+	cfg.Defaults.WarmManifests = []string{"web/*.yaml"}
+	if got := warmManifests(dir, cfg); len(got) != 1 || got[0] != filepath.Join("web", "pnpm-lock.yaml") {
+		t.Errorf("warmManifests(rooted) = %v", got)
+	}
+
+	h1 := manifestHash(dir, cfg)
+	os.WriteFile(filepath.Join(dir, "web", "pnpm-lock.yaml"), []byte("b"), 0o644)
+	if manifestHash(dir, cfg) == h1 {
+		t.Error("expected different hash after warm manifest change")
+	}
+}
+
+func TestSetupFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "scripts"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "api"), 0o755)
+	os.WriteFile(filepath.Join(dir, "scripts", "setup.sh"), []byte("#!/bin/sh"), 0o755)
+	os.WriteFile(filepath.Join(dir, "api", "requirements-dev.txt"), []byte("pytest"), 0o644)
+
+	got := setupFiles(dir, []string{
+		"cd /workspace && ./scripts/setup.sh --fast",
+		"cd /workspace/api && pip install -r requirements-dev.txt",
+		"bash /workspace/scripts/setup.sh",
+		"cat /etc/hosts ~/.bashrc missing.txt",
+	})
+	want := []string{filepath.Join("api", "requirements-dev.txt"), filepath.Join("scripts", "setup.sh")}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("setupFiles = %v, want %v", got, want)
+	}
+}
+
+func TestWarmInputsChanged(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "setup.sh"), []byte("v1"), 0o644)
+	cfg := langs("node")
+	cfg.Defaults.Setup = []string{"cd /workspace && sh setup.sh"}
+
+	built := computeWarmInputs(dir, "abc123", cfg)
+	if changed := computeWarmInputs(dir, "abc123", cfg).changed(built); len(changed) != 0 {
+		t.Fatalf("expected no changes, got %v", changed)
+	}
+
+	os.WriteFile(filepath.Join(dir, "setup.sh"), []byte("v2"), 0o644)
+	cfg.Defaults.Env = map[string]string{"NODE_ENV": "test"}
+	changed := computeWarmInputs(dir, "abc123", cfg).changed(built)
+	if len(changed) != 2 || changed[0] != "config" || changed[1] != "setup files" {
+		t.Errorf("changed = %v, want [config setup files]", changed)
+	}
+
+	cfg.Defaults.Setup = append(cfg.Defaults.Setup, "make deps")
+	if built.hash() == computeWarmHash(dir, "abc123", cfg) {
+		t.Error("expected setup change to change the warm hash")
+	}
+}
+
+func TestWarmInputsRunSettings(t *testing.T) {
+	dir := t.TempDir()
+	cfg := langs("node")
+	built := computeWarmInputs(dir, "abc123", cfg)
+
+	cfg.Defaults.Egress = config.Egress{Mode: config.EgressAllowlist}
+	if changed := computeWarmInputs(dir, "abc123", cfg).changed(built); len(changed) != 1 || changed[0] != "config" {
+		t.Errorf("egress: changed = %v, want [config]", changed)
+	}
+
+	cfg.Defaults.Egress = config.Egress{}
+	cfg.Defaults.Git = config.Git{SSHAgent: true}
+	if changed := computeWarmInputs(dir, "abc123", cfg).changed(built); len(changed) != 1 || changed[0] != "config" {
+		t.Errorf("git: changed = %v, want [config]", changed)
+	}
+}
+
+func TestResetEnvChanges(t *testing.T) {
+	args := []string{"run", "-d", "-e", "HTTP_PROXY=http://proxy:3128", "-v", "/a:/b",
+		"-e", "ANTHROPIC_API_KEY", "-e", "PATH=/opt/bin", "-e", "HTTP_PROXY=http://other", "image"}
+	names := runEnvNames(args)
+	if want := []string{"HTTP_PROXY", "ANTHROPIC_API_KEY", "PATH"}; !slices.Equal(names, want) {
+		t.Fatalf("runEnvNames() = %v, want %v", names, want)
+	}
+
+	got := resetEnvChanges(names, []string{"PATH=/usr/bin:/bin", "LANG=C.UTF-8"})
+	want := []string{
+		"--change", `ENV HTTP_PROXY=""`,
+		"--change", `ENV ANTHROPIC_API_KEY=""`,
+		"--change", `ENV PATH="/usr/bin:/bin"`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("resetEnvChanges() = %q, want %q", got, want)
+	}
+}
+
+func TestWarmInputsCommitted(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %v", args, out, err)
+		}
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name":"v1"}`), 0o644)
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+
+	cfg := langs("node")
+	built := computeWarmInputs(dir, "abc123", cfg)
+
+	// Uncommitted edits aren't part of HEAD, which is what gets checked out
+	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name":"v2"}`), 0o644)
+	if changed := computeWarmInputs(dir, "abc123", cfg).changed(built); len(changed) != 0 {
+		t.Errorf("uncommitted edit: changed = %v, want none", changed)
+	}
+
+	git("commit", "-q", "-am", "bump")
+	if changed := computeWarmInputs(dir, "abc123", cfg).changed(built); len(changed) != 1 || changed[0] != "dependency manifests" {
+		t.Errorf("committed edit: changed = %v, want [dependency manifests]", changed)
+	}
+}
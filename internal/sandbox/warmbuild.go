@@ -0,0 +1,164 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/worktree"
+)
+
+// ErrWarmInProgress is returned by BuildWarm when another build is running.
+var ErrWarmInProgress = errors.New("a warm image build is already running")
+
+// warmBuildContainerName is the throwaway container warm images are built in.
+func warmBuildContainerName(project string) string {
+	return fmt.Sprintf("sc-%s-warm-build", project)
+}
+
+// WarmNeeded reports whether a warm image should be built: the project has
+// setup commands, the base image is current, and the warm image is missing
+// or stale.
+func (m *Manager) WarmNeeded() bool {
+	if len(m.cfg.Defaults.Setup) == 0 || !m.imageUpToDate() {
+		return false
+	}
+	return !m.WarmCurrent()
+}
+
+// WarmCurrent reports whether the warm image exists and matches the current
+// base image, manifests, setup and config.
+func (m *Manager) WarmCurrent() bool {
+	return m.imageUpToDate() && m.warmImageExists() &&
+		warmImageUpToDate(m.projectDir, m.baseImageID(), m.cfg)
+}
+
+// BuildWarm builds a fresh warm image from the current HEAD in a throwaway
+// container: the setup commands run against a detached worktree and the
+// container is committed, so no sandbox pays the setup cost. The base image
+// is built first if needed. Returns why the warm image was rebuilt.
+func (m *Manager) BuildWarm(progress ProgressFunc) (string, error) {
+	if !m.warmMu.TryLock() {
+		return "", ErrWarmInProgress
+	}
+	defer m.warmMu.Unlock()
+
+	report := func(phase string) {
+		if progress != nil {
+			progress(phase)
+		}
+	}
+
+	if len(m.cfg.Defaults.Setup) == 0 {
+		return "", fmt.Errorf("no setup commands configured; nothing to warm")
+	}
+	if !m.imageUpToDate() {
+		report("Building image...")
+		if err := m.buildImage(); err != nil {
+			return "", fmt.Errorf("building image: %w", err)
+		}
+	}
+
+	secrets, err := resolveSecrets(m.projectDir, m.cfg.Defaults.Secrets)
+	if err != nil {
+		return "", err
+	}
+	if m.cfg.Defaults.Egress.Restricted() {
+		if err := m.ensureEgress(); err != nil {
+			return "", fmt.Errorf("setting up egress: %w", err)
+		}
+	}
+
+	report("Checking out a scratch worktree...")
+	wtPath, err := os.MkdirTemp("", "sc-warm-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(wtPath)
+	if err := worktree.CreateDetached(m.projectDir, wtPath); err != nil {
+		return "", err
+	}
+	defer worktree.RemoveDetached(m.projectDir, wtPath)
+
+	// Inputs are hashed from HEAD, the same tree as the scratch worktree
+	baseID := m.baseImageID()
+	inputs := computeWarmInputs(m.projectDir, baseID, m.cfg)
+	_, reason := m.warmDecision(baseID, inputs)
+	reason = strings.TrimPrefix(reason, "setup ran: ")
+
+	containerName := warmBuildContainerName(m.cfg.Project)
+	exec.Command("docker", "rm", "-f", containerName).Run()
+	defer exec.Command("docker", "rm", "-f", containerName).Run()
+
+	report("Starting build container...")
+	args := []string{
+		"run", "-d",
+		"--name", containerName,
+		"-v", fmt.Sprintf("%s:/workspace", wtPath),
+	}
+	args = append(args, m.runArgs()...)
+	if m.cfg.Defaults.IsHostNetwork() {
+		args = append(args, "--network", "host")
+	}
+	envFile, err := writeSecretEnvFile(secrets)
+	if err != nil {
+		return "", err
+	}
+	if envFile != "" {
+		defer os.Remove(envFile)
+		args = append(args, "--env-file", envFile)
+	}
+	if hasFileSecrets(secrets) {
+		args = append(args, "--tmpfs", fmt.Sprintf("%s:mode=0700,uid=%d,gid=%d", secretsMountPath, os.Getuid(), os.Getgid()))
+	}
+	args = append(args, m.imageName(), "sleep", "infinity")
+
+	if err := m.ensureCacheVolumes(); err != nil {
+		return "", err
+	}
+	if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("docker run failed: %s: %w", redact(strings.TrimSpace(string(out)), secrets), err)
+	}
+	if envFile != "" {
+		os.Remove(envFile)
+	}
+
+	if err := writeFileSecrets(containerName, secrets); err != nil {
+		return "", err
+	}
+	if paths := cacheMountPaths(m.cfg); len(paths) > 0 {
+		exec.Command("docker", append([]string{"exec", "--user", "root", containerName,
+			"chown", "sandcastle:sandcastle"}, paths...)...).Run()
+	}
+
+	report("Running setup commands...")
+	var script strings.Builder
+	script.WriteString(m.gitSetup())
+	for _, cmd := range m.cfg.Defaults.Setup {
+		script.WriteString(fmt.Sprintf("(%s) || true\n", cmd))
+	}
+	setup := exec.Command("docker", "exec", "-i", containerName, "bash", "-s")
+	setup.Stdin = strings.NewReader(script.String())
+	setup.CombinedOutput()
+
+	report("Committing warm image...")
+	commitArgs := append([]string{"commit"}, inputs.commitChanges()...)
+	// Undo the build container's run-time env (proxies, SSH agent, secrets)
+	// so their values aren't baked into the image config
+	envNames := runEnvNames(args)
+	for _, sec := range secrets {
+		if !sec.asFile && !slices.Contains(envNames, sec.name) {
+			envNames = append(envNames, sec.name)
+		}
+	}
+	commitArgs = append(commitArgs, resetEnvChanges(envNames, imageEnv(m.imageName()))...)
+	commitArgs = append(commitArgs, containerName, warmImageName(m.cfg.Project))
+	if out, err := exec.Command("docker", commitArgs...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("docker commit failed: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	saveWarmHash(m.projectDir, inputs)
+	return reason, nil
+}
@@ -0,0 +1,10 @@
+// Package shell builds command lines for the POSIX shells run inside
+// sandbox containers.
+package shell
+
+import "strings"
+
+// Quote quotes s for safe use as a single POSIX shell word.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
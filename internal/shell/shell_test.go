@@ -0,0 +1,17 @@
+package shell
+
+import "testing"
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"plain":     "'plain'",
+		"it's":      `'it'\''s'`,
+		"$HOME `x`": "'$HOME `x`'",
+		"":          "''",
+	}
+	for in, want := range tests {
+		if got := Quote(in); got != want {
+			t.Errorf("Quote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package tasks loads reusable task prompts from .sandcastles/tasks/*.md.
+// A template is Markdown with optional YAML front-matter and {{var}}
+// placeholders:
+//
+//	---
+//	name: fix-{{issue}}
+//	base: main
+//	model: claude-opus-4-1
+//	---
+//	Fix issue #{{issue}}. Add a regression test.
+package tasks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Meta is a template's front-matter. Every field may use placeholders.
+type Meta struct {
+	Name  string `yaml:"name,omitempty"`  // default sandbox name
+	Base  string `yaml:"base,omitempty"`  // git ref the sandbox branches from
+	Agent string `yaml:"agent,omitempty"` // only "claude" is supported
+	Model string `yaml:"model,omitempty"` // passed to the agent
+}
+
+// Template is a task prompt template.
+type Template struct {
+	Name string // file name without .md
+	Meta Meta
+	Body string
+}
+
+// placeholderRe matches {{var}}, allowing spaces inside the braces.
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// varNameRe matches a valid placeholder name.
+var varNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Dir is where a project's templates live.
+func Dir(projectDir string) string {
+	return filepath.Join(projectDir, config.Dir, config.TasksDir)
+}
+
+// List returns the names of a project's templates, sorted.
+func List(projectDir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(Dir(projectDir), "*.md"))
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		names = append(names, strings.TrimSuffix(filepath.Base(p), ".md"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templatePath resolves a template reference: a name in the project's tasks
+// directory, or a file path if ref contains a path separator or ends in .md.
+func templatePath(projectDir, ref string) string {
+	if !strings.ContainsRune(ref, filepath.Separator) && !strings.HasSuffix(ref, ".md") {
+		return filepath.Join(Dir(projectDir), ref+".md")
+	}
+	return ref
+}
+
+// Exists reports whether ref names a template that can be loaded.
+func Exists(projectDir, ref string) bool {
+	info, err := os.Stat(templatePath(projectDir, ref))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Load reads a template by name from the project's tasks directory, or
+// from a file path if ref contains a path separator or ends in .md.
+func Load(projectDir, ref string) (*Template, error) {
+	path := templatePath(projectDir, ref)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("task template %q not found (templates live in %s/%s)", ref, config.Dir, config.TasksDir)
+		}
+		return nil, fmt.Errorf("reading task template: %w", err)
+	}
+	return Parse(strings.TrimSuffix(filepath.Base(path), ".md"), data)
+}
+
+// Parse parses a template's front-matter and body.
+func Parse(name string, data []byte) (*Template, error) {
+	t := &Template{Name: name}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		front, body, found := strings.Cut("\n"+rest, "\n---\n")
+		front = strings.TrimPrefix(front, "\n")
+		if !found {
+			front, found = strings.CutSuffix(rest, "\n---")
+		}
+		if !found {
+			return nil, fmt.Errorf("task template %s: front-matter has no closing ---", name)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader([]byte(front)))
+		dec.KnownFields(true)
+		if err := dec.Decode(&t.Meta); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("task template %s: %w", name, err)
+		}
+		text = body
+	}
+	if t.Meta.Agent != "" && t.Meta.Agent != "claude" {
+		return nil, fmt.Errorf("task template %s: unsupported agent %q (only claude is supported)", name, t.Meta.Agent)
+	}
+
+	t.Body = strings.TrimSpace(text)
+	if t.Body == "" {
+		return nil, fmt.Errorf("task template %s has no prompt", name)
+	}
+	return t, nil
+}
+
+// Vars returns the template's placeholder names in order of first use.
+func (t *Template) Vars() []string {
+	var vars []string
+	for _, s := range []string{t.Meta.Name, t.Meta.Base, t.Meta.Model, t.Body} {
+		for _, m := range placeholderRe.FindAllStringSubmatch(s, -1) {
+			if !slices.Contains(vars, m[1]) {
+				vars = append(vars, m[1])
+			}
+		}
+	}
+	return vars
+}
+
+// Render fills in the placeholders, returning the rendered front-matter and
+// task prompt. Every placeholder needs a value and every value a placeholder,
+// so a typo in either is caught.
+func (t *Template) Render(vars map[string]string) (Meta, string, error) {
+	used := t.Vars()
+	var missing, unknown []string
+	for _, v := range used {
+		if _, ok := vars[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	for k := range vars {
+		if !slices.Contains(used, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	if len(missing) > 0 {
+		return Meta{}, "", fmt.Errorf("template %s needs %s", t.Name, strings.Join(withEquals(missing), " "))
+	}
+	if len(unknown) > 0 {
+		return Meta{}, "", fmt.Errorf("template %s has no %s (it uses: %s)", t.Name, strings.Join(unknown, ", "), strings.Join(used, ", "))
+	}
+
+	fill := func(s string) string {
+		return placeholderRe.ReplaceAllStringFunc(s, func(match string) string {
+			return vars[placeholderRe.FindStringSubmatch(match)[1]]
+		})
+	}
+	meta := Meta{
+		Name:  fill(t.Meta.Name),
+		Base:  fill(t.Meta.Base),
+		Agent: t.Meta.Agent,
+		Model: fill(t.Meta.Model),
+	}
+	return meta, fill(t.Body), nil
+}
+
+func withEquals(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = n + "=..."
+	}
+	return out
+}
+
+// ParseVars parses key=value arguments.
+func ParseVars(args []string) (map[string]string, error) {
+	vars := make(map[string]string, len(args))
+	for _, arg := range args {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok || !varNameRe.MatchString(k) {
+			return nil, fmt.Errorf("expected key=value, got %q", arg)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// SplitArgs splits a command line into words. Single or double quotes keep
+// spaces in a word, e.g. title="Fix the login page".
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// Complete returns the template names starting with prefix.
+func Complete(projectDir, prefix string) []string {
+	var matches []string
+	for _, name := range List(projectDir) {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,127 @@
+package tasks
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const bugfix = `---
+name: fix-{{issue}}
+base: main
+model: claude-opus-4-1
+---
+
+Fix issue #{{ issue }} in {{area}}.
+Keep the fix for #{{issue}} small.
+`
+
+func TestParseAndRender(t *testing.T) {
+	tmpl, err := Parse("bugfix", []byte(bugfix))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if tmpl.Meta.Base != "main" || tmpl.Meta.Model != "claude-opus-4-1" {
+		t.Errorf("Meta = %+v", tmpl.Meta)
+	}
+	if got := tmpl.Vars(); !slices.Equal(got, []string{"issue", "area"}) {
+		t.Errorf("Vars() = %v", got)
+	}
+
+	meta, task, err := tmpl.Render(map[string]string{"issue": "42", "area": "the login page"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if meta.Name != "fix-42" {
+		t.Errorf("Name = %q, want fix-42", meta.Name)
+	}
+	if want := "Fix issue #42 in the login page.\nKeep the fix for #42 small."; task != want {
+		t.Errorf("task = %q, want %q", task, want)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tmpl, _ := Parse("bugfix", []byte(bugfix))
+
+	_, _, err := tmpl.Render(map[string]string{"issue": "42"})
+	if err == nil || !strings.Contains(err.Error(), "area=...") {
+		t.Errorf("missing var: err = %v", err)
+	}
+	_, _, err = tmpl.Render(map[string]string{"issue": "42", "area": "x", "isue": "1"})
+	if err == nil || !strings.Contains(err.Error(), "isue") {
+		t.Errorf("unknown var: err = %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"unclosed":      "---\nname: x\nFix it",
+		"unknown field": "---\nmodle: opus\n---\nFix it",
+		"agent":         "---\nagent: codex\n---\nFix it",
+		"empty body":    "---\nname: x\n---\n\n",
+	} {
+		if _, err := Parse(name, []byte(data)); err == nil {
+			t.Errorf("%s: Parse succeeded", name)
+		}
+	}
+
+	tmpl, err := Parse("plain", []byte("Just a prompt, no front-matter.\n"))
+	if err != nil || tmpl.Body != "Just a prompt, no front-matter." || tmpl.Meta != (Meta{}) {
+		t.Errorf("plain: %+v, %v", tmpl, err)
+	}
+}
+
+func TestLoadAndComplete(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(Dir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bugfix", "bump-deps", "refactor"} {
+		os.WriteFile(filepath.Join(Dir(dir), name+".md"), []byte(bugfix), 0o644)
+	}
+	os.WriteFile(filepath.Join(Dir(dir), "notes.txt"), []byte("ignored"), 0o644)
+
+	if got := List(dir); !slices.Equal(got, []string{"bugfix", "bump-deps", "refactor"}) {
+		t.Errorf("List() = %v", got)
+	}
+	if got := Complete(dir, "b"); !slices.Equal(got, []string{"bugfix", "bump-deps"}) {
+		t.Errorf("Complete(b) = %v", got)
+	}
+
+	if tmpl, err := Load(dir, "refactor"); err != nil || tmpl.Name != "refactor" {
+		t.Errorf("Load(refactor) = %+v, %v", tmpl, err)
+	}
+	path := filepath.Join(t.TempDir(), "adhoc.md")
+	os.WriteFile(path, []byte("Do the thing."), 0o644)
+	if tmpl, err := Load(dir, path); err != nil || tmpl.Body != "Do the thing." {
+		t.Errorf("Load(path) = %+v, %v", tmpl, err)
+	}
+	if _, err := Load(dir, "missing"); err == nil {
+		t.Error("Load(missing) succeeded")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args, err := SplitArgs(`issue=42 area="the login page" note='it''s fine'`)
+	if err != nil {
+		t.Fatalf("SplitArgs: %v", err)
+	}
+	if want := []string{"issue=42", "area=the login page", "note=its fine"}; !slices.Equal(args, want) {
+		t.Errorf("SplitArgs() = %q, want %q", args, want)
+	}
+	if _, err := SplitArgs(`area="open`); err == nil {
+		t.Error("SplitArgs accepted an unterminated quote")
+	}
+
+	vars, err := ParseVars(args)
+	if err != nil || vars["area"] != "the login page" {
+		t.Errorf("ParseVars() = %v, %v", vars, err)
+	}
+	for _, bad := range []string{"novalue", "=x", "two words=x"} {
+		if _, err := ParseVars([]string{bad}); err == nil {
+			t.Errorf("ParseVars(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Package transcript reads Claude Code session transcripts (JSONL) and
+// renders them as readable text: prompts, replies, tool calls and results.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxResultLines is how many lines of a tool result are shown unless
+// Options.Full is set.
+const maxResultLines = 8
+
+// maxInputWidth caps the one-line summary of a tool call's input.
+const maxInputWidth = 160
+
+// Options controls rendering.
+type Options struct {
+	Full bool // show tool results in full instead of truncating them
+}
+
+// entry is one line of a session transcript. Only the fields sandcastles
+// renders are decoded.
+type entry struct {
+	Type        string    `json:"type"`
+	Timestamp   time.Time `json:"timestamp"`
+	IsSidechain bool      `json:"isSidechain"`
+	IsMeta      bool      `json:"isMeta"`
+	Summary     string    `json:"summary"`
+	Message     struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// block is one element of a message's content array.
+type block struct {
+	Type    string          `json:"type"`
+	Text    string          `json:"text"`
+	Name    string          `json:"name"`    // tool_use
+	Input   json.RawMessage `json:"input"`   // tool_use
+	Content json.RawMessage `json:"content"` // tool_result: string or blocks
+	IsError bool            `json:"is_error"`
+}
+
+// Summary describes a session for the transcript index.
+type Summary struct {
+	FirstPrompt string    `json:"first_prompt"`
+	Started     time.Time `json:"started"`
+	Updated     time.Time `json:"updated"`
+	Messages    int       `json:"messages"` // user prompts and assistant replies
+}
+
+// Summarize reads a session and returns its first prompt, time span and
+// message count.
+func Summarize(r io.Reader) (Summary, error) {
+	var s Summary
+	err := each(r, func(e entry) {
+		if e.IsMeta || (e.Type != "user" && e.Type != "assistant") {
+			return
+		}
+		if s.Started.IsZero() && !e.Timestamp.IsZero() {
+			s.Started = e.Timestamp
+		}
+		if e.Timestamp.After(s.Updated) {
+			s.Updated = e.Timestamp
+		}
+		text, _ := content(e.Message.Content)
+		if e.Type == "user" && text == "" {
+			return // tool results
+		}
+		s.Messages++
+		if s.FirstPrompt == "" && e.Type == "user" && !e.IsSidechain {
+			s.FirstPrompt = firstLine(text)
+		}
+	})
+	return s, err
+}
+
+// Render writes a readable rendering of a session transcript to w.
+func Render(w io.Writer, r io.Reader, opts Options) error {
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	err := each(r, func(e entry) {
+		if e.IsMeta {
+			return
+		}
+		switch e.Type {
+		case "summary":
+			if e.Summary != "" {
+				fmt.Fprintf(bw, "# %s\n", e.Summary)
+			}
+		case "user", "assistant":
+			renderMessage(bw, e, opts)
+		}
+	})
+	return err
+}
+
+// renderMessage writes one user or assistant message.
+func renderMessage(w io.Writer, e entry, opts Options) {
+	text, blocks := content(e.Message.Content)
+
+	var body strings.Builder
+	if text != "" {
+		body.WriteString(indent(text, "  "))
+	}
+	for _, b := range blocks {
+		switch b.Type {
+		case "tool_use":
+			fmt.Fprintf(&body, "  ⚙ %s: %s\n", b.Name, toolInput(b.Input))
+		case "tool_result":
+			result, _ := content(b.Content)
+			marker := "↳"
+			if b.IsError {
+				marker = "✗"
+			}
+			lines := strings.Split(strings.TrimRight(result, "\n"), "\n")
+			more := 0
+			if !opts.Full && len(lines) > maxResultLines {
+				more = len(lines) - maxResultLines
+				lines = lines[:maxResultLines]
+			}
+			for i, line := range lines {
+				prefix := "      "
+				if i == 0 {
+					prefix = "    " + marker + " "
+				}
+				body.WriteString(prefix + line + "\n")
+			}
+			if more > 0 {
+				fmt.Fprintf(&body, "      … %d more lines\n", more)
+			}
+		}
+	}
+	if body.Len() == 0 {
+		return
+	}
+
+	// Tool results arrive as user messages with no text; they continue the
+	// assistant turn above them rather than starting a new heading.
+	if text != "" {
+		role := e.Type
+		if e.IsSidechain {
+			role += " (subagent)"
+		}
+		if !e.Timestamp.IsZero() {
+			role = "[" + e.Timestamp.Local().Format("2006-01-02 15:04:05") + "] " + role
+		}
+		fmt.Fprintf(w, "\n%s\n", role)
+	}
+	io.WriteString(w, body.String())
+}
+
+// content splits message content into its text and its non-text blocks.
+// Content is either a plain string or an array of blocks.
+func content(raw json.RawMessage) (string, []block) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s, nil
+	}
+	var blocks []block
+	if json.Unmarshal(raw, &blocks) != nil {
+		return "", nil
+	}
+	var texts []string
+	var rest []block
+	for _, b := range blocks {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		} else if b.Type != "thinking" && b.Type != "redacted_thinking" {
+			rest = append(rest, b)
+		}
+	}
+	return strings.Join(texts, "\n"), rest
+}
+
+// toolInputKeys are the input fields that best describe a tool call, in
+// order of preference.
+var toolInputKeys = []string{"command", "file_path", "path", "pattern", "url", "query", "description", "prompt"}
+
+// toolInput summarizes a tool call's input on one line.
+func toolInput(raw json.RawMessage) string {
+	var fields map[string]json.RawMessage
+	summary := strings.TrimSpace(string(raw))
+	if json.Unmarshal(raw, &fields) == nil {
+		for _, key := range toolInputKeys {
+			var s string
+			if json.Unmarshal(fields[key], &s) == nil && s != "" {
+				summary = s
+				break
+			}
+		}
+	}
+	summary = strings.Join(strings.Fields(summary), " ")
+	if len(summary) > maxInputWidth {
+		summary = summary[:maxInputWidth] + "…"
+	}
+	return summary
+}
+
+// each decodes every line of a JSONL transcript, skipping malformed lines.
+func each(r io.Reader, fn func(entry)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var e entry
+			if json.Unmarshal(line, &e) == nil {
+				fn(e)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func indent(text, prefix string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		b.WriteString(prefix + line + "\n")
+	}
+	return b.String()
+}
+
+func firstLine(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return text
+}
@@ -0,0 +1,85 @@
+package transcript
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const session = `{"type":"summary","summary":"Fix the login bug"}
+{"type":"user","isMeta":true,"timestamp":"2025-06-01T12:00:00Z","message":{"role":"user","content":"Caveat: injected"}}
+{"type":"user","timestamp":"2025-06-01T12:00:01Z","message":{"role":"user","content":"fix the login bug\nit fails on empty passwords"}}
+{"type":"assistant","timestamp":"2025-06-01T12:00:05Z","message":{"role":"assistant","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Let me look."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./...","description":"Run tests"}}]}}
+{"type":"user","timestamp":"2025-06-01T12:00:09Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"%s","is_error":true}]}}
+not json
+{"type":"assistant","timestamp":"2025-06-01T12:00:12Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"Edit","input":{"file_path":"auth/login.go","old_string":"a","new_string":"b"}}]}}
+{"type":"user","timestamp":"2025-06-01T12:00:13Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"ok"}]}]}}
+{"type":"assistant","timestamp":"2025-06-01T12:00:20Z","message":{"role":"assistant","content":[{"type":"text","text":"Fixed."}]}}
+`
+
+func sessionWithResult(lines int) string {
+	var result []string
+	for i := 1; i <= lines; i++ {
+		result = append(result, fmt.Sprintf("line %d", i))
+	}
+	return fmt.Sprintf(session, strings.Join(result, `\n`))
+}
+
+func TestRender(t *testing.T) {
+	var out strings.Builder
+	if err := Render(&out, strings.NewReader(sessionWithResult(12)), Options{}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"# Fix the login bug",
+		"user\n  fix the login bug\n  it fails on empty passwords\n",
+		"assistant\n  Let me look.\n  ⚙ Bash: go test ./...\n",
+		"    ✗ line 1\n      line 2\n",
+		"      … 4 more lines\n",
+		"  ⚙ Edit: auth/login.go\n    ↳ ok\n",
+		"  Fixed.\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"Caveat", "hmm", "line 9"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("output contains %q:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestRenderFull(t *testing.T) {
+	var out strings.Builder
+	if err := Render(&out, strings.NewReader(sessionWithResult(12)), Options{Full: true}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(out.String(), "line 12") || strings.Contains(out.String(), "more lines") {
+		t.Errorf("full output truncated:\n%s", out.String())
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	s, err := Summarize(strings.NewReader(sessionWithResult(1)))
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if s.FirstPrompt != "fix the login bug" {
+		t.Errorf("FirstPrompt = %q", s.FirstPrompt)
+	}
+	if s.Messages != 4 {
+		t.Errorf("Messages = %d, want 4", s.Messages)
+	}
+	if s.Started.Format("15:04:05") != "12:00:01" || s.Updated.Format("15:04:05") != "12:00:20" {
+		t.Errorf("span = %v – %v", s.Started, s.Updated)
+	}
+}
+
+func TestToolInputFallsBackToJSON(t *testing.T) {
+	got := toolInput([]byte(`{"todos": [{"content": "a"}]}`))
+	if got != `{"todos": [{"content": "a"}]}` {
+		t.Errorf("toolInput = %q", got)
+	}
+}
@@ -2,16 +2,46 @@ package tui
 
 import (
 	"fmt"
+	"os"
+	"sync"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/zpdzap/sandcastles/internal/config"
 	"github.com/zpdzap/sandcastles/internal/sandbox"
 )
 
+// terminalOutput is the program's output. Writes are serialized so escape
+// sequences written outside the renderer (notification bells) can't land in
+// the middle of a frame.
+type terminalOutput struct {
+	*os.File // Fd lets Bubble Tea detect the terminal and its size
+	mu       sync.Mutex
+}
+
+func (t *terminalOutput) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.File.Write(p)
+}
+
+func (t *terminalOutput) WriteString(s string) (int, error) {
+	return t.Write([]byte(s))
+}
+
 // Run starts the main TUI loop. It cycles between the Bubble Tea dashboard
 // and subprocess connections (tmux attach) until the user quits.
 func Run(mgr *sandbox.Manager, cfg *config.Config) error {
-	p := tea.NewProgram(newModel(mgr, cfg), tea.WithAltScreen())
+	out := &terminalOutput{File: os.Stdout}
+	m := newModel(mgr, cfg)
+	m.output = out
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithOutput(out))
+
+	done := make(chan struct{})
+	defer close(done)
+	go mgr.WatchCredentials(done, func(s sandbox.CredentialSync) {
+		p.Send(credentialsSyncedMsg(s))
+	})
+
 	if _, err := p.Run(); err != nil {
 		return fmt.Errorf("TUI error: %w", err)
 	}
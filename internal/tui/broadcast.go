@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zpdzap/sandcastles/internal/agent"
+	"github.com/zpdzap/sandcastles/internal/sandbox"
+)
+
+// broadcastFilter selects which running sandcastles a /broadcast reaches.
+// Empty fields match everything.
+type broadcastFilter struct {
+	state string // agent state: working, waiting or done
+	tag   string
+	name  string // glob, e.g. "api-*"
+}
+
+// broadcastSentMsg reports which sandcastles received a broadcast.
+type broadcastSentMsg struct {
+	sent   []string
+	failed []string
+}
+
+// parseBroadcast splits /broadcast arguments into the filter flags
+// (--state, --tag, --name, as "--flag value" or "--flag=value") and the
+// message that follows them. A "--" ends the flags.
+func parseBroadcast(input string) (broadcastFilter, string, error) {
+	var f broadcastFilter
+	fields := strings.Fields(input)
+	consumed := 1 // the command itself
+	for consumed < len(fields) {
+		arg := fields[consumed]
+		if arg == "--" {
+			consumed++
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			break
+		}
+		flag, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		consumed++
+		if !hasValue {
+			if consumed >= len(fields) {
+				return f, "", fmt.Errorf("--%s needs a value", flag)
+			}
+			value = fields[consumed]
+			consumed++
+		}
+		switch flag {
+		case "state":
+			switch value {
+			case "working", "waiting", "done":
+			default:
+				return f, "", fmt.Errorf("unknown state %q (want working, waiting or done)", value)
+			}
+			f.state = value
+		case "tag":
+			f.tag = value
+		case "name":
+			if _, err := path.Match(value, ""); err != nil {
+				return f, "", fmt.Errorf("bad name pattern %q", value)
+			}
+			f.name = value
+		default:
+			return f, "", fmt.Errorf("unknown flag --%s", flag)
+		}
+	}
+	return f, argsAfter(input, consumed), nil
+}
+
+// matches reports whether a sandcastle in the given agent state passes the filter.
+func (f broadcastFilter) matches(sb *sandbox.Sandbox, state string) bool {
+	if f.state != "" && state != f.state {
+		return false
+	}
+	if f.tag != "" && !sb.HasTag(f.tag) {
+		return false
+	}
+	if f.name != "" {
+		if ok, _ := path.Match(f.name, sb.Name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// broadcastCmd sends a prompt to each named sandcastle in parallel.
+func broadcastCmd(names []string, text string) tea.Cmd {
+	return func() tea.Msg {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var msg broadcastSentMsg
+		for _, name := range names {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := agent.Send(fmt.Sprintf("sc-%s", name), text)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					msg.failed = append(msg.failed, name)
+				} else {
+					msg.sent = append(msg.sent, name)
+				}
+			}()
+		}
+		wg.Wait()
+		return msg
+	}
+}
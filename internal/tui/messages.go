@@ -1,14 +1,20 @@
 package tui
 
 import (
+	"fmt"
+	"io"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zpdzap/sandcastles/internal/agent"
+	"github.com/zpdzap/sandcastles/internal/notify"
+	"github.com/zpdzap/sandcastles/internal/sandbox"
 )
 
 // sandboxCreatedMsg is sent when a sandbox finishes creating.
 type sandboxCreatedMsg struct {
 	name string
+	warm string // warm image reuse/rebuild reason
 	err  error
 }
 
@@ -22,6 +28,32 @@ type allDestroyedMsg struct {
 	count int
 }
 
+// portExposedMsg is sent when an /expose port forward finishes starting.
+type portExposedMsg struct {
+	name     string
+	port     int
+	hostPort string
+	err      error
+}
+
+// promptSentMsg is sent when a follow-up prompt has been delivered to an agent.
+type promptSentMsg struct {
+	name string
+	err  error
+}
+
+// promptAnsweredMsg is sent when an approval answer was forwarded to a pane.
+type promptAnsweredMsg struct {
+	name  string
+	label string // the chosen option
+	err   error
+}
+
+// notifyFailedMsg is sent when a notification channel fails.
+type notifyFailedMsg struct {
+	err error
+}
+
 // statusTickMsg triggers a status refresh poll.
 type statusTickMsg time.Time
 
@@ -42,13 +74,168 @@ type attachFinishedMsg struct {
 type statusPollResultMsg struct {
 	previews    map[string]string
 	agentStates map[string]string
+	approvals   map[string]*agent.Prompt // selection prompts waiting agents are blocked on
 	diffStats   map[string]diffStat
 	attachedAt  map[string]time.Time
+
+	authRefreshedAt map[string]time.Time // last automatic reauth per sandbox
+	authRefreshed   []string             // sandboxes reauthed after an auth failure in their pane
 }
 
+// credentialsSyncedMsg is sent when the credentials watcher pushed rotated
+// host credentials or hit a new error.
+type credentialsSyncedMsg sandbox.CredentialSync
+
 // tickCmd returns a command that sends a tick every 2 seconds.
 func tickCmd() tea.Cmd {
 	return tea.Tick(2*time.Second, func(t time.Time) tea.Msg {
 		return statusTickMsg(t)
 	})
 }
+
+// warmCheckInterval is how often the dashboard checks whether the warm image
+// needs rebuilding in the background.
+const warmCheckInterval = 30 * time.Second
+
+// warmTickMsg triggers a background warm-image check.
+type warmTickMsg time.Time
+
+// warmCheckResultMsg reports whether the warm image is stale.
+type warmCheckResultMsg struct {
+	needed bool
+}
+
+// warmBuiltMsg is sent when a background warm-image build finishes.
+type warmBuiltMsg struct {
+	reason string
+	err    error
+}
+
+// warmTickCmd schedules the next warm-image check after d.
+func warmTickCmd(d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(t time.Time) tea.Msg {
+		return warmTickMsg(t)
+	})
+}
+
+// transcriptSyncInterval is how often running sandboxes' agent transcripts
+// are mirrored to the host.
+const transcriptSyncInterval = time.Minute
+
+// transcriptTickMsg triggers a background transcript sync.
+type transcriptTickMsg time.Time
+
+// transcriptsSyncedMsg is sent when a background transcript sync finishes,
+// with the estimated agent costs read from the synced transcripts.
+type transcriptsSyncedMsg struct {
+	costs       map[string]float64 // per sandbox, since it was created
+	projectCost float64            // every saved session in the project
+	err         error
+}
+
+// syncTranscriptsCmd mirrors running sandboxes' transcripts to the host and
+// totals their costs in the background.
+func syncTranscriptsCmd(mgr *sandbox.Manager) tea.Cmd {
+	return func() tea.Msg {
+		err := mgr.SyncAllTranscripts()
+		msg := transcriptsSyncedMsg{costs: make(map[string]float64), err: err}
+		for _, u := range mgr.Usage(sandbox.UsageFilter{}) {
+			msg.projectCost += u.Cost
+		}
+		for _, sb := range mgr.List() {
+			for _, u := range mgr.Usage(sandbox.UsageFilter{Sandbox: sb.Name, Since: sb.CreatedAt}) {
+				msg.costs[sb.Name] += u.Cost
+			}
+		}
+		return msg
+	}
+}
+
+// transcriptTickCmd schedules the next transcript sync after d.
+func transcriptTickCmd(d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(t time.Time) tea.Msg {
+		return transcriptTickMsg(t)
+	})
+}
+
+// verifiedMsg is sent when a sandbox's verify commands finish.
+type verifiedMsg struct {
+	name     string
+	result   *sandbox.Verification
+	skipped  bool   // automatic run with nothing changed since the last one
+	feedback string // failures to send back to the agent, see Manager.Feedback
+	err      error
+}
+
+// verifyCmd runs a sandbox's verify commands in the background. Automatic
+// runs (force false) are skipped if the worktree hasn't changed since the
+// last run, and feed failures back to the agent under defaults.max_iterations.
+func verifyCmd(mgr *sandbox.Manager, name string, force bool) tea.Cmd {
+	return func() tea.Msg {
+		if !force && !mgr.VerifyNeeded(name) {
+			// An agent that stops without changing anything after being sent
+			// failures still uses up an iteration
+			if sb, ok := mgr.Get(name); ok && sb.Iterations > 0 && !sb.NeedsAttention &&
+				sb.Verify != nil && sb.Verify.Status == sandbox.VerifyFailed {
+				return verifiedMsg{name: name, result: sb.Verify, feedback: mgr.Feedback(name, sb.Verify)}
+			}
+			return verifiedMsg{name: name, skipped: true}
+		}
+		result, err := mgr.Verify(name)
+		msg := verifiedMsg{name: name, result: result, err: err}
+		if !force && err == nil {
+			msg.feedback = mgr.Feedback(name, result)
+		}
+		return msg
+	}
+}
+
+// poolFilledMsg is sent when a background container pool top-up finishes.
+type poolFilledMsg struct {
+	started int
+	err     error
+}
+
+// fillPoolCmd tops up the container pool in the background.
+func fillPoolCmd(mgr *sandbox.Manager) tea.Cmd {
+	return func() tea.Msg {
+		started, err := mgr.FillPool()
+		return poolFilledMsg{started: started, err: err}
+	}
+}
+
+// sendPromptCmd types a prompt into a sandbox's agent pane in the background.
+func sendPromptCmd(name, text string) tea.Cmd {
+	return func() tea.Msg {
+		err := agent.Send(fmt.Sprintf("sc-%s", name), text)
+		return promptSentMsg{name: name, err: err}
+	}
+}
+
+// answerPromptCmd presses an option's key in a sandbox's agent pane, if the
+// pane still shows the prompt it was picked from.
+func answerPromptCmd(name string, p *agent.Prompt, opt agent.Option) tea.Cmd {
+	return func() tea.Msg {
+		err := agent.Answer(fmt.Sprintf("sc-%s", name), p, opt.Key)
+		return promptAnsweredMsg{name: name, label: opt.Label, err: err}
+	}
+}
+
+// terminalNotifyCmd writes a notification escape sequence (bell, OSC 9) to
+// the program's output, which keeps it from interleaving with a frame.
+func terminalNotifyCmd(w io.Writer, seq string) tea.Cmd {
+	return func() tea.Msg {
+		io.WriteString(w, seq)
+		return nil
+	}
+}
+
+// dispatchNotifyCmd runs an event's desktop, command and webhook channels in the background.
+func dispatchNotifyCmd(n *notify.Notifier, ev notify.Event) tea.Cmd {
+	return func() tea.Msg {
+		if err := n.Dispatch(ev); err != nil {
+			return notifyFailedMsg{err: err}
+		}
+		return nil
+	}
+}
@@ -1,13 +1,18 @@
 package tui
 
 import (
+	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"time"
 
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/zpdzap/sandcastles/internal/agent"
 	"github.com/zpdzap/sandcastles/internal/config"
+	"github.com/zpdzap/sandcastles/internal/notify"
 	"github.com/zpdzap/sandcastles/internal/sandbox"
 	"golang.org/x/term"
 )
@@ -35,14 +40,45 @@ type model struct {
 	agentStates map[string]string // "working" / "waiting" / "done" per sandbox
 	attachedAt  map[string]time.Time // last time a client was detected attached
 
+	// Notifications on agent state transitions (nil if the rules are invalid)
+	notifier *notify.Notifier
+	output   io.Writer // the program's output; bell sequences are written through it
+
+	// Automatic credential refresh after auth failures
+	authRefreshedAt map[string]time.Time
+
 	// Diff stats shown in column headers
 	diffStats map[string]diffStat // per-sandbox diff summary
 
+	// Estimated agent costs from synced transcripts
+	costs       map[string]float64 // per sandbox, shown in its column header
+	projectCost float64            // shown in the title bar
+
 	// Modals
 	showHelp    bool
 	showDiff    bool
 	diffContent string // rendered diff tree
 
+	// Verify results modal
+	showVerify    bool
+	verifyContent string
+
+	// Race comparison: the attempts of one /race side by side
+	showRace   bool
+	raceName   string
+	raceCursor int
+
+	// Approval queue: selection prompts parsed from waiting agents' panes
+	approvals      map[string]*agent.Prompt
+	answeredAt     map[string]time.Time // last answer per sandbox, until the pane catches up
+	showApprovals  bool
+	approvalCursor int
+
+	// Prompt editor for sending a follow-up to an agent without attaching
+	prompting  bool
+	promptName string
+	prompt     textarea.Model
+
 	// Double-press stop confirmation
 	confirmStop     bool
 	confirmStopName string
@@ -56,6 +92,13 @@ func newModel(mgr *sandbox.Manager, cfg *config.Config) model {
 	// Input starts unfocused — activated by pressing /
 	ti.Blur()
 
+	ta := textarea.New()
+	ta.Placeholder = "Follow-up prompt for the agent..."
+	ta.ShowLineNumbers = false
+	ta.CharLimit = 0
+	ta.SetWidth(72)
+	ta.SetHeight(8)
+
 	// Get initial terminal size so the first render isn't at width=0
 	w, h, _ := term.GetSize(int(os.Stdout.Fd()))
 	if w == 0 {
@@ -69,18 +112,31 @@ func newModel(mgr *sandbox.Manager, cfg *config.Config) model {
 		manager:     mgr,
 		cfg:         cfg,
 		input:       ti,
+		prompt:      ta,
 		width:       w,
 		height:      h,
 		quip:        quips[rand.Intn(len(quips))],
 		previews:    make(map[string]string),
 		agentStates: make(map[string]string),
 		diffStats:   make(map[string]diffStat),
+		costs:       make(map[string]float64),
 		attachedAt:  make(map[string]time.Time),
+		approvals:   make(map[string]*agent.Prompt),
+		answeredAt:  make(map[string]time.Time),
+
+		authRefreshedAt: make(map[string]time.Time),
+	}
+
+	notifier, err := notify.New(cfg.Notify)
+	if err != nil {
+		m.message = fmt.Sprintf("Notifications disabled: %v", err)
+		m.isError = true
 	}
+	m.notifier = notifier
 
 	return m
 }
 
 func (m model) Init() tea.Cmd {
-	return tickCmd()
+	return tea.Batch(tickCmd(), warmTickCmd(warmCheckInterval), syncTranscriptsCmd(m.manager), fillPoolCmd(m.manager))
 }
@@ -0,0 +1,234 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/zpdzap/sandcastles/internal/agent"
+	"github.com/zpdzap/sandcastles/internal/sandbox"
+	"github.com/zpdzap/sandcastles/internal/usage"
+)
+
+// raceRequest is a parsed /race command.
+type raceRequest struct {
+	name string
+	n    int
+	task string
+	opts sandbox.RaceOptions
+}
+
+// raceStartedMsg is sent when a race's sandcastles have been created.
+type raceStartedMsg struct {
+	race    string
+	started []string
+	err     error
+}
+
+// raceFinishedMsg is sent when a race's winner was merged and the other
+// attempts have been stopped.
+type raceFinishedMsg struct {
+	race    string
+	result  string // the merge result
+	stopped []string
+}
+
+// parseRace parses "/race <name> <n> [--base ref] [--models a,b] <task>".
+// Flags go between the count and the task, as "--flag value" or
+// "--flag=value"; a "--" ends them.
+func parseRace(input string) (raceRequest, error) {
+	var req raceRequest
+	fields := strings.Fields(input)
+	if len(fields) < 4 {
+		return req, fmt.Errorf("usage: /race <name> <n> [--base ref] [--models a,b] <task> (every attempt gets the same task; only the model varies)")
+	}
+	req.name = fields[1]
+	n, err := strconv.Atoi(fields[2])
+	if err != nil || n < 2 || n > sandbox.MaxRace {
+		return req, fmt.Errorf("the number of attempts must be 2 to %d", sandbox.MaxRace)
+	}
+	req.n = n
+
+	consumed := 3
+	for consumed < len(fields) {
+		arg := fields[consumed]
+		if arg == "--" {
+			consumed++
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			break
+		}
+		flag, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		consumed++
+		if !hasValue {
+			if consumed >= len(fields) {
+				return req, fmt.Errorf("--%s needs a value", flag)
+			}
+			value = fields[consumed]
+			consumed++
+		}
+		switch flag {
+		case "base":
+			req.opts.Base = value
+		case "models", "model":
+			for _, model := range strings.Split(value, ",") {
+				if model = strings.TrimSpace(model); model != "" {
+					req.opts.Models = append(req.opts.Models, model)
+				}
+			}
+		default:
+			return req, fmt.Errorf("unknown flag --%s", flag)
+		}
+	}
+	req.task = argsAfter(input, consumed)
+	if req.task == "" {
+		return req, fmt.Errorf("a race needs a task")
+	}
+	return req, nil
+}
+
+// startRaceCmd creates a race's sandcastles and then starts every agent,
+// so no attempt gets a head start.
+func startRaceCmd(mgr *sandbox.Manager, req raceRequest, phase *string) tea.Cmd {
+	return func() tea.Msg {
+		created, err := mgr.CreateRace(req.name, req.task, req.n, req.opts, func(p string) {
+			*phase = p
+		})
+		msg := raceStartedMsg{race: req.name, err: err}
+		for _, sb := range created {
+			go agent.Start(fmt.Sprintf("sc-%s", sb.Name), req.task, agent.Options{Model: sb.Model})
+			msg.started = append(msg.started, sb.Name)
+		}
+		return msg
+	}
+}
+
+// finishRaceCmd stops the attempts that lost a race.
+func finishRaceCmd(mgr *sandbox.Manager, race, result string, rest []string) tea.Cmd {
+	for _, name := range rest {
+		mgr.MarkStopping(name)
+	}
+	return func() tea.Msg {
+		for _, name := range rest {
+			mgr.Destroy(name)
+		}
+		return raceFinishedMsg{race: race, result: result, stopped: rest}
+	}
+}
+
+// handleRaceMode handles keys while a race comparison is open: arrows move
+// between attempts, m merges the focused one and stops the rest, and enter
+// attaches to it.
+func (m model) handleRaceMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	members := m.manager.Race(m.raceName)
+	m.raceCursor = min(m.raceCursor, max(0, len(members)-1))
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "c", "esc":
+		m.showRace = false
+		return m, nil
+
+	case "up", "k", "left", "h":
+		if m.raceCursor > 0 {
+			m.raceCursor--
+		}
+		return m, nil
+
+	case "down", "j", "right", "l":
+		if m.raceCursor < len(members)-1 {
+			m.raceCursor++
+		}
+		return m, nil
+
+	case "enter":
+		if len(members) == 0 {
+			return m, nil
+		}
+		name := members[m.raceCursor].Name
+		m.showRace = false
+		m.attaching = true
+		m.attachedAt[name] = time.Now()
+		return m, tea.ExecProcess(m.manager.ConnectCmd(name), func(err error) tea.Msg {
+			return attachFinishedMsg{name: name}
+		})
+
+	case "m":
+		if len(members) == 0 {
+			return m, nil
+		}
+		winner := members[m.raceCursor].Name
+		result, rest, err := m.manager.FinishRace(winner)
+		if err != nil {
+			return m, m.setMessage(fmt.Sprintf("Merge failed: %v", err), true)
+		}
+		m.showRace = false
+		m.message = fmt.Sprintf("%s — stopping %d other attempt%s...", result, len(rest), plural(len(rest)))
+		m.isError = false
+		return m, finishRaceCmd(m.manager, m.raceName, result, rest)
+	}
+	return m, nil
+}
+
+// renderRaceOverlay compares the attempts in a race side by side: changes,
+// checks and cost, with the focused attempt marked.
+func (m model) renderRaceOverlay(base string) string {
+	members := m.manager.Race(m.raceName)
+	lines := []string{helpHeaderStyle.Render(fmt.Sprintf("Race %s (%d)", m.raceName, len(members))), ""}
+	if len(members) == 0 {
+		lines = append(lines, helpDescStyle.Render("No attempts left in this race."))
+	} else {
+		task := strings.Join(strings.Fields(members[0].Task), " ")
+		lines = append(lines, serviceDimStyle.Render(ansi.Truncate(task, max(40, min(100, m.width-10)), "…")), "")
+	}
+
+	row := "%s%-16s %-18s %-8s %7s %5s %13s  %-26s %8s"
+	lines = append(lines, serviceDimStyle.Render(fmt.Sprintf(row, "  ", "attempt", "model", "agent", "commits", "files", "lines", "checks", "cost")))
+	cursor := min(m.raceCursor, max(0, len(members)-1))
+	for i, sb := range members {
+		modelName := sb.Model
+		if modelName == "" {
+			modelName = "default"
+		}
+		state := m.agentStates[sb.Name]
+		if state == "" || sb.Status != sandbox.StatusRunning {
+			state = string(sb.Status)
+		}
+		stats := m.diffStats[sb.Name]
+		checks := verifyBadge(sb)
+		if checks == "" {
+			checks = "-"
+		}
+		cost := "-"
+		if c := m.costs[sb.Name]; c > 0 {
+			cost = usage.FormatCost(c)
+		}
+
+		marker := "  "
+		if i == cursor {
+			marker = "▸ "
+		}
+		text := fmt.Sprintf(row, marker, sb.Name, modelName, state, strconv.Itoa(stats.commits), strconv.Itoa(stats.files),
+			fmt.Sprintf("+%d -%d", stats.added, stats.deleted), checks, cost)
+		if i == cursor {
+			lines = append(lines, helpKeyStyle.Render(text))
+		} else {
+			lines = append(lines, helpDescStyle.Render(text))
+		}
+	}
+
+	lines = append(lines, "",
+		helpKeyStyle.Render("m")+helpDescStyle.Render(" merge winner and stop the rest  ")+
+			helpKeyStyle.Render("↑/↓")+helpDescStyle.Render(" select  ")+
+			helpKeyStyle.Render("enter")+helpDescStyle.Render(" connect  ")+
+			helpKeyStyle.Render("esc")+helpDescStyle.Render(" close"))
+	return m.renderModalOverlay(base, strings.Join(lines, "\n"), lipgloss.Color("#5599FF"))
+}
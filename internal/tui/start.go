@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zpdzap/sandcastles/internal/sandbox"
+	"github.com/zpdzap/sandcastles/internal/tasks"
+)
+
+// startRequest is a parsed /start command.
+type startRequest struct {
+	name string
+	task string
+	opts sandbox.CreateOptions
+}
+
+// parseStart parses "/start <name> [task...]" and the template form
+// "/start [name] @template [key=value...]", where the name defaults to the
+// template's. After a name, an @word is only taken as a template if one by
+// that name exists, so a task may start with a mention ("@alice reported").
+// Quotes are only interpreted in the template form, so a free text task can
+// contain apostrophes.
+func parseStart(projectDir, input string) (startRequest, error) {
+	var req startRequest
+	parts := strings.Fields(input)
+	if len(parts) < 2 {
+		return req, fmt.Errorf("usage: /start <name> [task] or /start [name] @template [key=value...]")
+	}
+
+	tmplAt := -1
+	switch {
+	case strings.HasPrefix(parts[1], "@"):
+		// Names can't start with @, so this can only be a template
+		tmplAt = 1
+	case len(parts) > 2 && strings.HasPrefix(parts[2], "@") && tasks.Exists(projectDir, parts[2][1:]):
+		tmplAt = 2
+	}
+	if tmplAt < 0 {
+		req.name = parts[1]
+		req.task = argsAfter(input, 2)
+		return req, nil
+	}
+
+	args, err := tasks.SplitArgs(argsAfter(input, tmplAt+1))
+	if err != nil {
+		return req, err
+	}
+	vars, err := tasks.ParseVars(args)
+	if err != nil {
+		return req, err
+	}
+	tmpl, err := tasks.Load(projectDir, strings.TrimPrefix(parts[tmplAt], "@"))
+	if err != nil {
+		return req, err
+	}
+	meta, task, err := tmpl.Render(vars)
+	if err != nil {
+		return req, err
+	}
+
+	req.name = meta.Name
+	if tmplAt == 2 {
+		req.name = parts[1]
+	}
+	if req.name == "" {
+		return req, fmt.Errorf("template %s has no default name; use /start <name> @%s", tmpl.Name, tmpl.Name)
+	}
+	req.task = task
+	req.opts = sandbox.CreateOptions{Base: meta.Base, Model: meta.Model}
+	return req, nil
+}
+
+// startTemplateRe matches a /start command whose last word is a template
+// being typed: "/start api @bug".
+var startTemplateRe = regexp.MustCompile(`^(/?start\s+(?:\S+\s+)?@)(\S*)$`)
+
+// completeStart completes a template name in the command input. It returns
+// the new input and, when the prefix is ambiguous, the candidates.
+func completeStart(projectDir, input string) (string, []string) {
+	match := startTemplateRe.FindStringSubmatch(input)
+	if match == nil {
+		return input, nil
+	}
+	candidates := tasks.Complete(projectDir, match[2])
+	switch len(candidates) {
+	case 0:
+		return input, nil
+	case 1:
+		return match[1] + candidates[0] + " ", nil
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return match[1] + prefix, candidates
+}
@@ -0,0 +1,41 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zpdzap/sandcastles/internal/tasks"
+)
+
+func TestParseStart(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(tasks.Dir(dir), 0o755)
+	tmpl := "---\nname: bugfix\nbase: main\n---\nFix {{issue}}.\n"
+	os.WriteFile(filepath.Join(tasks.Dir(dir), "bug.md"), []byte(tmpl), 0o644)
+
+	for _, tc := range []struct {
+		input      string
+		name, task string
+		base       string
+	}{
+		{"/start api add a health check", "api", "add a health check", ""},
+		{"/start fix-login @alice reported a crash", "fix-login", "@alice reported a crash", ""},
+		{"/start api @bug issue=#12", "api", "Fix #12.", "main"},
+		{"/start @bug issue=#12", "bugfix", "Fix #12.", "main"},
+	} {
+		req, err := parseStart(dir, tc.input)
+		if err != nil {
+			t.Errorf("parseStart(%q): %v", tc.input, err)
+			continue
+		}
+		if req.name != tc.name || req.task != tc.task || req.opts.Base != tc.base {
+			t.Errorf("parseStart(%q) = %q, %q, base %q; want %q, %q, base %q",
+				tc.input, req.name, req.task, req.opts.Base, tc.name, tc.task, tc.base)
+		}
+	}
+
+	if _, err := parseStart(dir, "/start @missing"); err == nil {
+		t.Error("parseStart(@missing): want a template not found error")
+	}
+}
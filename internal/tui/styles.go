@@ -38,6 +38,11 @@ var (
 	statusStopped = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
 	statusOther   = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFAA00"))
 
+	// Sidecar service lines
+	serviceRunningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#00CC00"))
+	serviceNameStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#AAAAAA"))
+	serviceDimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#666666"))
+
 	// Agent state labels
 	stateWorking = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00"))
 	stateWaiting = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD700"))
@@ -1,22 +1,23 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/zpdzap/sandcastles/internal/agent"
+	"github.com/zpdzap/sandcastles/internal/notify"
 	"github.com/zpdzap/sandcastles/internal/sandbox"
 	"golang.org/x/term"
 )
 
-var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]*$`)
-
 // setMessage sets the status bar message and returns a tea.Cmd to auto-clear it.
 // Errors clear after 10s, normal messages after 5s.
 func (m *model) setMessage(text string, isErr bool) tea.Cmd {
@@ -48,19 +49,131 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.isError = false
 		}
 		// Dispatch heavy polling to a background goroutine
-		return m, pollStatusCmd(m.manager, m.previews, m.agentStates, m.diffStats, m.attachedAt)
+		return m, pollStatusCmd(m.manager, m.previews, m.agentStates, m.diffStats, m.attachedAt, m.authRefreshedAt)
 
 	case statusPollResultMsg:
+		var notifyCmds []tea.Cmd
+		transitions := notify.Transitions(m.cfg.Project, m.agentStates, msg.agentStates)
+		if m.notifier != nil {
+			for _, ev := range m.notifier.Filter(transitions) {
+				if seq := m.notifier.Terminal(ev); seq != "" {
+					notifyCmds = append(notifyCmds, terminalNotifyCmd(m.output, seq))
+				}
+				notifyCmds = append(notifyCmds, dispatchNotifyCmd(m.notifier, ev))
+			}
+		}
+		// Run the project's checks when an agent finishes
+		if len(m.cfg.Defaults.Verify) > 0 {
+			for _, ev := range transitions {
+				if ev.State == "done" {
+					notifyCmds = append(notifyCmds, verifyCmd(m.manager, ev.Sandbox, false))
+				}
+			}
+		}
+
 		m.previews = msg.previews
 		m.agentStates = msg.agentStates
 		m.diffStats = msg.diffStats
+		// Skip prompts just answered; the capture may predate the keypress
+		for name, t := range m.answeredAt {
+			if time.Since(t) < answerSettleTime {
+				delete(msg.approvals, name)
+			} else {
+				delete(m.answeredAt, name)
+			}
+		}
+		m.approvals = msg.approvals
 		m.attachedAt = msg.attachedAt
+		m.authRefreshedAt = msg.authRefreshedAt
+
+		cmds := append([]tea.Cmd{tickCmd()}, notifyCmds...)
+		if len(msg.authRefreshed) > 0 {
+			cmds = append(cmds, m.setMessage(fmt.Sprintf("Auth failure detected in %s — credentials refreshed, retry in the session",
+				strings.Join(msg.authRefreshed, ", ")), false))
+		}
+
 		// Pick up progress updates
 		if m.progressPhase != nil && *m.progressPhase != "" {
 			m.message = fmt.Sprintf("[%s] %s", m.progressName, *m.progressPhase)
 			m.isError = false
 		}
-		return m, tickCmd()
+		return m, tea.Batch(cmds...)
+
+	case warmTickMsg:
+		mgr := m.manager
+		return m, func() tea.Msg {
+			return warmCheckResultMsg{needed: mgr.WarmNeeded()}
+		}
+
+	case warmCheckResultMsg:
+		if !msg.needed {
+			return m, warmTickCmd(warmCheckInterval)
+		}
+		mgr := m.manager
+		build := func() tea.Msg {
+			reason, err := mgr.BuildWarm(nil)
+			return warmBuiltMsg{reason: reason, err: err}
+		}
+		return m, tea.Batch(build, m.setMessage("Building warm image in the background...", false))
+
+	case warmBuiltMsg:
+		switch {
+		case errors.Is(msg.err, sandbox.ErrWarmInProgress):
+			return m, warmTickCmd(warmCheckInterval)
+		case msg.err != nil:
+			// Back off so a broken setup doesn't rebuild every interval
+			return m, tea.Batch(warmTickCmd(10*warmCheckInterval),
+				m.setMessage(fmt.Sprintf("Warm image build failed: %v", msg.err), true))
+		}
+		// Pooled containers started from the old image are replaced
+		return m, tea.Batch(warmTickCmd(warmCheckInterval), fillPoolCmd(m.manager),
+			m.setMessage(fmt.Sprintf("Warm image rebuilt (%s) — next /start skips setup", msg.reason), false))
+
+	case transcriptTickMsg:
+		return m, syncTranscriptsCmd(m.manager)
+
+	case transcriptsSyncedMsg:
+		m.costs = msg.costs
+		m.projectCost = msg.projectCost
+		if msg.err != nil {
+			// Back off so a failing copy doesn't report every interval
+			return m, tea.Batch(transcriptTickCmd(10*transcriptSyncInterval),
+				m.setMessage(fmt.Sprintf("Transcript sync failed: %v", msg.err), true))
+		}
+		return m, transcriptTickCmd(transcriptSyncInterval)
+
+	case verifiedMsg:
+		switch {
+		case msg.skipped:
+			return m, nil
+		case errors.Is(msg.err, sandbox.ErrVerifyRunning):
+			return m, m.setMessage(fmt.Sprintf("[%s] Checks are already running", msg.name), false)
+		case msg.err != nil:
+			return m, m.setMessage(fmt.Sprintf("[%s] Checks failed to run: %v", msg.name, msg.err), true)
+		case msg.result.Status == sandbox.VerifyPassed:
+			return m, m.setMessage(fmt.Sprintf("[%s] Checks passed", msg.name), false)
+		case msg.result.Error != "" && len(msg.result.Failed()) == 0:
+			return m, m.setMessage(fmt.Sprintf("[%s] Checks failed to run: %s", msg.name, msg.result.Error), true)
+		}
+		failed := strings.Join(msg.result.Failed(), ", ")
+		if sb, ok := m.manager.Get(msg.name); ok {
+			if msg.feedback != "" {
+				return m, tea.Batch(sendPromptCmd(msg.name, msg.feedback),
+					m.setMessage(fmt.Sprintf("[%s] Checks failed: %s — sent back to the agent (%d/%d)",
+						msg.name, failed, sb.Iterations, m.cfg.Defaults.MaxIterations), true))
+			}
+			if sb.NeedsAttention {
+				return m, m.setMessage(fmt.Sprintf("[%s] Checks still fail after %d attempts: %s — needs attention",
+					msg.name, sb.Iterations, failed), true)
+			}
+		}
+		return m, m.setMessage(fmt.Sprintf("[%s] Checks failed: %s — press v for the log", msg.name, failed), true)
+
+	case poolFilledMsg:
+		if msg.err != nil {
+			return m, m.setMessage(fmt.Sprintf("Container pool: %v", msg.err), true)
+		}
+		return m, nil
 
 	case sandboxCreatedMsg:
 		m.progressName = ""
@@ -69,9 +182,96 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.err != nil {
 			clearCmd = m.setMessage(fmt.Sprintf("Error: %v", msg.err), true)
 		} else {
-			clearCmd = m.setMessage(fmt.Sprintf("Created sandcastle: %s", msg.name), false)
+			text := fmt.Sprintf("Created sandcastle: %s", msg.name)
+			if msg.warm != "" {
+				text += fmt.Sprintf(" (%s)", msg.warm)
+			}
+			clearCmd = m.setMessage(text, false)
 		}
-		return m, tea.Batch(tea.ClearScreen, clearCmd)
+		return m, tea.Batch(tea.ClearScreen, clearCmd, fillPoolCmd(m.manager))
+
+	case raceStartedMsg:
+		m.progressName = ""
+		m.progressPhase = nil
+		var clearCmd tea.Cmd
+		switch {
+		case msg.err != nil && len(msg.started) > 0:
+			clearCmd = m.setMessage(fmt.Sprintf("Race %s: started %s, then: %v",
+				msg.race, strings.Join(msg.started, ", "), msg.err), true)
+		case msg.err != nil:
+			clearCmd = m.setMessage(fmt.Sprintf("Error: %v", msg.err), true)
+		default:
+			clearCmd = m.setMessage(fmt.Sprintf("Race %s: started %s — press c to compare",
+				msg.race, strings.Join(msg.started, ", ")), false)
+		}
+		return m, tea.Batch(tea.ClearScreen, clearCmd, fillPoolCmd(m.manager))
+
+	case raceFinishedMsg:
+		for _, name := range msg.stopped {
+			delete(m.previews, name)
+			delete(m.agentStates, name)
+			delete(m.diffStats, name)
+			delete(m.attachedAt, name)
+		}
+		if n := len(m.manager.List()); m.cursor >= n {
+			m.cursor = max(0, n-1)
+		}
+		text := msg.result
+		if len(msg.stopped) > 0 {
+			text += fmt.Sprintf(" — stopped %s", strings.Join(msg.stopped, ", "))
+		}
+		return m, tea.Batch(tea.ClearScreen, m.setMessage(text, false))
+
+	case credentialsSyncedMsg:
+		if len(msg.Errors) > 0 {
+			var names []string
+			for name := range msg.Errors {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return m, m.setMessage(fmt.Sprintf("Credential sync failed for %s: %v (retrying with backoff)",
+				strings.Join(names, ", "), msg.Errors[names[0]]), true)
+		}
+		return m, m.setMessage(fmt.Sprintf("Host credentials changed — synced to %d sandcastle%s",
+			len(msg.Synced), plural(len(msg.Synced))), false)
+
+	case notifyFailedMsg:
+		return m, m.setMessage(fmt.Sprintf("Notification failed: %v", msg.err), true)
+
+	case promptAnsweredMsg:
+		if errors.Is(msg.err, agent.ErrPromptChanged) {
+			// Let the next capture queue whatever the pane shows now
+			delete(m.answeredAt, msg.name)
+			return m, m.setMessage(fmt.Sprintf("[%s] Prompt changed; answer not sent", msg.name), true)
+		}
+		if msg.err != nil {
+			return m, m.setMessage(fmt.Sprintf("[%s] %v", msg.name, msg.err), true)
+		}
+		return m, m.setMessage(fmt.Sprintf("[%s] Answered: %s", msg.name, msg.label), false)
+
+	case promptSentMsg:
+		if msg.err != nil {
+			return m, m.setMessage(fmt.Sprintf("[%s] %v", msg.name, msg.err), true)
+		}
+		return m, m.setMessage(fmt.Sprintf("[%s] Prompt sent", msg.name), false)
+
+	case broadcastSentMsg:
+		sort.Strings(msg.sent)
+		sort.Strings(msg.failed)
+		text := fmt.Sprintf("Broadcast to %d: %s", len(msg.sent), strings.Join(msg.sent, ", "))
+		if len(msg.sent) == 0 {
+			text = "Broadcast reached no sandcastles"
+		}
+		if len(msg.failed) > 0 {
+			text += fmt.Sprintf(" — failed: %s", strings.Join(msg.failed, ", "))
+		}
+		return m, m.setMessage(text, len(msg.failed) > 0)
+
+	case portExposedMsg:
+		if msg.err != nil {
+			return m, m.setMessage(fmt.Sprintf("Expose failed: %v", msg.err), true)
+		}
+		return m, m.setMessage(fmt.Sprintf("[%s] Exposed :%d→:%s", msg.name, msg.port, msg.hostPort), false)
 
 	case sandboxDestroyedMsg:
 		clearCmd := m.setMessage(fmt.Sprintf("Destroyed sandcastle: %s", msg.name), false)
@@ -90,6 +290,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.cursor = 0
 		m.previews = make(map[string]string)
 		m.agentStates = make(map[string]string)
+		m.approvals = make(map[string]*agent.Prompt)
 		m.diffStats = make(map[string]diffStat)
 		m.attachedAt = make(map[string]time.Time)
 		return m, tea.Batch(tea.ClearScreen, clearCmd)
@@ -97,6 +298,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case attachFinishedMsg:
 		m.attaching = false
 		m.attachedAt[msg.name] = time.Now()
+		m.manager.ClearAttention(msg.name)
 		fmt.Fprint(os.Stdout, "\033[2J\033[H")
 		w, h, _ := term.GetSize(int(os.Stdout.Fd()))
 		if w > 0 && h > 0 {
@@ -118,13 +320,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.prompting {
+			return m.handlePromptMode(msg)
+		}
 		if m.commanding {
 			return m.handleCommandMode(msg)
 		}
 		return m.handleNormalMode(msg)
 	}
 
-	// Forward to input if in command mode
+	// Forward to the prompt editor or command input (e.g. cursor blink)
+	if m.prompting {
+		var cmd tea.Cmd
+		m.prompt, cmd = m.prompt.Update(msg)
+		return m, cmd
+	}
 	if m.commanding {
 		var cmd tea.Cmd
 		m.input, cmd = m.input.Update(msg)
@@ -147,6 +357,24 @@ func (m model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	}
+	if m.showApprovals {
+		return m.handleApprovalsMode(msg)
+	}
+	if m.showRace {
+		return m.handleRaceMode(msg)
+	}
+	if m.showVerify {
+		if msg.String() == "v" || msg.String() == "esc" {
+			m.showVerify = false
+			m.verifyContent = ""
+			return m, nil
+		}
+		if msg.String() == "ctrl+c" || msg.String() == "q" {
+			m.quitting = true
+			return m, tea.Quit
+		}
+		return m, nil
+	}
 	if m.showDiff {
 		if msg.String() == "d" || msg.String() == "esc" {
 			m.showDiff = false
@@ -220,6 +448,46 @@ func (m model) handleNormalMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case "v":
+		sandboxes := m.manager.List()
+		if m.cursor < len(sandboxes) {
+			content, err := m.verifyReport(sandboxes[m.cursor])
+			if err != nil {
+				return m, m.setMessage(err.Error(), true)
+			}
+			m.verifyContent = content
+			m.showVerify = true
+		}
+		return m, nil
+
+	case "c":
+		sandboxes := m.manager.List()
+		if m.cursor < len(sandboxes) {
+			sb := sandboxes[m.cursor]
+			if sb.Race == "" {
+				return m, m.setMessage(fmt.Sprintf("%s is not part of a race (/race)", sb.Name), true)
+			}
+			m.showRace = true
+			m.raceName = sb.Race
+			m.raceCursor = 0
+		}
+		return m, nil
+
+	case "a":
+		m.showApprovals = true
+		m.approvalCursor = 0
+		return m, nil
+
+	case "p":
+		sandboxes := m.manager.List()
+		if m.cursor < len(sandboxes) {
+			m.prompting = true
+			m.promptName = sandboxes[m.cursor].Name
+			m.prompt.Reset()
+			return m, m.prompt.Focus()
+		}
+		return m, nil
+
 	case "m":
 		sandboxes := m.manager.List()
 		if m.cursor < len(sandboxes) {
@@ -311,6 +579,15 @@ func (m model) handleCommandMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.commanding = false
 		m.input.Blur()
 		return m.processInput()
+
+	case "tab":
+		value, candidates := completeStart(m.manager.ProjectDir(), m.input.Value())
+		m.input.SetValue(value)
+		m.input.CursorEnd()
+		if len(candidates) > 0 {
+			return m, m.setMessage("@"+strings.Join(candidates, "  @"), false)
+		}
+		return m, nil
 	}
 
 	var cmd tea.Cmd
@@ -318,6 +595,112 @@ func (m model) handleCommandMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// answerSettleTime is how long an answered prompt stays out of the approval
+// queue, so a capture taken just before the keypress doesn't re-add it.
+const answerSettleTime = 4 * time.Second
+
+// approvalQueue returns the names of sandcastles with a pending prompt, in
+// column order.
+func (m model) approvalQueue() []string {
+	var names []string
+	for _, sb := range m.manager.List() {
+		if _, ok := m.approvals[sb.Name]; ok {
+			names = append(names, sb.Name)
+		}
+	}
+	return names
+}
+
+// handleApprovalsMode handles keys while the approval queue is open: arrows
+// move between pending prompts, a digit answers the focused one, and enter
+// attaches to it.
+func (m model) handleApprovalsMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	queue := m.approvalQueue()
+	m.approvalCursor = min(m.approvalCursor, max(0, len(queue)-1))
+
+	key := msg.String()
+	switch key {
+	case "ctrl+c", "q":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "a", "esc":
+		m.showApprovals = false
+		return m, nil
+
+	case "up", "k", "left", "h":
+		if m.approvalCursor > 0 {
+			m.approvalCursor--
+		}
+		return m, nil
+
+	case "down", "j", "right", "l":
+		if m.approvalCursor < len(queue)-1 {
+			m.approvalCursor++
+		}
+		return m, nil
+
+	case "enter":
+		if len(queue) == 0 {
+			return m, nil
+		}
+		name := queue[m.approvalCursor]
+		m.showApprovals = false
+		m.attaching = true
+		m.attachedAt[name] = time.Now()
+		return m, tea.ExecProcess(m.manager.ConnectCmd(name), func(err error) tea.Msg {
+			return attachFinishedMsg{name: name}
+		})
+	}
+
+	if len(queue) == 0 {
+		return m, nil
+	}
+	name := queue[m.approvalCursor]
+	p := m.approvals[name]
+	for _, opt := range p.Options {
+		if opt.Key == key {
+			delete(m.approvals, name)
+			m.answeredAt[name] = time.Now()
+			if len(queue) == 1 {
+				m.showApprovals = false
+			}
+			return m, answerPromptCmd(name, p, opt)
+		}
+	}
+	return m, nil
+}
+
+// handlePromptMode handles keys while the prompt editor is open. Enter adds
+// a newline; ctrl+s sends the prompt.
+func (m model) handlePromptMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		m.prompting = false
+		m.prompt.Blur()
+		return m, nil
+
+	case "ctrl+s":
+		text := strings.TrimSpace(m.prompt.Value())
+		if text == "" {
+			return m, nil
+		}
+		m.prompting = false
+		m.prompt.Blur()
+		m.prompt.Reset()
+		m.manager.ClearAttention(m.promptName)
+		return m, sendPromptCmd(m.promptName, text)
+	}
+
+	var cmd tea.Cmd
+	m.prompt, cmd = m.prompt.Update(msg)
+	return m, cmd
+}
+
 func (m model) processInput() (tea.Model, tea.Cmd) {
 	input := strings.TrimSpace(m.input.Value())
 	m.input.SetValue("")
@@ -339,15 +722,15 @@ func (m model) processInput() (tea.Model, tea.Cmd) {
 	switch parts[0] {
 	case "start":
 		if len(parts) < 2 {
-			return m, m.setMessage("Usage: /start <name> [task description]", true)
+			return m, m.setMessage("Usage: /start <name> [task description] or /start [name] @template [key=value...]", true)
 		}
-		name := parts[1]
-		if !validName.MatchString(name) {
-			return m, m.setMessage("Name must be alphanumeric (hyphens ok, e.g. my-sandbox)", true)
+		req, err := parseStart(m.manager.ProjectDir(), input)
+		if err != nil {
+			return m, m.setMessage(err.Error(), true)
 		}
-		task := ""
-		if len(parts) > 2 {
-			task = strings.Join(parts[2:], " ")
+		name, task, opts := req.name, req.task, req.opts
+		if !sandbox.ValidName.MatchString(name) {
+			return m, m.setMessage("Name must be alphanumeric (hyphens ok, e.g. my-sandbox)", true)
 		}
 		m.progressName = name
 		phase := "Starting..."
@@ -360,15 +743,42 @@ func (m model) processInput() (tea.Model, tea.Cmd) {
 			progress := func(p string) {
 				*pp = p
 			}
-			sb, err := m.manager.Create(name, task, progress)
+			sb, err := m.manager.Create(name, task, opts, progress)
 			if err != nil {
 				return sandboxCreatedMsg{name: name, err: err}
 			}
 			// Auto-start Claude in background (non-blocking, non-fatal)
-			go agent.Start(fmt.Sprintf("sc-%s", sb.Name), task)
-			return sandboxCreatedMsg{name: name}
+			go agent.Start(fmt.Sprintf("sc-%s", sb.Name), task, agent.Options{Model: sb.Model})
+			return sandboxCreatedMsg{name: name, warm: sb.Warm}
 		}
 
+	case "race":
+		req, err := parseRace(input)
+		if err != nil {
+			return m, m.setMessage(err.Error(), true)
+		}
+		if !sandbox.ValidName.MatchString(req.name) {
+			return m, m.setMessage("Name must be alphanumeric (hyphens ok, e.g. my-sandbox)", true)
+		}
+		m.progressName = req.name
+		phase := "Starting..."
+		m.progressPhase = &phase
+		m.message = fmt.Sprintf("[%s] Starting %d attempts...", req.name, req.n)
+		m.isError = false
+		return m, startRaceCmd(m.manager, req, m.progressPhase)
+
+	case "compare":
+		if len(parts) < 2 {
+			return m, m.setMessage("Usage: /compare <race>", true)
+		}
+		if len(m.manager.Race(parts[1])) == 0 {
+			return m, m.setMessage(fmt.Sprintf("No race named %q", parts[1]), true)
+		}
+		m.showRace = true
+		m.raceName = parts[1]
+		m.raceCursor = 0
+		return m, nil
+
 	case "stop":
 		if len(parts) < 2 {
 			return m, m.setMessage("Usage: /stop <name> or /stop all", true)
@@ -398,6 +808,72 @@ func (m model) processInput() (tea.Model, tea.Cmd) {
 			return sandboxDestroyedMsg{name: name}
 		}
 
+	case "verify":
+		if len(parts) < 2 {
+			return m, m.setMessage("Usage: /verify <name>", true)
+		}
+		name := parts[1]
+		if _, ok := m.manager.Get(name); !ok {
+			return m, m.setMessage(fmt.Sprintf("Sandcastle %q not found", name), true)
+		}
+		if len(m.cfg.Defaults.Verify) == 0 {
+			return m, m.setMessage(sandbox.ErrNoVerify.Error(), true)
+		}
+		m.message = fmt.Sprintf("[%s] Running checks...", name)
+		m.isError = false
+		return m, verifyCmd(m.manager, name, true)
+
+	case "send":
+		if len(parts) < 3 {
+			return m, m.setMessage("Usage: /send <name> <message>", true)
+		}
+		name := parts[1]
+		if _, ok := m.manager.Get(name); !ok {
+			return m, m.setMessage(fmt.Sprintf("Sandcastle %q not found", name), true)
+		}
+		m.manager.ClearAttention(name)
+		return m, sendPromptCmd(name, argsAfter(input, 2))
+
+	case "broadcast":
+		filter, text, err := parseBroadcast(input)
+		if err != nil {
+			return m, m.setMessage(fmt.Sprintf("broadcast: %v", err), true)
+		}
+		if text == "" {
+			return m, m.setMessage("Usage: /broadcast [--state working|waiting|done] [--tag <tag>] [--name <glob>] <message>", true)
+		}
+		var names []string
+		for _, sb := range m.manager.List() {
+			if sb.Status == sandbox.StatusRunning && filter.matches(sb, m.agentStates[sb.Name]) {
+				names = append(names, sb.Name)
+			}
+		}
+		if len(names) == 0 {
+			return m, m.setMessage("No running sandcastles match", true)
+		}
+		m.message = fmt.Sprintf("Broadcasting to %d sandcastle%s...", len(names), plural(len(names)))
+		m.isError = false
+		return m, broadcastCmd(names, text)
+
+	case "tag", "untag":
+		if len(parts) < 3 {
+			return m, m.setMessage(fmt.Sprintf("Usage: /%s <name> <tag>...", parts[0]), true)
+		}
+		name, tags := parts[1], parts[2:]
+		for _, tag := range tags {
+			if !sandbox.ValidName.MatchString(tag) {
+				return m, m.setMessage("Tags must be alphanumeric (hyphens ok)", true)
+			}
+		}
+		tagFn, verb := m.manager.Tag, "Tagged"
+		if parts[0] == "untag" {
+			tagFn, verb = m.manager.Untag, "Untagged"
+		}
+		if err := tagFn(name, tags...); err != nil {
+			return m, m.setMessage(err.Error(), true)
+		}
+		return m, m.setMessage(fmt.Sprintf("[%s] %s %s", name, verb, strings.Join(tags, ", ")), false)
+
 	case "connect":
 		if len(parts) < 2 {
 			return m, m.setMessage("Usage: /connect <name>", true)
@@ -459,6 +935,36 @@ func (m model) processInput() (tea.Model, tea.Cmd) {
 		}
 		return m, m.setMessage(fmt.Sprintf("[%s] Credentials reauthorized", name), false)
 
+	case "expose":
+		if len(parts) < 3 {
+			return m, m.setMessage("Usage: /expose <name> <port>", true)
+		}
+		name := parts[1]
+		port, err := sandbox.ParsePort(parts[2])
+		if err != nil {
+			return m, m.setMessage(fmt.Sprintf("Invalid port: %s", parts[2]), true)
+		}
+		m.message = fmt.Sprintf("[%s] Exposing port %d...", name, port)
+		m.isError = false
+		return m, func() tea.Msg {
+			hostPort, err := m.manager.Expose(name, port)
+			return portExposedMsg{name: name, port: port, hostPort: hostPort, err: err}
+		}
+
+	case "unexpose":
+		if len(parts) < 3 {
+			return m, m.setMessage("Usage: /unexpose <name> <port>", true)
+		}
+		name := parts[1]
+		port, err := sandbox.ParsePort(parts[2])
+		if err != nil {
+			return m, m.setMessage(fmt.Sprintf("Invalid port: %s", parts[2]), true)
+		}
+		if err := m.manager.Unexpose(name, port); err != nil {
+			return m, m.setMessage(fmt.Sprintf("Unexpose failed: %v", err), true)
+		}
+		return m, m.setMessage(fmt.Sprintf("[%s] Unexposed port %d", name, port), false)
+
 	case "quit":
 		m.quitting = true
 		return m, tea.Quit
@@ -468,6 +974,20 @@ func (m model) processInput() (tea.Model, tea.Cmd) {
 	}
 }
 
+// argsAfter returns the input following its first n fields, keeping the
+// rest's spacing intact (for free-form text like prompts).
+func argsAfter(input string, n int) string {
+	rest := strings.TrimSpace(input)
+	for range n {
+		i := strings.IndexFunc(rest, unicode.IsSpace)
+		if i < 0 {
+			return ""
+		}
+		rest = strings.TrimLeftFunc(rest[i:], unicode.IsSpace)
+	}
+	return rest
+}
+
 // pollStatusCmd runs all docker exec calls in a background goroutine and
 // returns the results as a statusPollResultMsg. This keeps Update() non-blocking.
 func pollStatusCmd(
@@ -476,6 +996,7 @@ func pollStatusCmd(
 	prevAgentStates map[string]string,
 	prevDiffStats map[string]diffStat,
 	prevAttachedAt map[string]time.Time,
+	prevAuthRefreshedAt map[string]time.Time,
 ) tea.Cmd {
 	// Copy maps to avoid races with the main goroutine
 	copyPreviews := make(map[string]string, len(prevPreviews))
@@ -494,12 +1015,19 @@ func pollStatusCmd(
 	for k, v := range prevAttachedAt {
 		copyAttachedAt[k] = v
 	}
+	copyAuthRefreshedAt := make(map[string]time.Time, len(prevAuthRefreshedAt))
+	for k, v := range prevAuthRefreshedAt {
+		copyAuthRefreshedAt[k] = v
+	}
 
 	return func() tea.Msg {
 		mgr.RefreshStatuses()
 
+		var authRefreshed []string
+
 		previews := make(map[string]string)
 		agentStates := make(map[string]string)
+		approvals := make(map[string]*agent.Prompt)
 		diffStats := make(map[string]diffStat)
 
 		for _, sb := range mgr.List() {
@@ -542,26 +1070,69 @@ func pollStatusCmd(
 			delete(copyAttachedAt, sb.Name)
 
 			// Capture pane output for preview
-			out, err := exec.Command("docker", "exec", containerName,
-				"tmux", "capture-pane", "-t", "main", "-p", "-S", "-30").CombinedOutput()
+			output, err := agent.Capture(containerName)
 			if err != nil {
 				continue
 			}
-			output := string(out)
 			prevOutput := copyPreviews[sb.Name]
 			previews[sb.Name] = output
 
 			agentStates[sb.Name] = detectAgentState(output, prevOutput)
+			if agentStates[sb.Name] == "waiting" {
+				if p := agent.ParsePrompt(output); p != nil {
+					approvals[sb.Name] = p
+				}
+			}
 			diffStats[sb.Name] = fetchDiffStats(sb.Name)
+
+			// Re-copy credentials when the agent reports an auth failure,
+			// at most once per cooldown so a stale error on screen doesn't loop
+			if detectAuthFailure(output) && time.Since(copyAuthRefreshedAt[sb.Name]) > authRefreshCooldown {
+				copyAuthRefreshedAt[sb.Name] = time.Now()
+				if err := mgr.RefreshCredentials(sb.Name); err == nil {
+					authRefreshed = append(authRefreshed, sb.Name)
+				}
+			}
 		}
 
 		return statusPollResultMsg{
-			previews:    previews,
-			agentStates: agentStates,
-			diffStats:   diffStats,
-			attachedAt:  copyAttachedAt,
+			previews:        previews,
+			agentStates:     agentStates,
+			approvals:       approvals,
+			diffStats:       diffStats,
+			attachedAt:      copyAttachedAt,
+			authRefreshedAt: copyAuthRefreshedAt,
+			authRefreshed:   authRefreshed,
+		}
+	}
+}
+
+// authRefreshCooldown is the minimum time between automatic reauths of one sandbox.
+const authRefreshCooldown = 2 * time.Minute
+
+// authFailurePatterns are Claude Code messages indicating its credentials were rejected.
+var authFailurePatterns = []string{
+	"OAuth token has expired",
+	"OAuth token revoked",
+	"Please run /login",
+	"Invalid API key",
+	"authentication_error",
+	"API Error: 401",
+}
+
+// detectAuthFailure reports whether the recent pane output shows an auth error.
+func detectAuthFailure(output string) bool {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > 15 {
+		lines = lines[len(lines)-15:]
+	}
+	recent := strings.Join(lines, "\n")
+	for _, p := range authFailurePatterns {
+		if strings.Contains(recent, p) {
+			return true
 		}
 	}
+	return false
 }
 
 // detectAgentState infers the agent's state from output changes and UI patterns.
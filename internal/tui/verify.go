@@ -0,0 +1,73 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/zpdzap/sandcastles/internal/sandbox"
+)
+
+// verifyReport renders a sandbox's last verify run for the v modal: a line
+// per command, then as much of the end of the log as fits on screen.
+func (m model) verifyReport(sb *sandbox.Sandbox) (string, error) {
+	v := sb.Verify
+	if v == nil {
+		if len(m.cfg.Defaults.Verify) == 0 {
+			return "", sandbox.ErrNoVerify
+		}
+		return "", fmt.Errorf("no checks have run for %s yet (/verify %s)", sb.Name, sb.Name)
+	}
+
+	lines := []string{helpHeaderStyle.Render(fmt.Sprintf("Checks for %s: %s", sb.Name, v.Status)), ""}
+	if v.Error != "" {
+		lines = append(lines, errorStyle.Render("Couldn't run the checks: "+v.Error))
+	}
+	for _, c := range v.Checks {
+		mark := serviceRunningStyle.Render("✓")
+		if c.ExitCode != 0 {
+			mark = errorStyle.Render("✗")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s  %s", mark, c.Command, serviceDimStyle.Render(c.Duration.String())))
+	}
+
+	logWidth := max(20, m.width-12)
+	logHeight := max(3, m.height-len(lines)-10)
+	if data, err := os.ReadFile(m.manager.VerifyLogPath(sb.Name)); err == nil {
+		logLines := strings.Split(strings.TrimRight(ansi.Strip(string(data)), "\n"), "\n")
+		if len(logLines) > logHeight {
+			logLines = logLines[len(logLines)-logHeight:]
+		}
+		lines = append(lines, "")
+		for _, l := range logLines {
+			lines = append(lines, serviceNameStyle.Render(ansi.Truncate(strings.ReplaceAll(l, "\t", "    "), logWidth, "…")))
+		}
+	}
+
+	lines = append(lines, "", serviceDimStyle.Render(fmt.Sprintf("Full log: %s · v or esc to close", m.manager.VerifyLogPath(sb.Name))))
+	return strings.Join(lines, "\n"), nil
+}
+
+// verifyBadge is the column header label for a sandbox's checks.
+func verifyBadge(sb *sandbox.Sandbox) string {
+	if sb.Verify == nil {
+		return ""
+	}
+	var badge string
+	switch sb.Verify.Status {
+	case sandbox.VerifyRunning:
+		badge = "checking…"
+	case sandbox.VerifyPassed:
+		return "✓ checks"
+	default:
+		badge = "✗ checks"
+	}
+	if sb.NeedsAttention {
+		return badge + " ⚠ needs attention"
+	}
+	if sb.Iterations > 0 {
+		badge += fmt.Sprintf(" (retry %d)", sb.Iterations)
+	}
+	return badge
+}
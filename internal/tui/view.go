@@ -8,6 +8,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/x/ansi"
 	"github.com/zpdzap/sandcastles/internal/sandbox"
+	"github.com/zpdzap/sandcastles/internal/usage"
 )
 
 func (m model) View() string {
@@ -19,6 +20,9 @@ func (m model) View() string {
 
 	// Header — always shown
 	title := "sandcastles v0.2.3"
+	if m.projectCost > 0 {
+		title += "  " + usage.FormatCost(m.projectCost) + " total"
+	}
 	quip := quipStyle.Render(m.quip)
 	gap := m.width - lipgloss.Width(title) - lipgloss.Width(quip) - 4
 	if gap < 1 {
@@ -57,6 +61,9 @@ func (m model) renderEmptyState(header string) string {
 	m.renderStatusAndInput(&b)
 
 	// Modal overlays
+	if m.showApprovals {
+		return m.renderApprovalsOverlay(b.String())
+	}
 	if m.showDiff {
 		return m.renderModalOverlay(b.String(), m.diffContent, lipgloss.Color("#5599FF"))
 	}
@@ -119,7 +126,14 @@ func (m model) renderSplitView(header string, sandboxes []*sandbox.Sandbox) stri
 	} else if m.confirmStop {
 		b.WriteString(confirmStyle.Render(fmt.Sprintf("Stop %s? Press x again to confirm, any other key to cancel", m.confirmStopName)))
 	} else {
-		b.WriteString(hotkeysStyle.Render("[◀ ▶] select  [enter] connect  [s]tart  [x] stop  [d]iff  [m]erge  re[b]ase  [r]eauth  [?] help"))
+		hotkeys := "[◀ ▶] select  [enter] connect  [s]tart  [x] stop  [p]rompt  [d]iff  [v]erify log  [m]erge  re[b]ase  [r]eauth  [?] help"
+		if sandboxes := m.manager.List(); m.cursor < len(sandboxes) && sandboxes[m.cursor].Race != "" {
+			hotkeys = strings.Replace(hotkeys, "[m]erge", "[c]ompare  [m]erge", 1)
+		}
+		if n := len(m.approvals); n > 0 {
+			hotkeys = fmt.Sprintf("[a]pprove (%d)  ", n) + hotkeys
+		}
+		b.WriteString(hotkeysStyle.Render(hotkeys))
 	}
 	b.WriteString("\n")
 
@@ -128,9 +142,21 @@ func (m model) renderSplitView(header string, sandboxes []*sandbox.Sandbox) stri
 
 	// Modal overlays
 	base := b.String()
+	if m.prompting {
+		return m.renderPromptOverlay(base)
+	}
+	if m.showApprovals {
+		return m.renderApprovalsOverlay(base)
+	}
+	if m.showRace {
+		return m.renderRaceOverlay(base)
+	}
 	if m.showDiff {
 		return m.renderModalOverlay(base, m.diffContent, lipgloss.Color("#5599FF"))
 	}
+	if m.showVerify {
+		return m.renderModalOverlay(base, m.verifyContent, lipgloss.Color("#5599FF"))
+	}
 	if m.showHelp {
 		return m.renderHelpOverlay(base)
 	}
@@ -149,15 +175,28 @@ func (m model) renderColumn(index int, sb *sandbox.Sandbox, width, height int) s
 
 	icon, _ := m.agentIcon(sb)
 	headerText := icon + " " + sb.Name
+	for _, tag := range sb.Tags {
+		headerText += " #" + tag
+	}
+	if sb.Race != "" && sb.Model != "" {
+		headerText += " [" + sb.Model + "]"
+	}
 
 	if sb.Status == sandbox.StatusRunning {
 		switch m.agentStates[sb.Name] {
 		case "waiting":
-			headerText += " waiting"
+			if _, ok := m.approvals[sb.Name]; ok {
+				headerText += " needs approval"
+			} else {
+				headerText += " waiting"
+			}
 		case "done":
 			headerText += " done"
 		}
 	}
+	if badge := verifyBadge(sb); badge != "" {
+		headerText += " " + badge
+	}
 
 	// Port mappings
 	portKeys := make([]string, 0, len(sb.Ports))
@@ -199,6 +238,9 @@ func (m model) renderColumn(index int, sb *sandbox.Sandbox, width, height int) s
 	} else if sb.Status == sandbox.StatusRunning {
 		statsText = s(lipgloss.Color("#888888"), "no changes")
 	}
+	if cost := m.costs[sb.Name]; cost > 0 {
+		statsText = s(headerFg, usage.FormatCost(cost)+"  ") + statsText
+	}
 
 	// Layout: left-align name, right-align stats
 	var headerLine string
@@ -216,8 +258,14 @@ func (m model) renderColumn(index int, sb *sandbox.Sandbox, width, height int) s
 	}
 	header := hStyle.Width(width).Render(headerLine)
 
-	// Preview content — fills remaining height below header
-	contentHeight := height - 1 // 1 line for header
+	// Sidecar services, one line each below the header
+	var serviceLines []string
+	for _, svc := range sb.Services {
+		serviceLines = append(serviceLines, ansi.Truncate(m.renderService(svc), width, ""))
+	}
+
+	// Preview content — fills remaining height below header and services
+	contentHeight := height - 1 - len(serviceLines) // 1 line for header
 	if contentHeight < 1 {
 		contentHeight = 1
 	}
@@ -245,9 +293,39 @@ func (m model) renderColumn(index int, sb *sandbox.Sandbox, width, height int) s
 		content = columnContentStyle.Render("Waiting for output...")
 	}
 
+	if len(serviceLines) > 0 {
+		header += "\n" + strings.Join(serviceLines, "\n")
+	}
 	return header + "\n" + content
 }
 
+// renderService renders a sidecar service line: status icon, name, health and ports.
+func (m model) renderService(svc sandbox.ServiceInfo) string {
+	style := serviceRunningStyle
+	switch svc.Status {
+	case "healthy", "running":
+	case "starting", "created", "restarting":
+		style = statusOther
+	default:
+		style = statusStopped
+	}
+
+	text := style.Render("⛁") + serviceNameStyle.Render(" "+svc.Name)
+	if svc.Status != "" && svc.Status != "running" {
+		text += serviceDimStyle.Render(" " + svc.Status)
+	}
+
+	portKeys := make([]string, 0, len(svc.Ports))
+	for k := range svc.Ports {
+		portKeys = append(portKeys, k)
+	}
+	sort.Strings(portKeys)
+	for _, container := range portKeys {
+		text += portStyle.Render(" :" + container + "→:" + svc.Ports[container])
+	}
+	return text
+}
+
 // agentIcon returns the status icon and style for a sandbox.
 // For running sandboxes, it reflects agent state; otherwise container status.
 func (m model) agentIcon(sb *sandbox.Sandbox) (string, lipgloss.Style) {
@@ -297,20 +375,34 @@ func (m model) renderHelpOverlay(base string) string {
 		helpHeaderStyle.Render("Actions"),
 		helpKeyStyle.Render("  s") + helpDescStyle.Render("           Start a new sandbox"),
 		helpKeyStyle.Render("  x") + helpDescStyle.Render("           Stop selected sandbox"),
+		helpKeyStyle.Render("  p") + helpDescStyle.Render("           Send a prompt to the agent"),
+		helpKeyStyle.Render("  a") + helpDescStyle.Render("           Approval queue (answer agent prompts)"),
 		helpKeyStyle.Render("  d") + helpDescStyle.Render("           Diff selected sandbox"),
+		helpKeyStyle.Render("  v") + helpDescStyle.Render("           Results and log of the checks"),
 		helpKeyStyle.Render("  m") + helpDescStyle.Render("           Merge selected sandbox"),
+		helpKeyStyle.Render("  c") + helpDescStyle.Render("           Compare the attempts of its race"),
 		helpKeyStyle.Render("  b") + helpDescStyle.Render("           Rebase onto local main"),
 		helpKeyStyle.Render("  r") + helpDescStyle.Render("           Reauth credentials"),
 		"",
 		helpHeaderStyle.Render("Commands"),
 		helpKeyStyle.Render("  /") + helpDescStyle.Render("           Open command bar"),
 		helpDescStyle.Render("  /start <name> [task]"),
+		helpDescStyle.Render("  /start [name] @template [key=value...]"),
+		helpDescStyle.Render("  /race <name> <n> [--base ref] [--models a,b] <task>"),
+		helpDescStyle.Render("      every attempt gets the same task; only the model varies"),
+		helpDescStyle.Render("  /compare <race>"),
 		helpDescStyle.Render("  /stop <name|all>"),
 		helpDescStyle.Render("  /connect <name>"),
+		helpDescStyle.Render("  /send <name> <message>"),
+		helpDescStyle.Render("  /broadcast [--state s] [--tag t] [--name glob] <message>"),
+		helpDescStyle.Render("  /tag, /untag <name> <tag>..."),
 		helpDescStyle.Render("  /diff <name>"),
+		helpDescStyle.Render("  /verify <name>"),
 		helpDescStyle.Render("  /merge <name>"),
 		helpDescStyle.Render("  /rebase <name>"),
 		helpDescStyle.Render("  /reauth <name>"),
+		helpDescStyle.Render("  /expose <name> <port>"),
+		helpDescStyle.Render("  /unexpose <name> <port>"),
 		"",
 		helpKeyStyle.Render("  q") + helpDescStyle.Render("  quit") + "     " + helpKeyStyle.Render("?") + helpDescStyle.Render("  close this help"),
 	}, "\n")
@@ -318,6 +410,56 @@ func (m model) renderHelpOverlay(base string) string {
 	return m.renderModalOverlay(base, help, lipgloss.Color("#FFD700"))
 }
 
+// renderApprovalsOverlay shows the queue of agent prompts awaiting an answer.
+// The focused prompt is shown in full; the rest as one line each.
+func (m model) renderApprovalsOverlay(base string) string {
+	queue := m.approvalQueue()
+	lines := []string{helpHeaderStyle.Render(fmt.Sprintf("Approvals (%d)", len(queue))), ""}
+	if len(queue) == 0 {
+		lines = append(lines, helpDescStyle.Render("No agents are waiting on a prompt."))
+	}
+	width := max(40, min(100, m.width-10))
+	cursor := min(m.approvalCursor, max(0, len(queue)-1))
+	for i, name := range queue {
+		p := m.approvals[name]
+		if i != cursor {
+			lines = append(lines, helpDescStyle.Render(ansi.Truncate("  "+name+": "+p.Question, width, "…")))
+			continue
+		}
+		lines = append(lines, helpKeyStyle.Render("▸ "+name+": ")+ansi.Truncate(p.Question, width-len(name)-4, "…"))
+		for _, d := range p.Details {
+			lines = append(lines, helpDescStyle.Render(ansi.Truncate("    "+d, width, "…")))
+		}
+		for j, opt := range p.Options {
+			marker := "  "
+			if j == p.Selected {
+				marker = "❯ "
+			}
+			lines = append(lines, "  "+marker+helpKeyStyle.Render(opt.Key)+" "+ansi.Truncate(opt.Label, width-8, "…"))
+		}
+	}
+	lines = append(lines, "",
+		helpKeyStyle.Render("1-9")+helpDescStyle.Render(" answer  ")+
+			helpKeyStyle.Render("↑/↓")+helpDescStyle.Render(" next prompt  ")+
+			helpKeyStyle.Render("enter")+helpDescStyle.Render(" connect  ")+
+			helpKeyStyle.Render("esc")+helpDescStyle.Render(" close"))
+	return m.renderModalOverlay(base, strings.Join(lines, "\n"), lipgloss.Color("#FFAA00"))
+}
+
+// renderPromptOverlay shows the multi-line prompt editor for the selected agent.
+func (m model) renderPromptOverlay(base string) string {
+	content := strings.Join([]string{
+		helpHeaderStyle.Render("Send to " + m.promptName),
+		"",
+		m.prompt.View(),
+		"",
+		helpKeyStyle.Render("ctrl+s") + helpDescStyle.Render(" send  ") +
+			helpKeyStyle.Render("enter") + helpDescStyle.Render(" newline  ") +
+			helpKeyStyle.Render("esc") + helpDescStyle.Render(" cancel"),
+	}, "\n")
+	return m.renderModalOverlay(base, content, lipgloss.Color("#7B68EE"))
+}
+
 // renderModalOverlay centers styled content over the base view.
 func (m model) renderModalOverlay(base, content string, borderColor lipgloss.Color) string {
 	style := lipgloss.NewStyle().
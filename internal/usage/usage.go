@@ -0,0 +1,232 @@
+// Package usage reads token usage from Claude Code session transcripts and
+// estimates what it cost from a per-model price table.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+// Tokens counts the tokens of one or more API responses.
+type Tokens struct {
+	Input      int64 `json:"input"`
+	Output     int64 `json:"output"`
+	CacheWrite int64 `json:"cache_write"`
+	CacheRead  int64 `json:"cache_read"`
+}
+
+// Add adds o's counts to t.
+func (t *Tokens) Add(o Tokens) {
+	t.Input += o.Input
+	t.Output += o.Output
+	t.CacheWrite += o.CacheWrite
+	t.CacheRead += o.CacheRead
+}
+
+// Total is the sum of every kind of token.
+func (t Tokens) Total() int64 {
+	return t.Input + t.Output + t.CacheWrite + t.CacheRead
+}
+
+// Record is the usage of one API response.
+type Record struct {
+	Time  time.Time
+	Model string
+	Tokens
+}
+
+// Parse reads the usage records of a session transcript. Claude Code writes
+// one line per content block of a response, each repeating the response's
+// usage, so records are deduplicated by message ID (the last line wins).
+// Responses that used no tokens, such as synthetic error messages, are skipped.
+func Parse(r io.Reader) ([]Record, error) {
+	var records []Record
+	seen := make(map[string]int)
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if rec, id, ok := parseLine(line); ok {
+			if i, dup := seen[id]; dup && id != "" {
+				records[i] = rec
+			} else {
+				seen[id] = len(records)
+				records = append(records, rec)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+	}
+}
+
+// parseLine decodes an assistant transcript line's usage and message ID.
+func parseLine(line []byte) (Record, string, bool) {
+	if !strings.Contains(string(line), `"usage"`) {
+		return Record{}, "", false
+	}
+	var e struct {
+		Type      string    `json:"type"`
+		Timestamp time.Time `json:"timestamp"`
+		Message   struct {
+			ID    string `json:"id"`
+			Model string `json:"model"`
+			Usage struct {
+				Input      int64 `json:"input_tokens"`
+				Output     int64 `json:"output_tokens"`
+				CacheWrite int64 `json:"cache_creation_input_tokens"`
+				CacheRead  int64 `json:"cache_read_input_tokens"`
+			} `json:"usage"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(line, &e) != nil || e.Type != "assistant" {
+		return Record{}, "", false
+	}
+	u := e.Message.Usage
+	rec := Record{
+		Time:   e.Timestamp,
+		Model:  e.Message.Model,
+		Tokens: Tokens{Input: u.Input, Output: u.Output, CacheWrite: u.CacheWrite, CacheRead: u.CacheRead},
+	}
+	if rec.Total() == 0 {
+		return Record{}, "", false
+	}
+	return rec, e.Message.ID, true
+}
+
+// DefaultPrices are Anthropic's list prices in USD per million tokens, keyed
+// by model name prefix. Cache writes are the 5-minute rate.
+var DefaultPrices = PriceTable{
+	"claude-opus-4":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+	"claude-opus-4-5":   {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50},
+	"claude-sonnet-4":   {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"claude-3-7-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"claude-3-5-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"claude-haiku-4":    {Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.10},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+}
+
+// PriceTable maps model name prefixes to prices.
+type PriceTable map[string]config.ModelPrice
+
+// NewPriceTable returns the default prices with overrides applied on top.
+func NewPriceTable(overrides map[string]config.ModelPrice) PriceTable {
+	p := maps.Clone(DefaultPrices)
+	maps.Copy(p, overrides)
+	return p
+}
+
+// Lookup returns the price of a model by its longest matching prefix.
+func (p PriceTable) Lookup(model string) (config.ModelPrice, bool) {
+	best := ""
+	for prefix := range p {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return config.ModelPrice{}, false
+	}
+	return p[best], true
+}
+
+// Cost estimates what tokens cost on a model in USD. Returns false if the
+// model has no price.
+func (p PriceTable) Cost(model string, t Tokens) (float64, bool) {
+	price, ok := p.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	cost := float64(t.Input)*price.Input +
+		float64(t.Output)*price.Output +
+		float64(t.CacheWrite)*price.CacheWrite +
+		float64(t.CacheRead)*price.CacheRead
+	return cost / 1e6, true
+}
+
+// Totals aggregates usage records.
+type Totals struct {
+	Tokens
+	Cost     float64  `json:"cost_usd"`
+	Requests int      `json:"requests"`
+	Unpriced []string `json:"unpriced_models,omitempty"` // models whose tokens aren't in Cost
+}
+
+// Add counts a record, pricing it with prices.
+func (t *Totals) Add(rec Record, prices PriceTable) {
+	t.Tokens.Add(rec.Tokens)
+	t.Requests++
+	if cost, ok := prices.Cost(rec.Model, rec.Tokens); ok {
+		t.Cost += cost
+	} else if !slices.Contains(t.Unpriced, rec.Model) {
+		t.Unpriced = append(t.Unpriced, rec.Model)
+		slices.Sort(t.Unpriced)
+	}
+}
+
+// Merge adds another aggregate to t.
+func (t *Totals) Merge(o Totals) {
+	t.Tokens.Add(o.Tokens)
+	t.Cost += o.Cost
+	t.Requests += o.Requests
+	for _, model := range o.Unpriced {
+		if !slices.Contains(t.Unpriced, model) {
+			t.Unpriced = append(t.Unpriced, model)
+		}
+	}
+	slices.Sort(t.Unpriced)
+}
+
+// FormatCost renders a cost in dollars, e.g. "$0.42" or "$12.30".
+func FormatCost(usd float64) string {
+	return fmt.Sprintf("$%.2f", usd)
+}
+
+// FormatTokens renders a token count compactly, e.g. "950", "12.4k", "3.1M".
+func FormatTokens(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// ParseTime parses a --since/--until value: a date (2006-01-02), an RFC 3339
+// time, or an age such as "36h" or "7d" counted back from now. A date used as
+// an end bound covers the whole day.
+func ParseTime(s string, now time.Time, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err == nil && n >= 0 && fmt.Sprint(n) == days {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (want YYYY-MM-DD, RFC 3339, or an age like 24h or 7d)", s)
+}
@@ -0,0 +1,106 @@
+package usage
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zpdzap/sandcastles/internal/config"
+)
+
+const session = `{"type":"user","timestamp":"2025-06-01T12:00:00Z","message":{"role":"user","content":"hi"}}
+{"type":"assistant","timestamp":"2025-06-01T12:00:01Z","message":{"id":"msg_1","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":5,"cache_creation_input_tokens":1000,"cache_read_input_tokens":0}}}
+{"type":"assistant","timestamp":"2025-06-01T12:00:02Z","message":{"id":"msg_1","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":200,"cache_creation_input_tokens":1000,"cache_read_input_tokens":0}}}
+{"type":"assistant","timestamp":"2025-06-01T12:00:05Z","message":{"id":"msg_2","model":"claude-opus-4-1-20250805","usage":{"input_tokens":4,"output_tokens":100,"cache_creation_input_tokens":0,"cache_read_input_tokens":2000}}}
+{"type":"assistant","timestamp":"2025-06-01T12:00:06Z","message":{"id":"msg_3","model":"<synthetic>","usage":{"input_tokens":0,"output_tokens":0}}}
+{"type":"assistant","timestamp":"2025-06-01T12:00:07Z","message":{"id":"msg_4","model":"mystery-model","usage":{"input_tokens":7,"output_tokens":3}}}
+`
+
+func TestParse(t *testing.T) {
+	records, err := Parse(strings.NewReader(session))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Parse() = %d records, want 3: %+v", len(records), records)
+	}
+	// The second line for msg_1 replaces the first
+	if records[0].Output != 200 || records[0].CacheWrite != 1000 {
+		t.Errorf("records[0] = %+v", records[0])
+	}
+	if records[1].Model != "claude-opus-4-1-20250805" || records[1].CacheRead != 2000 {
+		t.Errorf("records[1] = %+v", records[1])
+	}
+}
+
+func TestTotals(t *testing.T) {
+	records, _ := Parse(strings.NewReader(session))
+	prices := NewPriceTable(nil)
+
+	var totals Totals
+	for _, rec := range records {
+		totals.Add(rec, prices)
+	}
+	// sonnet: 10*3 + 200*15 + 1000*3.75 = 6780; opus 4.1: 4*15 + 100*75 + 2000*1.5 = 10560
+	if want := (6780.0 + 10560.0) / 1e6; math.Abs(totals.Cost-want) > 1e-9 {
+		t.Errorf("Cost = %v, want %v", totals.Cost, want)
+	}
+	if totals.Requests != 3 || totals.Input != 21 || totals.Output != 303 {
+		t.Errorf("totals = %+v", totals)
+	}
+	if len(totals.Unpriced) != 1 || totals.Unpriced[0] != "mystery-model" {
+		t.Errorf("Unpriced = %v", totals.Unpriced)
+	}
+}
+
+func TestPriceTableLookup(t *testing.T) {
+	prices := NewPriceTable(map[string]config.ModelPrice{
+		"claude-sonnet-4": {Input: 1},
+		"mystery":         {Input: 2},
+	})
+	tests := map[string]float64{
+		"claude-opus-4-5-20251101": 5,  // longer prefix beats claude-opus-4
+		"claude-opus-4-20250514":   15, // default
+		"claude-sonnet-4-5":        1,  // overridden
+		"mystery-model":            2,  // added
+	}
+	for model, want := range tests {
+		price, ok := prices.Lookup(model)
+		if !ok || price.Input != want {
+			t.Errorf("Lookup(%q) = %+v, %v; want input %v", model, price, ok, want)
+		}
+	}
+	if _, ok := prices.Lookup("gpt-4"); ok {
+		t.Error("Lookup(gpt-4) found a price")
+	}
+	if DefaultPrices["claude-sonnet-4"].Input != 3 {
+		t.Error("NewPriceTable modified DefaultPrices")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2025, 6, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		end  bool
+		want time.Time
+	}{
+		{"2025-06-01", false, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"2025-06-01", true, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+		{"2025-06-01T08:30:00Z", false, time.Date(2025, 6, 1, 8, 30, 0, 0, time.UTC)},
+		{"7d", false, time.Date(2025, 6, 3, 15, 0, 0, 0, time.UTC)},
+		{"36h", false, time.Date(2025, 6, 9, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now, tt.end)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q, %v) = %v, %v; want %v", tt.in, tt.end, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"yesterday", "-3d", "3.5d", ""} {
+		if _, err := ParseTime(bad, now, false); err == nil {
+			t.Errorf("ParseTime(%q) succeeded", bad)
+		}
+	}
+}
@@ -10,16 +10,21 @@ import (
 	"github.com/zpdzap/sandcastles/internal/config"
 )
 
-// Create creates a new git worktree for a sandbox.
+// Create creates a new git worktree for a sandbox, branching from base
+// (any commit-ish), or from HEAD if base is empty.
 // Returns the absolute worktree path and branch name.
-func Create(projectDir, name string) (string, string, error) {
+func Create(projectDir, name, base string) (string, string, error) {
 	wtPath := filepath.Join(projectDir, config.Dir, config.WorktreeDir, name)
 	branch := fmt.Sprintf("sandcastle/%s", name)
 
 	// Delete stale branch if it exists (left over from a previous unclean destroy)
 	exec.Command("git", "-C", projectDir, "branch", "-D", branch).Run()
 
-	cmd := exec.Command("git", "worktree", "add", wtPath, "-b", branch)
+	args := []string{"worktree", "add", wtPath, "-b", branch}
+	if base != "" {
+		args = append(args, base)
+	}
+	cmd := exec.Command("git", args...)
 	cmd.Dir = projectDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -33,6 +38,15 @@ func Create(projectDir, name string) (string, string, error) {
 	return absPath, branch, nil
 }
 
+// ResolveRef returns the commit a ref (branch, tag, SHA, HEAD~2, ...) points to.
+func ResolveRef(projectDir, ref string) (string, error) {
+	out, err := exec.Command("git", "-C", projectDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown git ref %q", ref)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 // Remove removes a git worktree and optionally deletes the branch.
 func Remove(projectDir, name string) error {
 	wtPath := filepath.Join(projectDir, config.Dir, config.WorktreeDir, name)
@@ -43,6 +57,39 @@ func Remove(projectDir, name string) error {
 	cmd.Dir = projectDir
 	cmd.Run() // best-effort
 
+	removeLeftovers(projectDir, wtPath)
+
+	// Delete the branch
+	branchCmd := exec.Command("git", "branch", "-D", branch)
+	branchCmd.Dir = projectDir
+	branchCmd.Run() // best-effort
+
+	return nil
+}
+
+// CreateDetached checks out HEAD into an empty directory at path as a
+// detached worktree, for throwaway builds that need the project's files
+// without a branch.
+func CreateDetached(projectDir, path string) error {
+	cmd := exec.Command("git", "worktree", "add", "--detach", path, "HEAD")
+	cmd.Dir = projectDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git worktree add: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// RemoveDetached removes a worktree created with CreateDetached.
+func RemoveDetached(projectDir, path string) {
+	cmd := exec.Command("git", "worktree", "remove", "--force", path)
+	cmd.Dir = projectDir
+	cmd.Run() // best-effort
+	removeLeftovers(projectDir, path)
+}
+
+// removeLeftovers deletes a worktree directory that `git worktree remove`
+// could not, and prunes its metadata.
+func removeLeftovers(projectDir, wtPath string) {
 	// If the directory still exists (e.g. files owned by container UID),
 	// use a Docker container to remove it as root.
 	if _, err := os.Stat(wtPath); err == nil {
@@ -52,19 +99,13 @@ func Remove(projectDir, name string) error {
 				"-v", absPath+":/cleanup",
 				"alpine", "rm", "-rf", "/cleanup").Run()
 		}
+		os.Remove(wtPath)
 	}
 
 	// Prune stale worktree metadata if the directory is gone
 	pruneCmd := exec.Command("git", "worktree", "prune")
 	pruneCmd.Dir = projectDir
 	pruneCmd.Run() // best-effort
-
-	// Delete the branch
-	branchCmd := exec.Command("git", "branch", "-D", branch)
-	branchCmd.Dir = projectDir
-	branchCmd.Run() // best-effort
-
-	return nil
 }
 
 // List returns the names of existing sandcastle worktrees.